@@ -0,0 +1,490 @@
+package poker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAuditEntryNotFound = errors.New("audit entry not found")
+	ErrAlreadyReversed    = errors.New("audit entry has already been reversed")
+	ErrNotReversible      = errors.New("audit entry can't be reversed")
+)
+
+// The interfaces changes are made through.
+const (
+	ViaCLI  = "cli"
+	ViaHTTP = "http"
+)
+
+// The changes an audit entry can record.
+const (
+	RecordAction  = "record"
+	ImportAction  = "import"
+	DeleteAction  = "delete"
+	RenameAction  = "rename"
+	ReverseAction = "reverse"
+)
+
+// Actor is who made a change and through which interface.
+type Actor struct {
+	Who string
+	Via string
+}
+
+// AuditEntry is a change made to a store. Game is the game recorded or
+// deleted, Games the games imported together, From and To the names of a
+// rename and Reverses the entry that a reverse undid.
+type AuditEntry struct {
+	ID       int          `json:"id"`
+	At       time.Time    `json:"at"`
+	Who      string       `json:"who"`
+	Via      string       `json:"via"`
+	Action   string       `json:"action"`
+	Game     *GameRecord  `json:"game,omitempty"`
+	Games    []GameRecord `json:"games,omitempty"`
+	From     string       `json:"from,omitempty"`
+	To       string       `json:"to,omitempty"`
+	Reverses int          `json:"reverses,omitempty"`
+}
+
+func (e AuditEntry) String() string {
+	var change string
+	switch e.Action {
+	case RecordAction:
+		change = fmt.Sprintf("recorded game %d won by %s", e.Game.ID, e.Game.Winner())
+	case ImportAction:
+		change = fmt.Sprintf("imported %d games", len(e.Games))
+	case DeleteAction:
+		change = fmt.Sprintf("deleted game %d won by %s", e.Game.ID, e.Game.Winner())
+	case RenameAction:
+		change = fmt.Sprintf("renamed %s to %s", e.From, e.To)
+	case ReverseAction:
+		change = fmt.Sprintf("reversed #%d", e.Reverses)
+	default:
+		change = e.Action
+	}
+
+	return fmt.Sprintf("#%d %s %s (%s) %s", e.ID, e.At.Format("2006-01-02 15:04"), e.Who, e.Via, change)
+}
+
+// AuditLog keeps every entry as a line appended to its file.
+type AuditLog struct {
+	// changing is held by audited stores for the whole of a change, so the
+	// entry of a change that fails is always the last one to roll back.
+	changing sync.Mutex
+
+	mu       sync.Mutex
+	file     *os.File
+	clock    Clock
+	entries  []AuditEntry
+	size     int64
+	lastSize int64
+}
+
+func NewAuditLog(file *os.File) (*AuditLog, error) {
+	audit := &AuditLog{file: file, clock: RealClock}
+
+	if err := audit.load(); err != nil {
+		return nil, fmt.Errorf("problem loading audit log %s, %v", file.Name(), err)
+	}
+
+	return audit, nil
+}
+
+func AuditLogFromFile(path string) (*AuditLog, func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem opening %s %v", path, err)
+	}
+
+	audit, err := NewAuditLog(file)
+
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return audit, audit.Close, nil
+}
+
+// SetClock sets where the times of new entries come from.
+func (a *AuditLog) SetClock(clock Clock) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clock = clock
+}
+
+func (a *AuditLog) Entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := make([]AuditEntry, len(a.entries))
+	copy(entries, a.entries)
+	return entries
+}
+
+func (a *AuditLog) Entry(id int) (AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, entry := range a.entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return AuditEntry{}, ErrAuditEntryNotFound
+}
+
+func (a *AuditLog) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.file.Close()
+}
+
+func (a *AuditLog) add(actor Actor, entry AuditEntry) (AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry.ID = len(a.entries) + 1
+	entry.At = a.clock.Now().UTC()
+	entry.Who = actor.Who
+	entry.Via = actor.Via
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	line = append(line, '\n')
+
+	if _, err := a.file.Write(line); err != nil {
+		a.truncateAt(a.size)
+		return AuditEntry{}, fmt.Errorf("problem writing audit entry, %v", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		a.truncateAt(a.size)
+		return AuditEntry{}, fmt.Errorf("problem syncing audit log, %v", err)
+	}
+
+	a.entries = append(a.entries, entry)
+	a.lastSize = a.size
+	a.size += int64(len(line))
+	return entry, nil
+}
+
+// rollback takes back entry, which has to be the last one added, when the
+// change it records couldn't be made.
+func (a *AuditLog) rollback(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	last := len(a.entries) - 1
+	if last < 0 || a.entries[last].ID != entry.ID {
+		return fmt.Errorf("problem rolling back audit entry #%d, it isn't the last one", entry.ID)
+	}
+
+	if err := a.truncateAt(a.lastSize); err != nil {
+		return fmt.Errorf("problem rolling back audit entry #%d, %v", entry.ID, err)
+	}
+	a.entries = a.entries[:last]
+	a.size = a.lastSize
+	return nil
+}
+
+func (a *AuditLog) reversed(id int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, entry := range a.entries {
+		if entry.Action == ReverseAction && entry.Reverses == id {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the entries back, cutting off a last line that was never
+// finished like the event log does.
+func (a *AuditLog) load() error {
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(a.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return a.truncateAt(offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return a.truncateAt(offset)
+			}
+			return fmt.Errorf("problem parsing audit entry at offset %d, %v", offset, err)
+		}
+
+		a.entries = append(a.entries, entry)
+		offset += int64(len(line))
+	}
+
+	a.size = offset
+	_, err := a.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (a *AuditLog) truncateAt(offset int64) error {
+	if err := a.file.Truncate(offset); err != nil {
+		return err
+	}
+	a.size = offset
+	_, err := a.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// AuditedPlayerStore is a PlayerStore that writes every change made through
+// it to an audit log on behalf of its actor.
+type AuditedPlayerStore struct {
+	PlayerStore
+	audit *AuditLog
+	actor Actor
+}
+
+func NewAuditedPlayerStore(store PlayerStore, audit *AuditLog, actor Actor) *AuditedPlayerStore {
+	return &AuditedPlayerStore{
+		PlayerStore: store,
+		audit:       audit,
+		actor:       actor,
+	}
+}
+
+// As is the same store making its changes on behalf of actor.
+func (s *AuditedPlayerStore) As(actor Actor) *AuditedPlayerStore {
+	return NewAuditedPlayerStore(s.PlayerStore, s.audit, actor)
+}
+
+func (s *AuditedPlayerStore) AuditLog() *AuditLog {
+	return s.audit
+}
+
+// OnChange hears about the changes to the store underneath, whoever they are
+// made on behalf of. It never notifies when that store can't.
+func (s *AuditedPlayerStore) OnChange(notify func()) func() {
+	if notifier, ok := s.PlayerStore.(ChangeNotifier); ok {
+		return notifier.OnChange(notify)
+	}
+	return func() {}
+}
+
+func (s *AuditedPlayerStore) RecordWin(name string) error {
+	if _, err := s.RecordGame(winRecord(name)); err != nil {
+		return fmt.Errorf("problem recording win for %s, %v", name, err)
+	}
+	return nil
+}
+
+// RecordGame records game and then its entry, deleting the game again when
+// the entry can't be written.
+func (s *AuditedPlayerStore) RecordGame(game GameRecord) (GameRecord, error) {
+	s.audit.changing.Lock()
+	defer s.audit.changing.Unlock()
+
+	recorded, err := s.PlayerStore.RecordGame(game)
+	if err != nil {
+		return GameRecord{}, err
+	}
+
+	if _, err := s.audit.add(s.actor, AuditEntry{Action: RecordAction, Game: &recorded}); err != nil {
+		return GameRecord{}, undone(err, s.PlayerStore.DeleteGame(recorded.ID))
+	}
+	return recorded, nil
+}
+
+func (s *AuditedPlayerStore) RecordGames(games []GameRecord) ([]GameRecord, error) {
+	s.audit.changing.Lock()
+	defer s.audit.changing.Unlock()
+
+	recorded, err := s.PlayerStore.RecordGames(games)
+	if err != nil || len(recorded) == 0 {
+		return recorded, err
+	}
+
+	if _, err := s.audit.add(s.actor, AuditEntry{Action: ImportAction, Games: recorded}); err != nil {
+		var undoErr error
+		for _, game := range recorded {
+			if undoErr = s.PlayerStore.DeleteGame(game.ID); undoErr != nil {
+				break
+			}
+		}
+		return nil, undone(err, undoErr)
+	}
+	return recorded, nil
+}
+
+// DeleteGame writes its entry first, taking it back when the game can't be
+// deleted, since a deleted game can only be recorded again under a new ID.
+func (s *AuditedPlayerStore) DeleteGame(id int) error {
+	s.audit.changing.Lock()
+	defer s.audit.changing.Unlock()
+
+	game, err := s.PlayerStore.GetGame(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.audited(AuditEntry{Action: DeleteAction, Game: &game}, func() error {
+		return s.PlayerStore.DeleteGame(id)
+	})
+	return err
+}
+
+// RenamePlayer writes its entry first like DeleteGame, since renaming back
+// can't split up two players the rename merged.
+func (s *AuditedPlayerStore) RenamePlayer(from, to string) error {
+	s.audit.changing.Lock()
+	defer s.audit.changing.Unlock()
+
+	_, err := s.audited(AuditEntry{Action: RenameAction, From: from, To: to}, func() error {
+		return s.PlayerStore.RenamePlayer(from, to)
+	})
+	return err
+}
+
+// audited writes entry and then makes its change, rolling the entry back if
+// the change fails. It must be called with the audit log's changing lock
+// held.
+func (s *AuditedPlayerStore) audited(entry AuditEntry, change func() error) (AuditEntry, error) {
+	added, err := s.audit.add(s.actor, entry)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if err := change(); err != nil {
+		if rollbackErr := s.audit.rollback(added); rollbackErr != nil {
+			return AuditEntry{}, fmt.Errorf("%w, and %v", err, rollbackErr)
+		}
+		return AuditEntry{}, err
+	}
+	return added, nil
+}
+
+// undone is err from writing the entry of a change, saying whether the
+// change could be undone.
+func undone(err, undoErr error) error {
+	if undoErr != nil {
+		return fmt.Errorf("%v, and the change couldn't be undone, %v", err, undoErr)
+	}
+	return fmt.Errorf("%v, so the change was undone", err)
+}
+
+// ReverseError is an import that was only partly reversed. Undone are the
+// games deleted, each with an entry of its own, before Err stopped it.
+type ReverseError struct {
+	Entry  int
+	Undone []int
+	Err    error
+}
+
+func (e ReverseError) Error() string {
+	return fmt.Sprintf("reversed only part of #%d, deleted games %v before %v", e.Entry, e.Undone, e.Err)
+}
+
+func (e ReverseError) Unwrap() error {
+	return e.Err
+}
+
+// Reverse undoes the change of an entry, writing its entry first like
+// DeleteGame. A deleted game is recorded again under a new ID, and a rename
+// is undone by renaming back, which can't split up two players a rename
+// merged. Imported games are only deleted once they have all been found.
+func (s *AuditedPlayerStore) Reverse(id int) (AuditEntry, error) {
+	s.audit.changing.Lock()
+	defer s.audit.changing.Unlock()
+
+	entry, err := s.audit.Entry(id)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if s.audit.reversed(id) {
+		return AuditEntry{}, ErrAlreadyReversed
+	}
+
+	var change func() error
+	switch entry.Action {
+	case RecordAction:
+		change = func() error { return s.PlayerStore.DeleteGame(entry.Game.ID) }
+	case ImportAction:
+		return s.reverseImport(entry)
+	case DeleteAction:
+		change = func() error {
+			_, err := s.PlayerStore.RecordGame(*entry.Game)
+			return err
+		}
+	case RenameAction:
+		change = func() error { return s.PlayerStore.RenamePlayer(entry.To, entry.From) }
+	default:
+		return AuditEntry{}, ErrNotReversible
+	}
+
+	return s.audited(AuditEntry{Action: ReverseAction, Reverses: id}, change)
+}
+
+// reverseImport deletes the games of an import one at a time. When one can't
+// be deleted it stops with a ReverseError, auditing the deletes it made on
+// their own instead of as a reverse of the import.
+func (s *AuditedPlayerStore) reverseImport(entry AuditEntry) (AuditEntry, error) {
+	for _, game := range entry.Games {
+		if _, err := s.PlayerStore.GetGame(game.ID); err != nil {
+			return AuditEntry{}, fmt.Errorf("problem reversing #%d, game %d, %w", entry.ID, game.ID, err)
+		}
+	}
+
+	reverse, err := s.audit.add(s.actor, AuditEntry{Action: ReverseAction, Reverses: entry.ID})
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	for i, game := range entry.Games {
+		err := s.PlayerStore.DeleteGame(game.ID)
+		if err == nil {
+			continue
+		}
+
+		if rollbackErr := s.audit.rollback(reverse); rollbackErr != nil {
+			return AuditEntry{}, fmt.Errorf("%w, and %v", err, rollbackErr)
+		}
+		if i == 0 {
+			return AuditEntry{}, err
+		}
+
+		reverseErr := ReverseError{Entry: entry.ID, Err: err}
+		for _, deleted := range entry.Games[:i] {
+			deleted := deleted
+			reverseErr.Undone = append(reverseErr.Undone, deleted.ID)
+			if _, err := s.audit.add(s.actor, AuditEntry{Action: DeleteAction, Game: &deleted}); err != nil {
+				reverseErr.Err = fmt.Errorf("%v, and %v", reverseErr.Err, err)
+			}
+		}
+		return AuditEntry{}, reverseErr
+	}
+	return reverse, nil
+}
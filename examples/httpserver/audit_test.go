@@ -0,0 +1,276 @@
+package poker_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+var cleo = poker.Actor{Who: "cleo", Via: poker.ViaCLI}
+
+func TestAuditedPlayerStore(t *testing.T) {
+	t.Run("records who made each change and when", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+		clock := poker.NewStubClock()
+		audit.SetClock(clock)
+
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+		poker.AssertNoError(t, store.As(poker.Actor{Who: "chris", Via: poker.ViaHTTP}).RenamePlayer("Chirs", "Chris"))
+		poker.AssertNoError(t, store.DeleteGame(1))
+
+		entries := audit.Entries()
+		if len(entries) != 3 {
+			t.Fatalf("got %d entries want 3", len(entries))
+		}
+
+		assertAuditEntry(t, entries[0], "#1 2019-03-01 20:00 cleo (cli) recorded game 1 won by Chirs")
+		assertAuditEntry(t, entries[1], "#2 2019-03-01 20:00 chris (http) renamed Chirs to Chris")
+		assertAuditEntry(t, entries[2], "#3 2019-03-01 20:00 cleo (cli) deleted game 1 won by Chris")
+	})
+
+	t.Run("doesn't audit changes that fail", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+
+		poker.AssertError(t, store.RenamePlayer("Chirs", "Chris"), poker.ErrPlayerNotFound)
+		poker.AssertError(t, store.DeleteGame(1), poker.ErrGameNotFound)
+
+		if len(audit.Entries()) != 0 {
+			t.Errorf("expected no entries but got %v", audit.Entries())
+		}
+	})
+
+	t.Run("takes back the entry of a change the store can't make", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+		auditFile, cleanAuditFile := poker.CreateTempFile(t, "")
+		defer cleanAuditFile()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+		audit, err := poker.NewAuditLog(auditFile)
+		poker.AssertNoError(t, err)
+		audited := poker.NewAuditedPlayerStore(store, audit, cleo)
+
+		poker.AssertNoError(t, audited.RecordWin("Chris"))
+		poker.AssertError(t, audited.RenamePlayer("Ruth", "Chris"), poker.ErrPlayerNotFound)
+		poker.AssertNoError(t, audited.RecordWin("Cleo"))
+
+		reopened, closeReopened, err := poker.AuditLogFromFile(auditFile.Name())
+		poker.AssertNoError(t, err)
+		defer closeReopened()
+
+		entries := reopened.Entries()
+		if len(entries) != 2 || entries[1].ID != 2 || entries[1].Action != poker.RecordAction {
+			t.Errorf("got %v want the two recorded games", entries)
+		}
+	})
+
+	t.Run("undoes a change whose entry can't be written", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+
+		audit.Close()
+
+		if err := store.RecordWin("Cleo"); err == nil {
+			t.Error("expected an error recording a win that can't be audited")
+		}
+		if err := store.RenamePlayer("Chirs", "Chris"); err == nil {
+			t.Error("expected an error renaming a player when it can't be audited")
+		}
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chirs", Wins: 1}})
+	})
+
+	t.Run("reverses a recorded game", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+		poker.AssertNoError(t, store.RecordWin("Cleo"))
+
+		entry, err := store.Reverse(1)
+		poker.AssertNoError(t, err)
+
+		assertAuditEntry(t, entry, "#3 "+entry.At.Format("2006-01-02 15:04")+" cleo (cli) reversed #1")
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("reverses a deleted game", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Cleo"))
+		poker.AssertNoError(t, store.DeleteGame(1))
+
+		_, err := store.Reverse(2)
+		poker.AssertNoError(t, err)
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("reverses a rename", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chris"))
+		poker.AssertNoError(t, store.RenamePlayer("Chris", "Chirs"))
+
+		_, err := store.Reverse(2)
+		poker.AssertNoError(t, err)
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("reverses an entry only once", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+
+		_, err := store.Reverse(1)
+		poker.AssertNoError(t, err)
+
+		_, err = store.Reverse(1)
+		poker.AssertError(t, err, poker.ErrAlreadyReversed)
+
+		_, err = store.Reverse(2)
+		poker.AssertError(t, err, poker.ErrNotReversible)
+
+		_, err = store.Reverse(42)
+		poker.AssertError(t, err, poker.ErrAuditEntryNotFound)
+	})
+}
+
+// failingDeleteStore fails to delete any game after the first few.
+type failingDeleteStore struct {
+	poker.PlayerStore
+	deletes int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (s *failingDeleteStore) DeleteGame(id int) error {
+	if s.deletes == 0 {
+		return errDiskFull
+	}
+	s.deletes--
+	return s.PlayerStore.DeleteGame(id)
+}
+
+func TestAuditedPlayerStore_ReverseImport(t *testing.T) {
+	newImport := func(t *testing.T, deletes int) (*poker.AuditedPlayerStore, *poker.AuditLog) {
+		t.Helper()
+		store, audit := newAuditedStore(t)
+		games := []poker.GameRecord{
+			{Players: []string{"Cleo", "Chris"}},
+			{Players: []string{"Chris", "Cleo"}},
+			{Players: []string{"Ruth", "Cleo"}},
+		}
+		_, err := store.RecordGames(games)
+		poker.AssertNoError(t, err)
+
+		failing := &failingDeleteStore{PlayerStore: store.PlayerStore, deletes: deletes}
+		return poker.NewAuditedPlayerStore(failing, audit, cleo), audit
+	}
+
+	t.Run("it stops with what it undid when a game can't be deleted", func(t *testing.T) {
+		store, audit := newImport(t, 1)
+
+		_, err := store.Reverse(1)
+
+		var reverseErr poker.ReverseError
+		if !errors.As(err, &reverseErr) {
+			t.Fatalf("got %v want a ReverseError", err)
+		}
+		if !reflect.DeepEqual(reverseErr.Undone, []int{1}) || !errors.Is(err, errDiskFull) {
+			t.Errorf("got %+v", reverseErr)
+		}
+
+		entries := audit.Entries()
+		if len(entries) != 2 || entries[1].String() != "#2 "+entries[1].At.Format("2006-01-02 15:04")+" cleo (cli) deleted game 1 won by Cleo" {
+			t.Errorf("got %v want the import and the game deleted", entries)
+		}
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chris", Wins: 1}, {Name: "Ruth", Wins: 1}})
+	})
+
+	t.Run("it deletes nothing when the first game can't be deleted", func(t *testing.T) {
+		store, audit := newImport(t, 0)
+
+		_, err := store.Reverse(1)
+
+		if !errors.Is(err, errDiskFull) {
+			t.Errorf("got %v want %v", err, errDiskFull)
+		}
+		if len(audit.Entries()) != 1 {
+			t.Errorf("got %v want only the import", audit.Entries())
+		}
+	})
+
+	t.Run("it deletes nothing when a game has already gone", func(t *testing.T) {
+		store, audit := newImport(t, 3)
+		poker.AssertNoError(t, store.PlayerStore.DeleteGame(3))
+
+		_, err := store.Reverse(1)
+
+		if !errors.Is(err, poker.ErrGameNotFound) {
+			t.Errorf("got %v want %v", err, poker.ErrGameNotFound)
+		}
+		if len(audit.Entries()) != 1 {
+			t.Errorf("got %v want only the import", audit.Entries())
+		}
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("keeps its entries when reopened", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+		auditFile, cleanAuditFile := poker.CreateTempFile(t, "")
+		defer cleanAuditFile()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+		audit, err := poker.NewAuditLog(auditFile)
+		poker.AssertNoError(t, err)
+		audit.SetClock(poker.NewStubClock())
+
+		poker.AssertNoError(t, poker.NewAuditedPlayerStore(store, audit, cleo).RecordWin("Cleo"))
+
+		reopened, closeReopened, err := poker.AuditLogFromFile(auditFile.Name())
+		poker.AssertNoError(t, err)
+		defer closeReopened()
+
+		entry, err := reopened.Entry(1)
+		poker.AssertNoError(t, err)
+		assertAuditEntry(t, entry, "#1 2019-03-01 20:00 cleo (cli) recorded game 1 won by Cleo")
+	})
+
+	t.Run("cuts off an entry that was never finished", func(t *testing.T) {
+		auditFile, cleanAuditFile := poker.CreateTempFile(t, `{"id":1,"action":"rename","from":"Chirs","to":"Chris"}`+"\n"+`{"id":2,"act`)
+		defer cleanAuditFile()
+
+		audit, err := poker.NewAuditLog(auditFile)
+		poker.AssertNoError(t, err)
+
+		if len(audit.Entries()) != 1 {
+			t.Errorf("got %d entries want 1", len(audit.Entries()))
+		}
+	})
+}
+
+func newAuditedStore(t *testing.T) (*poker.AuditedPlayerStore, *poker.AuditLog) {
+	t.Helper()
+
+	database, cleanDatabase := poker.CreateTempFile(t, "")
+	t.Cleanup(cleanDatabase)
+	auditFile, cleanAuditFile := poker.CreateTempFile(t, "")
+	t.Cleanup(cleanAuditFile)
+
+	store, err := poker.NewFileSystemPlayerStore(database)
+	poker.AssertNoError(t, err)
+
+	audit, err := poker.NewAuditLog(auditFile)
+	poker.AssertNoError(t, err)
+
+	return poker.NewAuditedPlayerStore(store, audit, cleo), audit
+}
+
+func assertAuditEntry(t *testing.T, entry poker.AuditEntry, want string) {
+	t.Helper()
+	if entry.String() != want {
+		t.Errorf("got entry %q want %q", entry.String(), want)
+	}
+}
@@ -1,23 +1,69 @@
 package poker
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"time"
 )
 
+type Blind struct {
+	Level       int
+	Amount      int
+	Ante        int
+	Break       bool
+	NextLevelIn time.Duration
+}
+
 type BlindAlerter interface {
-	ScheduleAlertAt(duration time.Duration, amount int, to io.Writer)
+	ScheduleAlertAt(ctx context.Context, duration time.Duration, blind Blind, to io.Writer)
 }
 
-type BlindAlerterFunc func(duration time.Duration, amount int, to io.Writer)
+type BlindAlerterFunc func(ctx context.Context, duration time.Duration, blind Blind, to io.Writer)
+
+func (a BlindAlerterFunc) ScheduleAlertAt(ctx context.Context, duration time.Duration, blind Blind, to io.Writer) {
+	a(ctx, duration, blind, to)
+}
 
-func (a BlindAlerterFunc) ScheduleAlertAt(duration time.Duration, amount int, to io.Writer) {
-	a(duration, amount, to)
+// BlindWriter is implemented by destinations that want blind changes as
+// structured values rather than as a line of text.
+type BlindWriter interface {
+	WriteBlind(blind Blind) error
 }
 
-func Alerter(duration time.Duration, amount int, to io.Writer) {
-	time.AfterFunc(duration, func() {
-		fmt.Fprintf(to, "Blind is now %d\n", amount)
+// Alerter writes blind to to once duration has passed, unless ctx is done
+// first.
+func Alerter(ctx context.Context, duration time.Duration, blind Blind, to io.Writer) {
+	fired := make(chan struct{})
+	timer := time.AfterFunc(duration, func() {
+		defer close(fired)
+		if ctx.Err() == nil {
+			WriteBlind(to, blind)
+		}
 	})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-fired:
+		}
+	}()
+}
+
+func WriteBlind(to io.Writer, blind Blind) error {
+	if w, ok := to.(BlindWriter); ok {
+		return w.WriteBlind(blind)
+	}
+
+	var err error
+	switch {
+	case blind.Break:
+		_, err = fmt.Fprintf(to, "Break for %v\n", blind.NextLevelIn)
+	case blind.Ante > 0:
+		_, err = fmt.Fprintf(to, "Blind is now %d with an ante of %d\n", blind.Amount, blind.Ante)
+	default:
+		_, err = fmt.Fprintf(to, "Blind is now %d\n", blind.Amount)
+	}
+	return err
 }
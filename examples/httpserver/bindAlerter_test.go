@@ -0,0 +1,93 @@
+package poker_test
+
+import (
+	"bytes"
+	"context"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAlerter(t *testing.T) {
+	blind := poker.Blind{Level: 1, Amount: 100}
+
+	t.Run("writes the blind once the duration has passed", func(t *testing.T) {
+		out := &syncBuffer{}
+
+		poker.Alerter(context.Background(), time.Millisecond, blind, out)
+
+		within(t, 100*time.Millisecond, func() {
+			for out.String() == "" {
+				time.Sleep(time.Millisecond)
+			}
+		})
+		poker.AssertResponseBody(t, out.String(), "Blind is now 100\n")
+	})
+
+	t.Run("does not write the blind once cancelled", func(t *testing.T) {
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		poker.Alerter(ctx, 5*time.Millisecond, blind, out)
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+
+		poker.AssertResponseBody(t, out.String(), "")
+	})
+
+	t.Run("leaves nothing running once the blinds are written", func(t *testing.T) {
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		before := runtime.NumGoroutine()
+		for i := 0; i < 50; i++ {
+			poker.Alerter(ctx, time.Millisecond, blind, out)
+		}
+
+		if !retryUntilTrue(func() bool { return runtime.NumGoroutine() <= before }) {
+			t.Errorf("got %d goroutines running want at most %d", runtime.NumGoroutine(), before)
+		}
+	})
+}
+
+func TestWriteBlind(t *testing.T) {
+	cases := []struct {
+		Blind poker.Blind
+		Want  string
+	}{
+		{poker.Blind{Level: 1, Amount: 100}, "Blind is now 100\n"},
+		{poker.Blind{Level: 3, Amount: 400, Ante: 50}, "Blind is now 400 with an ante of 50\n"},
+		{poker.Blind{Level: 2, Break: true, NextLevelIn: 10 * time.Minute}, "Break for 10m0s\n"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.Want, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			err := poker.WriteBlind(out, test.Blind)
+
+			poker.AssertNoError(t, err)
+			poker.AssertResponseBody(t, out.String(), test.Want)
+		})
+	}
+}
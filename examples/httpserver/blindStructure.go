@@ -0,0 +1,156 @@
+package poker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const DefaultBlindStructureName = "standard"
+
+type BlindLevel struct {
+	Blind   int  `json:"blind,omitempty"`
+	Ante    int  `json:"ante,omitempty"`
+	Minutes int  `json:"minutes,omitempty"`
+	Break   bool `json:"break,omitempty"`
+}
+
+// BlindStructure is how a tournament is played, with the Payouts of its
+// prizes when it has its own.
+type BlindStructure struct {
+	Name    string       `json:"name"`
+	Levels  []BlindLevel `json:"levels"`
+	Payouts *PayoutTable `json:"payouts,omitempty"`
+}
+
+type BlindStructures []BlindStructure
+
+// DefaultBlindStructure leaves the level length out, so every level lasts
+// five minutes plus one minute per player.
+var DefaultBlindStructure = BlindStructure{
+	Name: DefaultBlindStructureName,
+	Levels: []BlindLevel{
+		{Blind: 100}, {Blind: 200}, {Blind: 300}, {Blind: 400}, {Blind: 500}, {Blind: 600},
+		{Blind: 800}, {Blind: 1000}, {Blind: 2000}, {Blind: 4000}, {Blind: 8000},
+	},
+}
+
+func (b BlindStructures) Find(name string) *BlindStructure {
+	for i, s := range b {
+		if s.Name == name {
+			return &b[i]
+		}
+	}
+	return nil
+}
+
+func NewBlindStructures(rdr io.Reader) (BlindStructures, error) {
+	var structures BlindStructures
+	err := json.NewDecoder(rdr).Decode(&structures)
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing blind structures, %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, s := range structures {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("blind structure %q is defined more than once", s.Name)
+		}
+		seen[s.Name] = true
+	}
+
+	return structures, nil
+}
+
+// LoadBlindStructure picks a structure by name from the file at path, or from
+// the built in structures when path is empty.
+func LoadBlindStructure(path, name string) (BlindStructure, error) {
+	structures := BlindStructures{DefaultBlindStructure}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return BlindStructure{}, fmt.Errorf("problem opening %s %v", path, err)
+		}
+		defer file.Close()
+
+		structures, err = NewBlindStructures(file)
+		if err != nil {
+			return BlindStructure{}, fmt.Errorf("problem loading blind structures from %s, %v", path, err)
+		}
+	}
+
+	structure := structures.Find(name)
+	if structure == nil {
+		return BlindStructure{}, fmt.Errorf("could not find a blind structure called %q", name)
+	}
+
+	return *structure, nil
+}
+
+// PayoutTable is how the prizes of the structure are paid out, the
+// DefaultPayoutTable when it has no payouts of its own.
+func (s BlindStructure) PayoutTable() PayoutTable {
+	if s.Payouts == nil {
+		return DefaultPayoutTable
+	}
+	return *s.Payouts
+}
+
+func (s BlindStructure) Blinds(numberOfPlayers int) []Blind {
+	var blinds []Blind
+
+	for i, level := range s.Levels {
+		nextLevelIn := time.Duration(level.Minutes) * time.Minute
+		if level.Minutes == 0 {
+			nextLevelIn = time.Duration(5+numberOfPlayers) * time.Minute
+		}
+		if i == len(s.Levels)-1 {
+			nextLevelIn = 0
+		}
+
+		blinds = append(blinds, Blind{
+			Level:       i + 1,
+			Amount:      level.Blind,
+			Ante:        level.Ante,
+			Break:       level.Break,
+			NextLevelIn: nextLevelIn,
+		})
+	}
+
+	return blinds
+}
+
+func (s BlindStructure) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("blind structures need a name")
+	}
+
+	if len(s.Levels) == 0 {
+		return fmt.Errorf("blind structure %q has no levels", s.Name)
+	}
+
+	if s.Payouts != nil {
+		if err := s.Payouts.Validate(); err != nil {
+			return fmt.Errorf("blind structure %q has bad payouts, %v", s.Name, err)
+		}
+	}
+
+	for i, level := range s.Levels {
+		switch {
+		case level.Minutes < 0 || level.Ante < 0:
+			return fmt.Errorf("blind structure %q level %d has negative values", s.Name, i+1)
+		case level.Break && level.Minutes == 0:
+			return fmt.Errorf("blind structure %q level %d is a break without minutes", s.Name, i+1)
+		case !level.Break && level.Blind <= 0:
+			return fmt.Errorf("blind structure %q level %d needs a blind", s.Name, i+1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package poker_test
+
+import (
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlindStructures(t *testing.T) {
+	t.Run("reads named structures with antes and breaks", func(t *testing.T) {
+		structures, err := poker.NewBlindStructures(strings.NewReader(`[
+            {"name": "turbo", "levels": [
+                {"blind": 100, "minutes": 5},
+                {"break": true, "minutes": 10},
+                {"blind": 200, "ante": 25, "minutes": 5}]}]`))
+		poker.AssertNoError(t, err)
+
+		got := structures.Find("turbo")
+		if got == nil {
+			t.Fatal("expected to find the turbo structure")
+		}
+
+		want := []poker.BlindLevel{
+			{Blind: 100, Minutes: 5},
+			{Break: true, Minutes: 10},
+			{Blind: 200, Ante: 25, Minutes: 5},
+		}
+		if !reflect.DeepEqual(got.Levels, want) {
+			t.Errorf("got %v want %v", got.Levels, want)
+		}
+	})
+
+	t.Run("reads the payouts of a structure", func(t *testing.T) {
+		structures, err := poker.NewBlindStructures(strings.NewReader(`[
+            {"name": "turbo", "levels": [{"blind": 100}], "payouts": {"buyIn": 20, "places": [70, 30]}},
+            {"name": "standard", "levels": [{"blind": 100}]}]`))
+		poker.AssertNoError(t, err)
+
+		want := poker.PayoutTable{BuyIn: 20, Places: []float64{70, 30}}
+		if got := structures.Find("turbo").PayoutTable(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+		if got := structures.Find("standard").PayoutTable(); !reflect.DeepEqual(got, poker.DefaultPayoutTable) {
+			t.Errorf("got %v want the default payouts", got)
+		}
+	})
+
+	t.Run("does not find structures that are not there", func(t *testing.T) {
+		structures := poker.BlindStructures{poker.DefaultBlindStructure}
+
+		if structures.Find("turbo") != nil {
+			t.Error("did not expect to find a turbo structure")
+		}
+	})
+
+	invalid := map[string]string{
+		"missing name":          `[{"levels": [{"blind": 100}]}]`,
+		"no levels":             `[{"name": "turbo", "levels": []}]`,
+		"level without a blind": `[{"name": "turbo", "levels": [{"minutes": 5}]}]`,
+		"break without minutes": `[{"name": "turbo", "levels": [{"break": true}]}]`,
+		"negative ante":         `[{"name": "turbo", "levels": [{"blind": 100, "ante": -1}]}]`,
+		"duplicate names":       `[{"name": "turbo", "levels": [{"blind": 100}]}, {"name": "turbo", "levels": [{"blind": 100}]}]`,
+		"not json":              `turbo`,
+		"bad payouts":           `[{"name": "turbo", "levels": [{"blind": 100}], "payouts": {"buyIn": 20, "places": [70]}}]`,
+	}
+
+	for name, data := range invalid {
+		t.Run("rejects structures with "+name, func(t *testing.T) {
+			_, err := poker.NewBlindStructures(strings.NewReader(data))
+
+			if err == nil {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+
+	t.Run("loads the standard structure when no file is given", func(t *testing.T) {
+		got, err := poker.LoadBlindStructure("", poker.DefaultBlindStructureName)
+		poker.AssertNoError(t, err)
+
+		if !reflect.DeepEqual(got, poker.DefaultBlindStructure) {
+			t.Errorf("got %v want %v", got, poker.DefaultBlindStructure)
+		}
+	})
+
+	t.Run("loads a structure by name from a file", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, `[{"name": "home-game", "levels": [{"blind": 25, "minutes": 20}]}]`)
+		defer clean()
+
+		got, err := poker.LoadBlindStructure(file.Name(), "home-game")
+		poker.AssertNoError(t, err)
+
+		if got.Name != "home-game" {
+			t.Errorf("got structure %q want %q", got.Name, "home-game")
+		}
+	})
+
+	t.Run("errors when the structure is unknown", func(t *testing.T) {
+		_, err := poker.LoadBlindStructure("", "turbo")
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestBlindStructure_Blinds(t *testing.T) {
+	structure := poker.BlindStructure{
+		Name: "club",
+		Levels: []poker.BlindLevel{
+			{Blind: 100, Minutes: 15},
+			{Break: true, Minutes: 10},
+			{Blind: 200, Ante: 25},
+			{Blind: 400, Ante: 50, Minutes: 15},
+		},
+	}
+
+	got := structure.Blinds(4)
+	want := []poker.Blind{
+		{Level: 1, Amount: 100, NextLevelIn: 15 * time.Minute},
+		{Level: 2, Break: true, NextLevelIn: 10 * time.Minute},
+		{Level: 3, Amount: 200, Ante: 25, NextLevelIn: 9 * time.Minute},
+		{Level: 4, Amount: 400, Ante: 50, NextLevelIn: 0},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
@@ -0,0 +1,261 @@
+package poker
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const DefaultSendBuffer = 16
+
+// SlowClientPolicy is what a Broadcaster does with a client that has fallen
+// so far behind its send buffer is full.
+type SlowClientPolicy int
+
+const (
+	// DisconnectSlowClients closes the client, which can join again to
+	// catch up.
+	DisconnectSlowClients SlowClientPolicy = iota
+	// DropMessages skips the messages that don't fit in the client's buffer.
+	DropMessages
+)
+
+func (p SlowClientPolicy) String() string {
+	if p == DropMessages {
+		return "drop"
+	}
+	return "disconnect"
+}
+
+func ParseSlowClientPolicy(name string) (SlowClientPolicy, error) {
+	switch name {
+	case "disconnect":
+		return DisconnectSlowClients, nil
+	case "drop":
+		return DropMessages, nil
+	}
+	return 0, fmt.Errorf("unknown slow client policy %q, want disconnect or drop", name)
+}
+
+// Broadcaster writes everything written to it to each of its clients. Every
+// client has a send buffer of its own that is drained in the background, so a
+// slow client never holds up the game or the other clients.
+type Broadcaster struct {
+	mu      sync.Mutex
+	size    int
+	policy  SlowClientPolicy
+	clients map[io.Writer]*broadcastClient
+}
+
+type broadcastClient struct {
+	to      io.Writer
+	send    chan func(io.Writer) error
+	stop    chan struct{}
+	closing bool
+	dropped int
+}
+
+func NewBroadcaster(size int, policy SlowClientPolicy) *Broadcaster {
+	if size < 1 {
+		size = DefaultSendBuffer
+	}
+	return &Broadcaster{
+		size:    size,
+		policy:  policy,
+		clients: map[io.Writer]*broadcastClient{},
+	}
+}
+
+// Add starts sending what is broadcast to client.
+func (b *Broadcaster) Add(client io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.clients[client]; ok {
+		return
+	}
+
+	c := &broadcastClient{
+		to:   client,
+		send: make(chan func(io.Writer) error, b.size),
+		stop: make(chan struct{}),
+	}
+	b.clients[client] = c
+	go c.run()
+}
+
+// Remove stops sending to client. What is already in its buffer is still
+// sent and then the client is closed if it can be, in the background, so a
+// client that is slow to take it doesn't hold up the caller.
+func (b *Broadcaster) Remove(client io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.clients[client]; ok {
+		b.remove(c, true, false)
+	}
+}
+
+// Close stops sending to every client and returns straight away. Each client
+// is sent what is left in its buffer and then closed if it can be, on its own
+// goroutine, which gives up on the rest of the buffer as soon as a write
+// fails. A client whose write never returns only holds up its own goroutine.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, c := range b.clients {
+		b.remove(c, true, false)
+	}
+}
+
+// Len is how many clients are being sent to.
+func (b *Broadcaster) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.clients)
+}
+
+// Dropped is how many messages client has missed for being too slow.
+func (b *Broadcaster) Dropped(client io.Writer) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.clients[client]; ok {
+		return c.dropped
+	}
+	return 0
+}
+
+func (b *Broadcaster) Write(p []byte) (int, error) {
+	msg := append([]byte(nil), p...)
+	b.broadcast(func(to io.Writer) error {
+		_, err := to.Write(msg)
+		return err
+	})
+	return len(p), nil
+}
+
+func (b *Broadcaster) WriteBlind(blind Blind) error {
+	b.broadcast(func(to io.Writer) error { return WriteBlind(to, blind) })
+	return nil
+}
+
+func (b *Broadcaster) WriteClock(state ClockState) error {
+	b.broadcast(func(to io.Writer) error { return WriteClock(to, state) })
+	return nil
+}
+
+func (b *Broadcaster) WriteElimination(out Elimination) error {
+	b.broadcast(func(to io.Writer) error { return WriteElimination(to, out) })
+	return nil
+}
+
+func (b *Broadcaster) WriteResult(result Result) error {
+	b.broadcast(func(to io.Writer) error { return WriteResult(to, result) })
+	return nil
+}
+
+func (b *Broadcaster) broadcast(write func(io.Writer) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, c := range b.clients {
+		select {
+		case c.send <- write:
+		default:
+			c.dropped++
+			if b.policy == DisconnectSlowClients {
+				b.remove(c, true, true)
+			}
+		}
+	}
+}
+
+// remove stops sending to c, closing it when closing is set, and dropping
+// what is left in its buffer when now is set. It must be called with mu held.
+func (b *Broadcaster) remove(c *broadcastClient, closing, now bool) {
+	delete(b.clients, c.to)
+	c.closing = closing
+	if now {
+		close(c.stop)
+	}
+	close(c.send)
+}
+
+// run sends to the client until it is removed. Once it is stopped or a write
+// fails the rest of what it is sent is dropped.
+func (c *broadcastClient) run() {
+	failed := false
+	for write := range c.send {
+		if failed || c.stopped() {
+			continue
+		}
+		failed = write(c.to) != nil
+	}
+
+	if closer, ok := c.to.(io.Closer); ok && c.closing {
+		closer.Close()
+	}
+}
+
+func (c *broadcastClient) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Elimination is a player going out of a game, finishing in Place.
+type Elimination struct {
+	Player string
+	Place  int
+}
+
+// EliminationWriter is implemented by destinations that want eliminations as
+// structured values rather than as a line of text.
+type EliminationWriter interface {
+	WriteElimination(out Elimination) error
+}
+
+func WriteElimination(to io.Writer, out Elimination) error {
+	if w, ok := to.(EliminationWriter); ok {
+		return w.WriteElimination(out)
+	}
+
+	if out.Place == 0 {
+		_, err := fmt.Fprintf(to, "%s is out\n", out.Player)
+		return err
+	}
+	_, err := fmt.Fprintf(to, "%s is out in place %d\n", out.Player, out.Place)
+	return err
+}
+
+// Result is how a game finished, its winner first and then the runners up.
+type Result struct {
+	Winner    string
+	RunnersUp []string
+}
+
+// ResultWriter is implemented by destinations that want results as
+// structured values rather than as a line of text.
+type ResultWriter interface {
+	WriteResult(result Result) error
+}
+
+func WriteResult(to io.Writer, result Result) error {
+	if w, ok := to.(ResultWriter); ok {
+		return w.WriteResult(result)
+	}
+
+	if len(result.RunnersUp) == 0 {
+		_, err := fmt.Fprintf(to, "%s wins\n", result.Winner)
+		return err
+	}
+	_, err := fmt.Fprintf(to, "%s wins over %s\n", result.Winner, strings.Join(result.RunnersUp, ", "))
+	return err
+}
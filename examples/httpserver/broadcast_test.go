@@ -0,0 +1,191 @@
+package poker_test
+
+import (
+	"fmt"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"testing"
+	"time"
+)
+
+// blockedWriter is a client that can't keep up, holding every write until it
+// is let go.
+type blockedWriter struct {
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newBlockedWriter() *blockedWriter {
+	return &blockedWriter{release: make(chan struct{}), closed: make(chan struct{})}
+}
+
+func (b *blockedWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func (b *blockedWriter) Close() error {
+	close(b.closed)
+	return nil
+}
+
+// closingBuffer is a syncBuffer that can be closed.
+type closingBuffer struct {
+	syncBuffer
+	closed bool
+}
+
+func (c *closingBuffer) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *closingBuffer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// assertEventuallyGot waits for everything sent to a client to reach it.
+func assertEventuallyGot(t *testing.T, client fmt.Stringer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for client.String() != want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	poker.AssertResponseBody(t, client.String(), want)
+}
+
+// retryUntilTrue polls f for up to a second.
+func retryUntilTrue(f func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !f() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return f()
+}
+
+func TestBroadcaster(t *testing.T) {
+	t.Run("it sends everything to every client", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(4, poker.DropMessages)
+		first, second := &syncBuffer{}, &syncBuffer{}
+		broadcaster.Add(first)
+		broadcaster.Add(second)
+
+		poker.WriteBlind(broadcaster, poker.Blind{Level: 2, Amount: 200})
+		poker.WriteElimination(broadcaster, poker.Elimination{Player: "Chris", Place: 3})
+		poker.WriteResult(broadcaster, poker.Result{Winner: "Cleo", RunnersUp: []string{"Ruth"}})
+
+		broadcaster.Remove(first)
+		broadcaster.Remove(second)
+
+		want := "Blind is now 200\nChris is out in place 3\nCleo wins over Ruth\n"
+		assertEventuallyGot(t, first, want)
+		assertEventuallyGot(t, second, want)
+	})
+
+	t.Run("a slow client doesn't hold up the others", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(1, poker.DropMessages)
+		slow := newBlockedWriter()
+		fast := &syncBuffer{}
+		broadcaster.Add(slow)
+		broadcaster.Add(fast)
+
+		within(t, 100*time.Millisecond, func() {
+			for i := 0; i < 5; i++ {
+				broadcaster.Write([]byte("Blind is now 100\n"))
+				time.Sleep(time.Millisecond)
+			}
+		})
+
+		if got := broadcaster.Dropped(slow); got == 0 {
+			t.Error("expected the slow client to miss messages")
+		}
+		if broadcaster.Len() != 2 {
+			t.Error("didn't expect the slow client to be disconnected")
+		}
+
+		close(slow.release)
+		broadcaster.Remove(slow)
+		broadcaster.Remove(fast)
+
+		assertEventuallyGot(t, fast, "Blind is now 100\nBlind is now 100\nBlind is now 100\nBlind is now 100\nBlind is now 100\n")
+	})
+
+	t.Run("a slow client can be disconnected", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(1, poker.DisconnectSlowClients)
+		slow := newBlockedWriter()
+		broadcaster.Add(slow)
+
+		within(t, 100*time.Millisecond, func() {
+			for broadcaster.Len() > 0 {
+				broadcaster.Write([]byte("Blind is now 100\n"))
+				time.Sleep(time.Millisecond)
+			}
+		})
+
+		close(slow.release)
+		within(t, 100*time.Millisecond, func() { <-slow.closed })
+	})
+
+	t.Run("removing a client sends what is left before closing it", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(4, poker.DisconnectSlowClients)
+		client := &closingBuffer{}
+		broadcaster.Add(client)
+
+		poker.WriteResult(broadcaster, poker.Result{Winner: "Cleo"})
+		broadcaster.Remove(client)
+
+		assertEventuallyGot(t, client, "Cleo wins\n")
+		if !retryUntilTrue(client.Closed) {
+			t.Error("expected the client to be closed")
+		}
+	})
+
+	t.Run("closing sends what is left before closing the clients", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(4, poker.DisconnectSlowClients)
+		client := &closingBuffer{}
+		broadcaster.Add(client)
+
+		poker.WriteResult(broadcaster, poker.Result{Winner: "Cleo"})
+		broadcaster.Close()
+
+		assertEventuallyGot(t, client, "Cleo wins\n")
+		if !retryUntilTrue(client.Closed) || broadcaster.Len() != 0 {
+			t.Error("expected the client to be closed")
+		}
+	})
+
+	t.Run("closing and removing don't wait for a client that never takes its messages", func(t *testing.T) {
+		broadcaster := poker.NewBroadcaster(4, poker.DropMessages)
+		stuck, alsoStuck := newBlockedWriter(), newBlockedWriter()
+		broadcaster.Add(stuck)
+		broadcaster.Add(alsoStuck)
+
+		broadcaster.Write([]byte("Blind is now 100\n"))
+		broadcaster.Write([]byte("Blind is now 200\n"))
+
+		within(t, 100*time.Millisecond, func() {
+			broadcaster.Remove(alsoStuck)
+			broadcaster.Close()
+		})
+		if broadcaster.Len() != 0 {
+			t.Error("expected no clients left")
+		}
+	})
+}
+
+func TestParseSlowClientPolicy(t *testing.T) {
+	for _, policy := range []poker.SlowClientPolicy{poker.DisconnectSlowClients, poker.DropMessages} {
+		got, err := poker.ParseSlowClientPolicy(policy.String())
+		poker.AssertNoError(t, err)
+		if got != policy {
+			t.Errorf("got %v want %v", got, policy)
+		}
+	}
+
+	if _, err := poker.ParseSlowClientPolicy("ignore"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -2,28 +2,473 @@ package poker
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
 )
 
 const PlayerPrompt = "Please enter the number of players: "
 const BadPlayerInputErrMsg = "Bad value received for number of players, please try again with a number"
-const BadPlayerWinInputErrMsg = "Bad value received for registering a player win, please try again with the correct format '<player> wins'"
+const BadPlayerWinInputErrMsg = "Bad value received for registering a player win, please try again with the correct format '<player> wins' or '<player> wins over <player>, <player>'"
+const RecordWinErrMsg = "Could not record the win"
+
+const ReplPrompt = "> "
+const UnknownCommandErrMsg = "Unknown command, type help to see what you can do"
+const GameRunningErrMsg = "A game is already running, record its winner or undo it first"
+const NoGameErrMsg = "No game is running, type start <number of players> to start one"
+const NoStoreErrMsg = "No scores are kept in this session"
+const NothingToUndoMsg = "Nothing to undo"
+const ExportErrMsg = "Could not export the league"
+const BadExportInputErrMsg = "Bad value received for exporting the league, please try again with the correct format 'export <json|csv|html|text> [file]'"
+const NoAuditErrMsg = "No audit log is kept in this session"
+const BadRenameInputErrMsg = "Bad value received for renaming a player, please try again with the correct format 'rename <player> to <player>'"
+const NoDealerErrMsg = "This game doesn't deal cards"
+const BadDealInputErrMsg = "Bad value received for dealing a hand, please try again with the correct format 'deal <player>:<chips> <player>:<chips> ...'"
+const ReplHelp = `Commands:
+  start <n>                          start a game for n players
+  <name> wins                        record the winner of the game
+  <name> wins over <name>, <name>    record where everyone finished
+  pause, resume, next, previous      control the clock
+  deal <name>:<chips> ...            deal a hand at the current blinds
+  <name> folds, checks or calls      act in the hand
+  <name> bets <n> or raises to <n>   bet or raise in the hand
+  <name> goes all in                 bet every chip
+  cards <name>                       show a player's hole cards
+  finish                             record the game in order of everyone's chips
+  time                               show the time left on this level
+  league                             show the league
+  export <format> [file]             export the league as json, csv, html or text
+  score <name>                       show a player's wins
+  stats <name>                       show how a player has done in their games
+  undo                               cancel the running game or remove the last one recorded
+  rename <name> to <name>            give a player's history to another name
+  audit                              show every change made
+  reverse <entry>                    undo the change of an audit entry
+  help                               show this help
+  quit                               leave
+`
 
 type CLI struct {
 	playerStore PlayerStore
 	in          *bufio.Scanner
 	out         io.Writer
 	game        Game
+
+	cancelGame func()
+	recorded   []int
 }
 
 func NewCLI(in io.Reader, out io.Writer, game Game) *CLI {
+	return NewCLIWithStore(in, out, game, nil)
+}
+
+// NewCLIWithStore makes a CLI that can also show the league and scores from
+// store, and undo games recorded in it.
+func NewCLIWithStore(in io.Reader, out io.Writer, game Game, store PlayerStore) *CLI {
 	return &CLI{
-		in:   bufio.NewScanner(in),
-		out:  out,
-		game: game,
+		playerStore: store,
+		in:          bufio.NewScanner(in),
+		out:         out,
+		game:        game,
+	}
+}
+
+// Run reads commands until quit or the end of the input, so several games can
+// be played in one session.
+func (cli *CLI) Run() {
+	defer cli.stopGame()
+
+	for {
+		fmt.Fprint(cli.out, ReplPrompt)
+
+		if !cli.in.Scan() {
+			return
+		}
+
+		input := strings.TrimSpace(cli.in.Text())
+		command, argument := input, ""
+		if space := strings.Index(input, " "); space != -1 {
+			command, argument = input[:space], strings.TrimSpace(input[space+1:])
+		}
+
+		switch {
+		case input == "":
+		case input == "quit":
+			return
+		case input == "help":
+			fmt.Fprint(cli.out, ReplHelp)
+		case input == "league":
+			cli.showLeague()
+		case command == "export":
+			cli.export(argument)
+		case input == "undo":
+			cli.undo()
+		case strings.Contains(input, " wins"):
+			cli.finish(input)
+		case command == "start":
+			cli.start(argument)
+		case command == "score":
+			cli.showScore(argument)
+		case command == "stats":
+			cli.showStats(argument)
+		case command == "rename":
+			cli.rename(argument)
+		case input == "audit":
+			cli.showAudit()
+		case command == "reverse":
+			cli.reverse(argument)
+		case command == "deal":
+			cli.deal(argument)
+		case command == "cards":
+			cli.showCards(argument)
+		case input == "finish":
+			cli.finishFromTable()
+		case cli.cancelGame != nil && cli.act(input):
+		case cli.cancelGame != nil && runClockCommand(cli.game, input, cli.out):
+		default:
+			fmt.Fprintln(cli.out, UnknownCommandErrMsg)
+		}
+	}
+}
+
+func (cli *CLI) start(numberOfPlayersInput string) {
+	if cli.cancelGame != nil {
+		fmt.Fprintln(cli.out, GameRunningErrMsg)
+		return
+	}
+
+	numberOfPlayers, err := strconv.Atoi(numberOfPlayersInput)
+	if err != nil || numberOfPlayers < 1 {
+		fmt.Fprintln(cli.out, BadPlayerInputErrMsg)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cli.cancelGame = cancel
+	cli.game.Start(ctx, numberOfPlayers, cli.out)
+}
+
+func (cli *CLI) finish(input string) {
+	if cli.cancelGame == nil {
+		fmt.Fprintln(cli.out, NoGameErrMsg)
+		return
+	}
+
+	winner, runnersUp, err := extractResult(input)
+	if err != nil {
+		fmt.Fprintln(cli.out, err)
+		return
+	}
+
+	cli.record(winner, runnersUp...)
+}
+
+func (cli *CLI) record(winner string, runnersUp ...string) {
+	game, err := cli.game.Finish(winner, runnersUp...)
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", RecordWinErrMsg, err)
+		return
+	}
+	cli.stopGame()
+
+	fmt.Fprintf(cli.out, "Recorded the win for %s\n", winner)
+	if cli.playerStore != nil {
+		cli.recorded = append(cli.recorded, game.ID)
+	}
+}
+
+// undo cancels the game that's running, or when there isn't one removes the
+// last game recorded in this session.
+func (cli *CLI) undo() {
+	if cli.cancelGame != nil {
+		cli.stopGame()
+		fmt.Fprintln(cli.out, "Cancelled the running game")
+		return
+	}
+
+	if len(cli.recorded) == 0 {
+		fmt.Fprintln(cli.out, NothingToUndoMsg)
+		return
+	}
+
+	id := cli.recorded[len(cli.recorded)-1]
+	if err := cli.playerStore.DeleteGame(id); err != nil {
+		fmt.Fprintf(cli.out, "Could not undo game %d, %v\n", id, err)
+		return
+	}
+
+	cli.recorded = cli.recorded[:len(cli.recorded)-1]
+	fmt.Fprintf(cli.out, "Removed game %d\n", id)
+}
+
+func (cli *CLI) showLeague() {
+	if cli.playerStore == nil {
+		fmt.Fprintln(cli.out, NoStoreErrMsg)
+		return
+	}
+
+	league, err := RatedLeague(cli.playerStore, DefaultRatingSystem)
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", LeagueErrMsg, err)
+		return
+	}
+
+	(&LeagueExporter{}).Export(cli.out, TextFormat, league)
+}
+
+// export writes the league in a format, to a file when one is named.
+func (cli *CLI) export(input string) {
+	if cli.playerStore == nil {
+		fmt.Fprintln(cli.out, NoStoreErrMsg)
+		return
+	}
+
+	args := strings.Fields(input)
+	if len(args) == 0 || len(args) > 2 || ContentType(args[0]) == "" {
+		fmt.Fprintln(cli.out, BadExportInputErrMsg)
+		return
+	}
+	format := args[0]
+
+	exporter := &LeagueExporter{}
+	if format == HTMLFormat {
+		var err error
+		if exporter, err = NewLeagueExporter(LeagueTemplatePath); err != nil {
+			fmt.Fprintf(cli.out, "%s, %v\n", ExportErrMsg, err)
+			return
+		}
+	}
+
+	league, err := RatedLeague(cli.playerStore, DefaultRatingSystem)
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", LeagueErrMsg, err)
+		return
+	}
+
+	if len(args) == 1 {
+		if err := exporter.Export(cli.out, format, league); err != nil {
+			fmt.Fprintf(cli.out, "%s, %v\n", ExportErrMsg, err)
+		}
+		return
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", ExportErrMsg, err)
+		return
+	}
+	defer file.Close()
+
+	if err := exporter.Export(file, format, league); err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", ExportErrMsg, err)
+		return
+	}
+	fmt.Fprintf(cli.out, "Exported the league to %s\n", args[1])
+}
+
+func (cli *CLI) showScore(name string) {
+	if cli.playerStore == nil {
+		fmt.Fprintln(cli.out, NoStoreErrMsg)
+		return
+	}
+
+	if name == "" {
+		fmt.Fprintln(cli.out, UnknownCommandErrMsg)
+		return
+	}
+
+	score, err := cli.playerStore.GetPlayerScore(name)
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		fmt.Fprintf(cli.out, "%s hasn't won yet\n", name)
+	case err != nil:
+		fmt.Fprintf(cli.out, "%s, %v\n", ScoreErrMsg, err)
+	default:
+		fmt.Fprintf(cli.out, "%s has %d wins\n", name, score)
+	}
+}
+
+func (cli *CLI) showStats(name string) {
+	if cli.playerStore == nil {
+		fmt.Fprintln(cli.out, NoStoreErrMsg)
+		return
+	}
+
+	if name == "" {
+		fmt.Fprintln(cli.out, UnknownCommandErrMsg)
+		return
+	}
+
+	games, err := cli.playerStore.GetGames()
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", StatsErrMsg, err)
+		return
+	}
+
+	stats, err := StatsFor(name, games)
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s hasn't played a game yet\n", name)
+		return
+	}
+
+	fmt.Fprintf(cli.out, "%s has played %d games and won %d, %.0f%% of them\n", stats.Name, stats.GamesPlayed, stats.Wins, stats.WinRate*100)
+	fmt.Fprintf(cli.out, "Finishes %.2f on average, last played %s\n", stats.AveragePosition, stats.LastPlayed.Format("2006-01-02"))
+	fmt.Fprintf(cli.out, "On a %d game %s streak\n", stats.Streak.Length, stats.Streak.Type)
+	if stats.Winnings > 0 {
+		fmt.Fprintf(cli.out, "Won %d in prizes\n", stats.Winnings)
+	}
+	for _, record := range stats.HeadToHead {
+		fmt.Fprintf(cli.out, "Against %s: ahead %d, behind %d of %d\n", record.Opponent, record.Ahead, record.Behind, record.Games)
+	}
+}
+
+func (cli *CLI) rename(input string) {
+	if cli.playerStore == nil {
+		fmt.Fprintln(cli.out, NoStoreErrMsg)
+		return
+	}
+
+	names := strings.SplitN(input, " to ", 2)
+	if len(names) != 2 || strings.TrimSpace(names[0]) == "" || strings.TrimSpace(names[1]) == "" {
+		fmt.Fprintln(cli.out, BadRenameInputErrMsg)
+		return
+	}
+
+	from, to := strings.TrimSpace(names[0]), strings.TrimSpace(names[1])
+	if err := cli.playerStore.RenamePlayer(from, to); err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", RenameErrMsg, err)
+		return
+	}
+
+	fmt.Fprintf(cli.out, "Renamed %s to %s\n", from, to)
+}
+
+func (cli *CLI) showAudit() {
+	audited, ok := cli.playerStore.(*AuditedPlayerStore)
+	if !ok {
+		fmt.Fprintln(cli.out, NoAuditErrMsg)
+		return
+	}
+
+	for _, entry := range audited.AuditLog().Entries() {
+		fmt.Fprintln(cli.out, entry)
+	}
+}
+
+func (cli *CLI) reverse(input string) {
+	audited, ok := cli.playerStore.(*AuditedPlayerStore)
+	if !ok {
+		fmt.Fprintln(cli.out, NoAuditErrMsg)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(input, "#"))
+	if err != nil {
+		fmt.Fprintln(cli.out, UnknownCommandErrMsg)
+		return
+	}
+
+	entry, err := audited.Reverse(id)
+	if err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", ReverseErrMsg, err)
+		return
+	}
+
+	fmt.Fprintln(cli.out, entry)
+}
+
+// dealer is the game when it deals cards, reporting why not when it can't.
+func (cli *CLI) dealer() (Dealer, bool) {
+	if cli.cancelGame == nil {
+		fmt.Fprintln(cli.out, NoGameErrMsg)
+		return nil, false
+	}
+
+	dealer, ok := cli.game.(Dealer)
+	if !ok {
+		fmt.Fprintln(cli.out, NoDealerErrMsg)
+	}
+	return dealer, ok
+}
+
+func (cli *CLI) deal(input string) {
+	dealer, ok := cli.dealer()
+	if !ok {
+		return
+	}
+
+	seats, err := parseSeats(input)
+	if err != nil || len(seats) == 0 {
+		fmt.Fprintln(cli.out, BadDealInputErrMsg)
+		return
+	}
+
+	cli.writeHand(dealer.Deal(seats))
+}
+
+// act plays a player's turn in the hand. It returns false when input is not
+// an action.
+func (cli *CLI) act(input string) bool {
+	name, action, ok := parseHandAction(input)
+	if !ok {
+		return false
+	}
+
+	if dealer, ok := cli.dealer(); ok {
+		cli.writeHand(dealer.Act(name, action))
+	}
+	return true
+}
+
+func (cli *CLI) showCards(name string) {
+	dealer, ok := cli.dealer()
+	if !ok {
+		return
+	}
+
+	cards, err := dealer.HoleCards(name)
+	if err != nil {
+		fmt.Fprintln(cli.out, err)
+		return
+	}
+	fmt.Fprintf(cli.out, "%s has %s\n", name, joinCards(cards))
+}
+
+// finishFromTable records the game in the order of the players' chips after
+// the last hand.
+func (cli *CLI) finishFromTable() {
+	dealer, ok := cli.dealer()
+	if !ok {
+		return
+	}
+
+	standings, err := dealer.Standings()
+	if err != nil {
+		fmt.Fprintln(cli.out, err)
+		return
+	}
+
+	cli.record(standings[0], standings[1:]...)
+}
+
+func (cli *CLI) writeHand(state holdem.State, err error) {
+	if err != nil {
+		fmt.Fprintln(cli.out, err)
+		return
+	}
+	WriteHand(cli.out, state)
+}
+
+func (cli *CLI) stopGame() {
+	if cli.cancelGame != nil {
+		cli.cancelGame()
+		cli.cancelGame = nil
 	}
 }
 
@@ -36,25 +481,53 @@ func (cli *CLI) PlayPoker() {
 		fmt.Fprint(cli.out, BadPlayerInputErrMsg)
 		return
 	}
-	cli.game.Start(numberOfPlayers, cli.out)
+	cli.game.Start(context.Background(), numberOfPlayers, cli.out)
 
-	winnerInput := cli.readLine()
-	winner, err := extractWinner(winnerInput)
+	input := cli.readLine()
+	for runClockCommand(cli.game, input, cli.out) {
+		input = cli.readLine()
+	}
+
+	winner, runnersUp, err := extractResult(input)
 	if err != nil {
 		fmt.Fprint(cli.out, err)
 		return
 	}
 
-	cli.game.Finish(winner)
-
+	if _, err := cli.game.Finish(winner, runnersUp...); err != nil {
+		fmt.Fprintf(cli.out, "%s, %v\n", RecordWinErrMsg, err)
+	}
 }
 
-func extractWinner(userInput string) (string, error) {
-	results := strings.Index(userInput, " wins")
-	if results == -1 {
-		return "", fmt.Errorf(BadPlayerWinInputErrMsg)
+// extractResult reads "<winner> wins", optionally followed by
+// " over <second>, <third>, ..." for the rest of the finishing order.
+func extractResult(userInput string) (string, []string, error) {
+	wins := strings.Index(userInput, " wins")
+	if wins == -1 {
+		return "", nil, fmt.Errorf(BadPlayerWinInputErrMsg)
+	}
+
+	winner := userInput[:wins]
+	rest := strings.TrimSpace(userInput[wins+len(" wins"):])
+
+	if rest == "" {
+		return winner, nil, nil
 	}
-	return strings.Replace(userInput, " wins", "", 1), nil
+
+	if !strings.HasPrefix(rest, "over ") {
+		return "", nil, fmt.Errorf(BadPlayerWinInputErrMsg)
+	}
+
+	var runnersUp []string
+	for _, name := range strings.Split(strings.TrimPrefix(rest, "over "), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return "", nil, fmt.Errorf(BadPlayerWinInputErrMsg)
+		}
+		runnersUp = append(runnersUp, name)
+	}
+
+	return winner, runnersUp, nil
 }
 
 func (cli *CLI) readLine() string {
@@ -2,7 +2,15 @@ package poker_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/handeval"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -54,6 +62,82 @@ func TestCLI(t *testing.T) {
 		poker.AssertMessagesSentToUser(t, stdout, poker.PlayerPrompt, poker.BadPlayerInputErrMsg)
 	})
 
+	t.Run("it records the finishing order after the winner", func(t *testing.T) {
+		game := &poker.GameSpy{}
+
+		in := userSends("5", "Chris wins over Cleo, Ruth")
+		cli := poker.NewCLI(in, dummyStdOut, game)
+
+		cli.PlayPoker()
+
+		poker.AssertFinishCalledWith(t, game, "Chris")
+		poker.AssertFinishRunnersUp(t, game, "Cleo", "Ruth")
+	})
+
+	t.Run("it prints an error when the finishing order can't be read", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("5", "Chris wins against Cleo")
+		cli := poker.NewCLI(in, stdout, game)
+
+		cli.PlayPoker()
+
+		poker.AssertMessagesSentToUser(t, stdout, poker.PlayerPrompt, poker.BadPlayerWinInputErrMsg)
+	})
+
+	t.Run("it controls the clock until a winner is declared", func(t *testing.T) {
+		game := &poker.GameSpy{}
+
+		in := userSends("5", "pause", "resume", "next", "previous", "Chris wins")
+		cli := poker.NewCLI(in, dummyStdOut, game)
+
+		cli.PlayPoker()
+
+		poker.AssertClockCalls(t, game, "pause", "resume", "next", "previous")
+		poker.AssertFinishCalledWith(t, game, "Chris")
+	})
+
+	t.Run("it prints the time remaining on the clock", func(t *testing.T) {
+		game := &poker.GameSpy{ClockState: poker.ClockState{
+			Blind:     poker.Blind{Level: 2, Amount: 200, NextLevelIn: 10 * time.Minute},
+			Remaining: 4 * time.Minute,
+		}}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("5", "time", "Chris wins")
+		cli := poker.NewCLI(in, stdout, game)
+
+		cli.PlayPoker()
+
+		poker.AssertMessagesSentToUser(t, stdout, poker.PlayerPrompt, "Level 2 with 4m0s until the next level\n")
+	})
+
+	t.Run("it prints clock errors and carries on", func(t *testing.T) {
+		game := &poker.GameSpy{ClockError: poker.ErrClockPaused}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("5", "pause", "Chris wins")
+		cli := poker.NewCLI(in, stdout, game)
+
+		cli.PlayPoker()
+
+		poker.AssertMessagesSentToUser(t, stdout, poker.PlayerPrompt, poker.ErrClockPaused.Error()+"\n")
+		poker.AssertFinishCalledWith(t, game, "Chris")
+	})
+
+	t.Run("it prints an error when the win can't be recorded", func(t *testing.T) {
+		game := &poker.GameSpy{FinishError: errors.New("disk full")}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("5", "Chris wins")
+		cli := poker.NewCLI(in, stdout, game)
+
+		cli.PlayPoker()
+
+		poker.AssertMessagesSentToUser(t, stdout, poker.PlayerPrompt, poker.RecordWinErrMsg+", disk full\n")
+	})
+
 	t.Run("it prints an error when we don't send wins after the user name", func(t *testing.T) {
 		game := &poker.GameSpy{}
 
@@ -68,12 +152,253 @@ func TestCLI(t *testing.T) {
 	})
 }
 
+func TestCLI_Run(t *testing.T) {
+	t.Run("it plays several games in one session", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("start 3", "Chris wins", "start 5", "Cleo wins over Chris", "quit", "start 7")
+		poker.NewCLI(in, stdout, game).Run()
+
+		poker.AssertGameStartedWith(t, game, 5)
+		poker.AssertFinishCalledWith(t, game, "Cleo")
+		poker.AssertFinishRunnersUp(t, game, "Chris")
+		assertOutputContains(t, stdout, "Recorded the win for Chris\n", "Recorded the win for Cleo\n")
+	})
+
+	t.Run("it carries on after bad input", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("start pies", "Chris wins", "dance", "start 4", "start 4", "Loyd wins against Cleo", "Loyd wins")
+		poker.NewCLI(in, stdout, game).Run()
+
+		poker.AssertGameStartedWith(t, game, 4)
+		poker.AssertFinishCalledWith(t, game, "Loyd")
+		assertOutputContains(t, stdout,
+			poker.BadPlayerInputErrMsg,
+			poker.NoGameErrMsg,
+			poker.UnknownCommandErrMsg,
+			poker.GameRunningErrMsg,
+			poker.BadPlayerWinInputErrMsg,
+		)
+	})
+
+	t.Run("it controls the clock of the running game", func(t *testing.T) {
+		game := &poker.GameSpy{}
+
+		in := userSends("pause", "start 5", "pause", "next", "Chris wins")
+		poker.NewCLI(in, dummyStdOut, game).Run()
+
+		poker.AssertClockCalls(t, game, "pause", "next")
+	})
+
+	t.Run("it deals hands and takes the players' turns", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		stdout := &bytes.Buffer{}
+
+		in := userSends("deal Cleo:1000 Chris:1000", "start 2", "deal Cleo:1000 Chris:1000", "Chris checks", "Cleo raises to 300", "Chris folds", "finish")
+		poker.NewCLI(in, stdout, game).Run()
+
+		assertOutputContains(t, stdout,
+			poker.NoGameErrMsg,
+			"Preflop, pot 150\nCleo to act, 50 to call\n",
+			"it isn't that player's turn",
+			"Chris to act, 200 to call",
+			"Cleo wins 400\nStacks: Cleo 1100, Chris 900\n",
+			"Recorded the win for Cleo\n",
+		)
+	})
+
+	t.Run("it won't seat a player twice", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLI(userSends("start 2", "deal Cleo:1000 Cleo:500", "Cleo folds"), stdout, game).Run()
+
+		assertOutputContains(t, stdout, poker.BadDealInputErrMsg)
+		if strings.Contains(stdout.String(), "Preflop") {
+			t.Errorf("didn't expect a hand to be dealt, got %q", stdout.String())
+		}
+	})
+
+	t.Run("it needs a game that deals cards", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLI(userSends("start 2", "deal Cleo:1000 Chris:1000", "Cleo folds"), stdout, &poker.GameSpy{}).Run()
+
+		assertOutputContains(t, stdout, poker.NoDealerErrMsg)
+	})
+
+	t.Run("it cancels the alerts of the game when quitting", func(t *testing.T) {
+		game := &poker.GameSpy{}
+
+		poker.NewCLI(userSends("start 5", "quit"), dummyStdOut, game).Run()
+
+		poker.AssertStartContextCancelled(t, game)
+	})
+
+	t.Run("it shows the league and scores", func(t *testing.T) {
+		store := &poker.StubPlayerStore{
+			Scores: map[string]int{"Cleo": 3},
+			League: poker.League{{Name: "Cleo", Wins: 3}, {Name: "Chris", Wins: 1}},
+		}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("league", "score Cleo", "score Ruth")
+		poker.NewCLIWithStore(in, stdout, &poker.GameSpy{}, store).Run()
+
+		assertOutputContains(t, stdout,
+			"1. Cleo, 3 wins, rated 1500\n2. Chris, 1 wins, rated 1500\n",
+			"Cleo has 3 wins\n",
+			"Ruth hasn't won yet\n",
+		)
+	})
+
+	t.Run("it exports the league", func(t *testing.T) {
+		store := &poker.StubPlayerStore{League: poker.League{{Name: "Cleo", Wins: 3}}}
+		stdout := &bytes.Buffer{}
+
+		path := filepath.Join(t.TempDir(), "league.csv")
+		in := userSends("export csv", "export xml", "export csv "+path)
+		poker.NewCLIWithStore(in, stdout, &poker.GameSpy{}, store).Run()
+
+		csv := "position,name,wins,rating\n1,Cleo,3,1500\n"
+		assertOutputContains(t, stdout, csv, poker.BadExportInputErrMsg, "Exported the league to "+path+"\n")
+
+		assertFileContents(t, path, csv)
+	})
+
+	t.Run("it shows a player's stats", func(t *testing.T) {
+		store := &poker.StubPlayerStore{Games: []poker.GameRecord{
+			{ID: 1, StartedAt: gameTime, Players: []string{"Cleo", "Chris"}},
+		}}
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLIWithStore(userSends("stats Cleo", "stats Ruth"), stdout, &poker.GameSpy{}, store).Run()
+
+		assertOutputContains(t, stdout,
+			"Cleo has played 1 games and won 1, 100% of them\n",
+			"Finishes 1.00 on average, last played 2019-03-01\n",
+			"On a 1 game win streak\n",
+			"Against Chris: ahead 1, behind 0 of 1\n",
+			"Ruth hasn't played a game yet\n",
+		)
+	})
+
+	t.Run("it needs a store for the league", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLI(userSends("league"), stdout, &poker.GameSpy{}).Run()
+
+		assertOutputContains(t, stdout, poker.NoStoreErrMsg)
+	})
+
+	t.Run("undo cancels the running game", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLI(userSends("start 5", "undo", "Chris wins", "undo"), stdout, game).Run()
+
+		poker.AssertStartContextCancelled(t, game)
+		if game.FinishedCalled {
+			t.Error("didn't expect the cancelled game to finish")
+		}
+		assertOutputContains(t, stdout, "Cancelled the running game\n", poker.NoGameErrMsg, poker.NothingToUndoMsg)
+	})
+
+	t.Run("undo removes the games recorded in the session", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+
+		in := userSends("start 2", "Chris wins", "start 2", "Cleo wins", "undo", "league")
+		stdout := &bytes.Buffer{}
+		poker.NewCLIWithStore(in, stdout, game, store).Run()
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chris", Wins: 1}})
+		assertOutputContains(t, stdout, "Removed game 2\n")
+	})
+
+	t.Run("the game keeps running until its win is recorded", func(t *testing.T) {
+		store := &failOnceStore{StubPlayerStore: &poker.StubPlayerStore{}, err: errors.New("disk full")}
+		blindAlerter := &poker.SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, store)
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLIWithStore(userSends("start 2", "Chris wins", "Chris wins"), stdout, game, store).Run()
+
+		assertOutputContains(t, stdout, poker.RecordWinErrMsg+", disk full\n", "Recorded the win for Chris\n")
+		if strings.Contains(stdout.String(), poker.NoGameErrMsg) {
+			t.Errorf("didn't expect the game to have stopped, got %q", stdout.String())
+		}
+		if len(store.GameCalls) != 1 {
+			t.Errorf("got %d calls to RecordGame want 1", len(store.GameCalls))
+		}
+		poker.AssertAlertsCancelled(t, blindAlerter)
+	})
+
+	t.Run("undo removes the game this session recorded, not the latest one", func(t *testing.T) {
+		store := &poker.StubPlayerStore{Games: []poker.GameRecord{
+			{ID: 7, Players: []string{"Chris"}},
+			{ID: 8, Players: []string{"Ruth"}},
+		}}
+		game := &poker.GameSpy{FinishRecord: poker.GameRecord{ID: 7}}
+
+		in := userSends("start 2", "Chris wins", "undo")
+		stdout := &bytes.Buffer{}
+		poker.NewCLIWithStore(in, stdout, game, store).Run()
+
+		if !reflect.DeepEqual(store.Deleted, []int{7}) {
+			t.Errorf("got games %v deleted, want only 7", store.Deleted)
+		}
+		assertOutputContains(t, stdout, "Removed game 7\n")
+	})
+
+	t.Run("it renames players and reverses changes", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+		stdout := &bytes.Buffer{}
+
+		in := userSends("rename Chirs", "rename Chirs to Chris", "audit", "reverse 2", "reverse 2")
+		poker.NewCLIWithStore(in, stdout, &poker.GameSpy{}, store).Run()
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chirs", Wins: 1}})
+		assertOutputContains(t, stdout,
+			poker.BadRenameInputErrMsg,
+			"Renamed Chirs to Chris\n",
+			"cleo (cli) renamed Chirs to Chris\n",
+			"cleo (cli) reversed #2\n",
+			poker.ReverseErrMsg+", "+poker.ErrAlreadyReversed.Error(),
+		)
+	})
+
+	t.Run("it needs an audited store to reverse changes", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLIWithStore(userSends("reverse 1"), stdout, &poker.GameSpy{}, &poker.StubPlayerStore{}).Run()
+
+		assertOutputContains(t, stdout, poker.NoAuditErrMsg)
+	})
+
+	t.Run("it prints help", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		poker.NewCLI(userSends("help"), stdout, &poker.GameSpy{}).Run()
+
+		poker.AssertMessagesSentToUser(t, stdout, poker.ReplPrompt, poker.ReplHelp, poker.ReplPrompt)
+	})
+}
+
 func TestGame_Start(t *testing.T) {
 	t.Run("schedules alerts on game start for 5 players", func(t *testing.T) {
 		blindAlerter := &poker.SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
 
-		game.Start(5, dummyStdOut)
+		game.Start(context.Background(), 5, dummyStdOut)
 
 		cases := []poker.ScheduledAlert{
 			{At: 0 * time.Second, Amount: 100},
@@ -96,7 +421,7 @@ func TestGame_Start(t *testing.T) {
 		blindAlerter := &poker.SpyBlindAlerter{}
 		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
 
-		game.Start(7, dummyStdOut)
+		game.Start(context.Background(), 7, dummyStdOut)
 
 		cases := []poker.ScheduledAlert{
 			{At: 0 * time.Second, Amount: 100},
@@ -115,15 +440,474 @@ func TestGame_Start(t *testing.T) {
 		poker.CheckSchedulingCases(t, blindAlerter.Alerts, cases)
 	})
 
+	t.Run("numbers the levels and says when the next one starts", func(t *testing.T) {
+		blindAlerter := &poker.SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+
+		first := blindAlerter.Blinds[0]
+		poker.AssertBlind(t, first, poker.Blind{Level: 1, Amount: 100, NextLevelIn: 10 * time.Minute})
+
+		last := blindAlerter.Blinds[len(blindAlerter.Blinds)-1]
+		poker.AssertBlind(t, last, poker.Blind{Level: 11, Amount: 8000, NextLevelIn: 0})
+	})
+
+	t.Run("schedules alerts from the chosen blind structure", func(t *testing.T) {
+		blindAlerter := &poker.SpyBlindAlerter{}
+		turbo := poker.BlindStructure{
+			Name: "turbo",
+			Levels: []poker.BlindLevel{
+				{Blind: 100, Minutes: 5},
+				{Break: true, Minutes: 10},
+				{Blind: 400, Ante: 50, Minutes: 5},
+			},
+		}
+		game := poker.NewTexasHoldemWithBlinds(blindAlerter, dummyPlayerStore, turbo)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+
+		cases := []poker.ScheduledAlert{
+			{At: 0 * time.Second, Amount: 100},
+			{At: 5 * time.Minute, Amount: 0},
+			{At: 15 * time.Minute, Amount: 400},
+		}
+
+		poker.CheckSchedulingCases(t, blindAlerter.Alerts, cases)
+	})
 }
 
 func TestGame_Finish(t *testing.T) {
-	store := &poker.StubPlayerStore{}
-	game := poker.NewTexasHoldem(dummyBlindAlerter, store)
-	winner := "Ruth"
+	t.Run("records the winner", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+		winner := "Ruth"
+
+		game.Start(context.Background(), 2, dummyStdOut)
+		game.Finish(winner)
+		poker.AssertPlayerWin(t, store, winner)
+	})
+
+	t.Run("needs a game in progress", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+
+		_, err := game.Finish("Ruth")
+		poker.AssertError(t, err, poker.ErrGameNotStarted)
+
+		game.Start(context.Background(), 2, dummyStdOut)
+		_, err = game.Finish("Ruth")
+		poker.AssertNoError(t, err)
+
+		_, err = game.Finish("Ruth")
+		poker.AssertError(t, err, poker.ErrGameNotStarted)
+		if len(store.GameCalls) != 1 {
+			t.Errorf("got %d calls to RecordGame want 1", len(store.GameCalls))
+		}
+	})
+
+	t.Run("records the game with everyone's finishing position", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		turbo := poker.BlindStructure{Name: "turbo", Levels: []poker.BlindLevel{{Blind: 100, Minutes: 5}}}
+		game := poker.NewTexasHoldemWithBlinds(dummyBlindAlerter, store, turbo)
+
+		game.Start(context.Background(), 4, dummyStdOut)
+		_, err := game.Finish("Ruth", "Chris", "Cleo")
+		poker.AssertNoError(t, err)
+
+		if len(store.GameCalls) != 1 {
+			t.Fatalf("got %d calls to RecordGame want 1", len(store.GameCalls))
+		}
+
+		got := store.GameCalls[0]
+		want := poker.GameRecord{
+			StartedAt:       got.StartedAt,
+			Duration:        got.Duration,
+			NumberOfPlayers: 4,
+			Players:         []string{"Ruth", "Chris", "Cleo"},
+			BlindStructure:  "turbo",
+		}
+		poker.AssertGameRecord(t, got, want)
+
+		if got.StartedAt.IsZero() {
+			t.Error("expected the game to have a start time")
+		}
+	})
+
+	t.Run("records the game in the league it is played in", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+		game.PlayIn("Friday cash", "spring")
+
+		game.Start(context.Background(), 2, dummyStdOut)
+		_, err := game.Finish("Ruth", "Chris")
+		poker.AssertNoError(t, err)
+
+		got := store.GameCalls[0]
+		if got.League != "Friday cash" || got.Season != "spring" {
+			t.Errorf("got league %q season %q want %q and %q", got.League, got.Season, "Friday cash", "spring")
+		}
+	})
+
+	t.Run("returns the error when the win can't be recorded", func(t *testing.T) {
+		storeErr := errors.New("disk full")
+		store := &poker.StubPlayerStore{WinError: storeErr}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+
+		game.Start(context.Background(), 2, dummyStdOut)
+		_, err := game.Finish("Ruth")
+		poker.AssertError(t, err, storeErr)
+	})
+
+	t.Run("keeps the game going when it can't be recorded", func(t *testing.T) {
+		storeErr := errors.New("disk full")
+		blindAlerter := &poker.SpyBlindAlerter{}
+		store := &poker.StubPlayerStore{WinError: storeErr}
+		game := poker.NewTexasHoldem(blindAlerter, store)
+
+		game.Start(context.Background(), 4, dummyStdOut)
+		_, err := game.Finish("Ruth", "Chris")
+		poker.AssertError(t, err, storeErr)
+
+		if _, err := game.Status(); err != nil {
+			t.Fatalf("want the game still in progress, got %v", err)
+		}
+		poker.AssertAlertsNotCancelled(t, blindAlerter)
+
+		store.WinError = nil
+		_, err = game.Finish("Ruth", "Chris")
+		poker.AssertNoError(t, err)
+
+		if len(store.GameCalls) != 1 {
+			t.Fatalf("got %d calls to RecordGame want 1", len(store.GameCalls))
+		}
+		if got := store.GameCalls[0]; got.NumberOfPlayers != 4 || got.Winner() != "Ruth" {
+			t.Errorf("got %+v, want Ruth's win in the game of 4", got)
+		}
+		poker.AssertAlertsCancelled(t, blindAlerter)
+	})
+
+	t.Run("cancels the pending blind alerts", func(t *testing.T) {
+		blindAlerter := &poker.SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+		poker.AssertAlertsNotCancelled(t, blindAlerter)
+
+		game.Finish("Ruth")
+		poker.AssertAlertsCancelled(t, blindAlerter)
+	})
+}
+
+func TestGame_Snapshot(t *testing.T) {
+	t.Run("needs a game in progress", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+
+		_, err := game.Snapshot()
+		poker.AssertError(t, err, poker.ErrGameNotStarted)
+	})
+
+	t.Run("a restored game carries on from where it got to", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+		game.Start(context.Background(), 5, dummyStdOut)
+		poker.AssertNoError(t, game.NextLevel())
+
+		snapshot, err := game.Snapshot()
+		poker.AssertNoError(t, err)
+
+		restored := poker.NewTexasHoldem(dummyBlindAlerter, store)
+		poker.AssertNoError(t, restored.Restore(context.Background(), snapshot, dummyStdOut))
+
+		status, err := restored.Status()
+		poker.AssertNoError(t, err)
+		if status.Blind.Level != 2 {
+			t.Errorf("got level %d want 2", status.Blind.Level)
+		}
+
+		_, err = restored.Finish("Ruth")
+		poker.AssertNoError(t, err)
+		if len(store.GameCalls) != 1 {
+			t.Fatalf("got %d calls to RecordGame want 1", len(store.GameCalls))
+		}
+		got := store.GameCalls[0]
+		if !got.StartedAt.Equal(snapshot.StartedAt) || got.NumberOfPlayers != 5 {
+			t.Errorf("got %+v, want the game started at %v with 5 players", got, snapshot.StartedAt)
+		}
+	})
+
+	t.Run("a hand in play is dealt again from the same button", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		game.Start(context.Background(), 3, dummyStdOut)
+
+		seats := []holdem.Seat{{Name: "Cleo", Stack: 1000}, {Name: "Chris", Stack: 1000}, {Name: "Ruth", Stack: 1000}}
+		state, err := game.Deal(seats)
+		poker.AssertNoError(t, err)
+
+		snapshot, err := game.Snapshot()
+		poker.AssertNoError(t, err)
+
+		restored := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		poker.AssertNoError(t, restored.Restore(context.Background(), snapshot, dummyStdOut))
+
+		again, err := restored.Deal(nil)
+		poker.AssertNoError(t, err)
+		if again.Button != state.Button || !reflect.DeepEqual(again.Seats(), state.Seats()) {
+			t.Errorf("got button %s and %v want button %s and %v", again.Button, again.Seats(), state.Button, state.Seats())
+		}
+	})
+}
+
+func TestGame_Clock(t *testing.T) {
+	t.Run("clock controls need a game in progress", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+
+		poker.AssertError(t, game.Pause(), poker.ErrGameNotStarted)
+		poker.AssertError(t, game.Resume(), poker.ErrGameNotStarted)
+		poker.AssertError(t, game.NextLevel(), poker.ErrGameNotStarted)
+		poker.AssertError(t, game.PreviousLevel(), poker.ErrGameNotStarted)
+		_, err := game.Status()
+		poker.AssertError(t, err, poker.ErrGameNotStarted)
+	})
+
+	t.Run("pausing the game pauses its clock", func(t *testing.T) {
+		blindAlerter := &poker.SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+		poker.AssertNoError(t, game.Pause())
+
+		poker.AssertAlertsCancelled(t, blindAlerter)
+		state, err := game.Status()
+		poker.AssertNoError(t, err)
+		if !state.Paused {
+			t.Error("expected the clock to be paused")
+		}
+	})
+
+	t.Run("the clock stops when the game finishes", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+		game.Finish("Ruth")
+
+		poker.AssertError(t, game.Pause(), poker.ErrGameNotStarted)
+	})
+}
+
+func TestGame_Deal(t *testing.T) {
+	seats := []holdem.Seat{{Name: "Cleo", Stack: 1000}, {Name: "Chris", Stack: 1000}, {Name: "Ruth", Stack: 1000}}
+
+	t.Run("deals a hand at the blinds of the current level", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		game.Start(context.Background(), 3, dummyStdOut)
+		poker.AssertNoError(t, game.NextLevel())
+
+		state, err := game.Deal(seats)
+		poker.AssertNoError(t, err)
+
+		if state.Blinds != (holdem.Blinds{Small: 100, Big: 200}) {
+			t.Errorf("got blinds %+v, want 100/200", state.Blinds)
+		}
+		if state.Button != "Cleo" || state.ToAct != "Cleo" {
+			t.Errorf("got button %q and %q to act, want Cleo for both", state.Button, state.ToAct)
+		}
+	})
+
+	t.Run("moves the button with every hand", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		game.Start(context.Background(), 3, dummyStdOut)
+
+		game.Deal(seats)
+		_, err := game.Deal(seats)
+		poker.AssertError(t, err, poker.ErrHandInPlay)
+
+		game.Act("Cleo", holdem.Action{Type: holdem.Fold})
+		state, err := game.Act("Chris", holdem.Action{Type: holdem.Fold})
+		poker.AssertNoError(t, err)
+
+		state, err = game.Deal(state.Seats())
+		poker.AssertNoError(t, err)
+		if state.Button != "Chris" {
+			t.Errorf("got the button in front of %q, want Chris", state.Button)
+		}
+	})
+
+	t.Run("deals the same cards for the same seed", func(t *testing.T) {
+		deal := func() []holdem.Card {
+			game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+			game.SetRand(rand.New(rand.NewSource(42)))
+			game.Start(context.Background(), 3, dummyStdOut)
+			game.Deal(seats)
+
+			cards, err := game.HoleCards("Ruth")
+			poker.AssertNoError(t, err)
+			return cards
+		}
+
+		if first, second := deal(), deal(); !reflect.DeepEqual(first, second) {
+			t.Errorf("got %v then %v from the same seed", first, second)
+		}
+	})
+
+	t.Run("gives the pot at a showdown to the best cards", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		game.SetRand(rand.New(rand.NewSource(3)))
+		game.Start(context.Background(), 2, dummyStdOut)
+
+		game.Deal(seats[:2])
+		game.Act("Cleo", holdem.Action{Type: holdem.AllIn})
+		state, err := game.Act("Chris", holdem.Action{Type: holdem.Call})
+		poker.AssertNoError(t, err)
+
+		if len(state.Winners) == 0 {
+			t.Fatal("expected the showdown to have a winner")
+		}
+
+		values := map[string]handeval.Value{}
+		for _, player := range state.Players {
+			values[player.Name], err = handeval.Evaluate(append(player.Cards, state.Board...))
+			poker.AssertNoError(t, err)
+		}
+		for _, winner := range state.Winners {
+			for name, value := range values {
+				if value > values[winner] {
+					t.Errorf("%s won with %v but %s had %v", winner, values[winner], name, value)
+				}
+			}
+		}
+	})
+
+	t.Run("finishing without a winner takes the order from the chips", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+		game.Start(context.Background(), 3, dummyStdOut)
+
+		_, err := game.Finish("")
+		poker.AssertError(t, err, poker.ErrNoHand)
 
-	game.Finish(winner)
-	poker.AssertPlayerWin(t, store, winner)
+		game.Deal(seats)
+		game.Act("Cleo", holdem.Action{Type: holdem.Fold})
+		game.Act("Chris", holdem.Action{Type: holdem.Fold})
+		_, err = game.Finish("")
+		poker.AssertNoError(t, err)
+
+		if len(store.GameCalls) != 1 || !reflect.DeepEqual(store.GameCalls[0].Players, []string{"Ruth", "Cleo", "Chris"}) {
+			t.Errorf("got games %v, want one finishing Ruth, Cleo, Chris", store.GameCalls)
+		}
+	})
+
+	t.Run("puts out the players who lose their chips and pays the places", func(t *testing.T) {
+		short := []holdem.Seat{{Name: "Cleo", Stack: 1000}, {Name: "Chris", Stack: 1000}, {Name: "Ruth", Stack: 200}}
+
+		for seed := int64(1); seed < 200; seed++ {
+			store := &poker.StubPlayerStore{}
+			game := poker.NewTexasHoldem(dummyBlindAlerter, store)
+			game.SetPayouts(poker.PayoutTable{BuyIn: 100, Places: []float64{70, 30}})
+			game.SetRand(rand.New(rand.NewSource(seed)))
+			game.Start(context.Background(), 3, dummyStdOut)
+
+			game.Deal(short)
+			game.Act("Cleo", holdem.Action{Type: holdem.AllIn})
+			game.Act("Chris", holdem.Action{Type: holdem.AllIn})
+			state, err := game.Act("Ruth", holdem.Action{Type: holdem.AllIn})
+			poker.AssertNoError(t, err)
+
+			if !reflect.DeepEqual(state.Winners, []string{"Cleo"}) {
+				continue
+			}
+
+			standings, err := game.Standings()
+			poker.AssertNoError(t, err)
+			if !reflect.DeepEqual(standings, []string{"Cleo", "Chris", "Ruth"}) {
+				t.Errorf("got standings %v, want Cleo, Chris then Ruth who started with fewer chips", standings)
+			}
+
+			_, err = game.Deal(nil)
+			poker.AssertError(t, err, holdem.ErrNotEnoughPlayers)
+
+			_, err = game.Finish("")
+			poker.AssertNoError(t, err)
+			want := poker.GameRecord{
+				StartedAt:       store.GameCalls[0].StartedAt,
+				Duration:        store.GameCalls[0].Duration,
+				NumberOfPlayers: 3,
+				Players:         []string{"Cleo", "Chris", "Ruth"},
+				Prizes:          []int{210, 90},
+				BlindStructure:  poker.DefaultBlindStructureName,
+			}
+			poker.AssertGameRecord(t, store.GameCalls[0], want)
+			return
+		}
+		t.Fatal("no seed dealt Cleo the best hand")
+	})
+
+	t.Run("deals the next hand to the players left", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+		game.Start(context.Background(), 3, dummyStdOut)
+
+		game.Deal(seats)
+		game.Act("Cleo", holdem.Action{Type: holdem.Fold})
+		game.Act("Chris", holdem.Action{Type: holdem.Fold})
+
+		state, err := game.Deal(nil)
+		poker.AssertNoError(t, err)
+
+		var got []int
+		for _, player := range state.Players {
+			got = append(got, player.Stack+player.Bet)
+		}
+		if !reflect.DeepEqual(got, []int{1000, 950, 1050}) {
+			t.Errorf("got stacks %v before the blinds, want the stacks after the last hand", got)
+		}
+	})
+
+	t.Run("hands need a game in progress", func(t *testing.T) {
+		game := poker.NewTexasHoldem(dummyBlindAlerter, dummyPlayerStore)
+
+		_, err := game.Deal(seats)
+		poker.AssertError(t, err, poker.ErrGameNotStarted)
+		_, err = game.Act("Cleo", holdem.Action{Type: holdem.Fold})
+		poker.AssertError(t, err, poker.ErrNoHand)
+	})
+}
+
+func TestGame_Cancellation(t *testing.T) {
+	t.Run("cancelling the start context cancels the blind alerts", func(t *testing.T) {
+		blindAlerter := &poker.SpyBlindAlerter{}
+		game := poker.NewTexasHoldem(blindAlerter, dummyPlayerStore)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		game.Start(ctx, 5, dummyStdOut)
+		cancel()
+
+		poker.AssertAlertsCancelled(t, blindAlerter)
+	})
+
+	t.Run("starting a new game cancels the previous game's alerts", func(t *testing.T) {
+		firstGame := &poker.SpyBlindAlerter{}
+		secondGame := &poker.SpyBlindAlerter{}
+		alerter := firstGame
+		game := poker.NewTexasHoldem(poker.BlindAlerterFunc(func(ctx context.Context, duration time.Duration, blind poker.Blind, to io.Writer) {
+			alerter.ScheduleAlertAt(ctx, duration, blind, to)
+		}), dummyPlayerStore)
+
+		game.Start(context.Background(), 5, dummyStdOut)
+		alerter = secondGame
+		game.Start(context.Background(), 5, dummyStdOut)
+
+		poker.AssertAlertsCancelled(t, firstGame)
+		poker.AssertAlertsNotCancelled(t, secondGame)
+	})
+}
+
+func assertOutputContains(t *testing.T, stdout *bytes.Buffer, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if !strings.Contains(stdout.String(), message) {
+			t.Errorf("expected %q in the output but got %q", message, stdout.String())
+		}
+	}
 }
 
 func userSends(messages ...string) *strings.Reader {
@@ -131,3 +915,18 @@ func userSends(messages ...string) *strings.Reader {
 	return strings.NewReader(message)
 
 }
+
+// failOnceStore fails to record the first game it's given.
+type failOnceStore struct {
+	*poker.StubPlayerStore
+	err    error
+	failed bool
+}
+
+func (s *failOnceStore) RecordGame(game poker.GameRecord) (poker.GameRecord, error) {
+	if !s.failed {
+		s.failed = true
+		return poker.GameRecord{}, s.err
+	}
+	return s.StubPlayerStore.RecordGame(game)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
 	"log"
@@ -8,19 +9,66 @@ import (
 )
 
 const dbFileName = "game.db.json"
+const logFileName = "game.db.log"
+const auditFileName = "game.db.audit"
+
+var blindsFile = flag.String("blinds", "", "JSON file with the blind structures to choose from")
+var blindStructure = flag.String("structure", poker.DefaultBlindStructureName, "name of the blind structure to play")
+var storeKind = flag.String("store", poker.JSONStore, "how wins are stored, json or log")
+var leagueName = flag.String("league", poker.DefaultLeagueName, "league the games count towards")
+var userName = flag.String("user", os.Getenv("USER"), "who the changes are made by in the audit log")
+var seasonName = flag.String("season", "", "season the games count towards, the current quarter if empty")
+var buyIn = flag.Int("buy-in", 0, "what each player pays into the prize pool, the structure's buy in if 0")
+var payoutPlaces = flag.String("payouts", "", "percentage of the prize pool for each place like 50,30,20, the structure's payouts if empty")
 
 func main() {
-	store, close, err := poker.FileSystemPlayerStoreFromFile(dbFileName)
+	flag.Parse()
+
+	blinds, err := poker.LoadBlindStructure(*blindsFile, *blindStructure)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	payouts := blinds.PayoutTable()
+	if *buyIn != 0 {
+		payouts.BuyIn = *buyIn
+	}
+	if *payoutPlaces != "" {
+		if payouts.Places, err = poker.ParsePayoutPlaces(*payoutPlaces); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := payouts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	path := dbFileName
+	if *storeKind == poker.EventLogStore {
+		path = logFileName
+	}
+
+	store, close, err := poker.PlayerStoreFromFile(*storeKind, path)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer close()
 
+	audit, closeAudit, err := poker.AuditLogFromFile(auditFileName)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeAudit()
+
 	fmt.Println("Let's play poker")
-	fmt.Println("Type {Name} wins to record a win")
-	game := poker.NewTexasHoldem(poker.BlindAlerterFunc(poker.Alerter), store)
-	cli := poker.NewCLI(os.Stdin, os.Stdout, game)
-	cli.PlayPoker()
+	fmt.Println("Type start {Number of players} to start a game or help to see everything you can do")
+	audited := poker.NewAuditedPlayerStore(store, audit, poker.Actor{Who: *userName, Via: poker.ViaCLI})
+	game := poker.NewTexasHoldemWithBlinds(poker.BlindAlerterFunc(poker.Alerter), audited, blinds)
+	game.PlayIn(*leagueName, *seasonName)
+	game.SetPayouts(payouts)
+	cli := poker.NewCLIWithStore(os.Stdin, os.Stdout, game, audited)
+	cli.Run()
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const dbFileName = "game.db.json"
+const logFileName = "game.db.log"
+const auditFileName = "game.db.audit"
+
+var storeKind = flag.String("store", poker.JSONStore, "how wins are stored, json or log")
+var format = flag.String("format", "", "csv or json, taken from the file name when empty")
+var userName = flag.String("user", os.Getenv("USER"), "who the changes are made by in the audit log")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file\n\nImports the games in file, or standard input when it's -.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	dbPath := dbFileName
+	if *storeKind == poker.EventLogStore {
+		dbPath = logFileName
+	}
+
+	store, close, err := poker.PlayerStoreFromFile(*storeKind, dbPath)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer close()
+
+	audit, closeAudit, err := poker.AuditLogFromFile(auditFileName)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeAudit()
+
+	audited := poker.NewAuditedPlayerStore(store, audit, poker.Actor{Who: *userName, Via: poker.ViaCLI})
+
+	report, err := poker.ImportGames(audited, in, *format)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(report.Errors) > 0 {
+		for _, rowErr := range report.Errors {
+			fmt.Fprintln(os.Stderr, rowErr)
+		}
+		fmt.Fprintln(os.Stderr, "Nothing was imported")
+		close()
+		closeAudit()
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %d games, skipped %d already imported\n", len(report.Imported), report.Skipped)
+}
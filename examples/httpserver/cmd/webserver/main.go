@@ -1,31 +1,111 @@
 package main
 
 import (
+	"flag"
 	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
 	"log"
 	"net/http"
+	"time"
 )
 
 const dbFileName = "game.db.json"
+const logFileName = "game.db.log"
+const auditFileName = "game.db.audit"
+const sessionsFileName = "game.db.sessions"
+
+var blindsFile = flag.String("blinds", "", "JSON file with the blind structures to choose from")
+var blindStructure = flag.String("structure", poker.DefaultBlindStructureName, "name of the blind structure to play")
+var storeKind = flag.String("store", poker.JSONStore, "how wins are stored, json or log")
+var leagueName = flag.String("league", poker.DefaultLeagueName, "league the games count towards")
+var seasonName = flag.String("season", "", "season the games count towards, the current quarter if empty")
+var buyIn = flag.Int("buy-in", 0, "what each player pays into the prize pool, the structure's buy in if 0")
+var sendBuffer = flag.Int("send-buffer", poker.DefaultSendBuffer, "how many messages a websocket client can fall behind by")
+var slowClients = flag.String("slow-clients", poker.DisconnectSlowClients.String(), "what to do with clients that fall further behind, disconnect or drop the messages")
+var rejoinWithin = flag.Duration("rejoin-within", 10*time.Minute, "how long a game everyone has left is kept for its players to rejoin")
+var leagueHeartbeat = flag.Duration("league-heartbeat", poker.DefaultLeagueHeartbeat, "how often a quiet /league/stream is sent a heartbeat")
+var payoutPlaces = flag.String("payouts", "", "percentage of the prize pool for each place like 50,30,20, the structure's payouts if empty")
 
 func main() {
+	flag.Parse()
+
+	blinds, err := poker.LoadBlindStructure(*blindsFile, *blindStructure)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	payouts := blinds.PayoutTable()
+	if *buyIn != 0 {
+		payouts.BuyIn = *buyIn
+	}
+	if *payoutPlaces != "" {
+		if payouts.Places, err = poker.ParsePayoutPlaces(*payoutPlaces); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := payouts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	slowPolicy, err := poker.ParseSlowClientPolicy(*slowClients)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	store, close, err := poker.FileSystemPlayerStoreFromFile(dbFileName)
+	path := dbFileName
+	if *storeKind == poker.EventLogStore {
+		path = logFileName
+	}
+
+	store, close, err := poker.PlayerStoreFromFile(*storeKind, path)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer close()
 
-	game := poker.NewTexasHoldem(poker.BlindAlerterFunc(poker.Alerter), store)
+	audit, closeAudit, err := poker.AuditLogFromFile(auditFileName)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeAudit()
 
+	// Games played over the websocket don't know who is playing, so they are
+	// put down to the web game.
+	audited := poker.NewAuditedPlayerStore(store, audit, poker.Actor{Who: "web game", Via: poker.ViaHTTP})
+	// Each table gets a game of its own so their blind clocks run apart.
+	games := poker.NewGameRegistry(func() poker.Game {
+		game := poker.NewTexasHoldemWithBlinds(poker.BlindAlerterFunc(poker.Alerter), audited, blinds)
+		game.PlayIn(*leagueName, *seasonName)
+		game.SetPayouts(payouts)
+		return game
+	})
+	games.SetSendBuffer(*sendBuffer, slowPolicy)
 
-	server, err := poker.NewPlayerServer(store, game)
+	sessions, closeSessions, err := poker.SessionStoreFromFile(sessionsFileName)
 
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer closeSessions()
 
+	// Games in play when the server stopped go on where they were, their
+	// blinds going up after what was left of their level.
+	games.SetSessionStore(sessions, *rejoinWithin)
+	restored, err := games.Restore()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("restored %d games in play\n", restored)
+
+	server, err := poker.NewPlayerServerWithGames(audited, games)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.SetLeagueHeartbeat(*leagueHeartbeat)
 
 	log.Println("Webserver listening on port 500")
 	if err := http.ListenAndServe(":5000", server); err != nil {
@@ -0,0 +1,164 @@
+package poker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/handeval"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+)
+
+var (
+	ErrNoHand     = errors.New("no hand has been dealt")
+	ErrHandInPlay = errors.New("the hand in play hasn't finished")
+	ErrOnBreak    = errors.New("no hands are dealt during a break")
+)
+
+// Dealer is a Game that deals the cards as well as keeping the clock, each
+// hand being played at the blinds of the level it is dealt on and a
+// showdown being won by the best cards.
+type Dealer interface {
+	Deal(seats []holdem.Seat) (holdem.State, error)
+	Act(name string, action holdem.Action) (holdem.State, error)
+	HoleCards(name string) ([]holdem.Card, error)
+	Standings() ([]string, error)
+}
+
+// BlindsFor are the blinds of a hand dealt on a level, the big blind being
+// the level's amount and the small blind half of it.
+func BlindsFor(blind Blind) holdem.Blinds {
+	return holdem.Blinds{Small: blind.Amount / 2, Big: blind.Amount, Ante: blind.Ante}
+}
+
+// WriteHand describes a hand for players sharing a terminal.
+func WriteHand(to io.Writer, state holdem.State) error {
+	street := state.Street.String()
+	street = strings.ToUpper(street[:1]) + street[1:]
+	if len(state.Board) > 0 {
+		street = fmt.Sprintf("%s %s", street, joinCards(state.Board))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, pot %d\n", street, state.Pot)
+
+	for _, player := range state.Players {
+		if len(player.Cards) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s shows %s", player.Name, joinCards(player.Cards))
+		if value, err := handeval.Evaluate(append(append([]holdem.Card(nil), player.Cards...), state.Board...)); err == nil {
+			fmt.Fprintf(&b, ", %v", value)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	switch {
+	case len(state.Winners) > 0:
+		for _, player := range state.Players {
+			if player.Won > 0 {
+				fmt.Fprintf(&b, "%s wins %d\n", player.Name, player.Won)
+			}
+		}
+	case state.ToAct != "" && state.ToCall > 0:
+		fmt.Fprintf(&b, "%s to act, %d to call\n", state.ToAct, state.ToCall)
+	case state.ToAct != "":
+		fmt.Fprintf(&b, "%s to act\n", state.ToAct)
+	}
+
+	if len(state.Pots) > 1 && len(state.Winners) == 0 {
+		var pots []string
+		for _, pot := range state.Pots {
+			pots = append(pots, fmt.Sprintf("%d between %s", pot.Amount, strings.Join(pot.Eligible, ", ")))
+		}
+		fmt.Fprintf(&b, "Pots: %s\n", strings.Join(pots, "; "))
+	}
+
+	if len(state.Winners) > 0 {
+		var stacks []string
+		for _, player := range state.Players {
+			if player.Stack == 0 {
+				stacks = append(stacks, fmt.Sprintf("%s is out", player.Name))
+				continue
+			}
+			stacks = append(stacks, fmt.Sprintf("%s %d", player.Name, player.Stack))
+		}
+		fmt.Fprintf(&b, "Stacks: %s\n", strings.Join(stacks, ", "))
+	}
+
+	_, err := io.WriteString(to, b.String())
+	return err
+}
+
+func joinCards(cards []holdem.Card) string {
+	names := make([]string, len(cards))
+	for i, card := range cards {
+		names[i] = card.String()
+	}
+	return strings.Join(names, " ")
+}
+
+var handActions = []struct {
+	verb   string
+	action holdem.ActionType
+}{
+	{" folds", holdem.Fold},
+	{" checks", holdem.Check},
+	{" calls", holdem.Call},
+	{" raises to ", holdem.Raise},
+	{" bets ", holdem.Raise},
+	{" goes all in", holdem.AllIn},
+}
+
+// parseHandAction reads an action typed by a player, like "Cleo calls" or
+// "Chris raises to 300". It returns false when input isn't an action.
+func parseHandAction(input string) (string, holdem.Action, bool) {
+	for _, a := range handActions {
+		i := strings.Index(input, a.verb)
+		if i <= 0 {
+			continue
+		}
+
+		name, rest := input[:i], strings.TrimSpace(input[i+len(a.verb):])
+		if a.action != holdem.Raise {
+			if rest != "" {
+				continue
+			}
+			return name, holdem.Action{Type: a.action}, true
+		}
+
+		amount, err := strconv.Atoi(rest)
+		if err != nil {
+			continue
+		}
+		return name, holdem.Action{Type: a.action, Amount: amount}, true
+	}
+	return "", holdem.Action{}, false
+}
+
+// parseSeats reads players sitting down to a hand like "Cleo:1000 Chris:1500".
+func parseSeats(input string) ([]holdem.Seat, error) {
+	var seats []holdem.Seat
+	seated := map[string]bool{}
+	for _, field := range strings.Fields(input) {
+		colon := strings.LastIndex(field, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("%q isn't a player and their chips like Cleo:1000", field)
+		}
+
+		stack, err := strconv.Atoi(field[colon+1:])
+		if err != nil || stack <= 0 {
+			return nil, fmt.Errorf("%q isn't a player and their chips like Cleo:1000", field)
+		}
+
+		name := field[:colon]
+		if seated[name] {
+			return nil, fmt.Errorf("%s can only sit down once", name)
+		}
+		seated[name] = true
+		seats = append(seats, holdem.Seat{Name: name, Stack: stack})
+	}
+	return seats, nil
+}
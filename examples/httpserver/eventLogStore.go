@@ -0,0 +1,401 @@
+package poker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const DefaultCompactionThreshold = 1000
+
+const (
+	winEvent      = "win"
+	gameEvent     = "game"
+	gamesEvent    = "games"
+	deleteEvent   = "delete"
+	renameEvent   = "rename"
+	snapshotEvent = "snapshot"
+)
+
+// storeEvent is a line of the log. Win events and the league of a snapshot
+// come from logs written before whole games were kept. GameID is the game a
+// delete removes, or the last ID handed out when the snapshot was taken.
+type storeEvent struct {
+	Type   string       `json:"type"`
+	Player string       `json:"player,omitempty"`
+	To     string       `json:"to,omitempty"`
+	League League       `json:"league,omitempty"`
+	Game   *GameRecord  `json:"game,omitempty"`
+	Games  []GameRecord `json:"games,omitempty"`
+	GameID int          `json:"gameID,omitempty"`
+}
+
+// LogFile is what an event log is kept in, usually an *os.File.
+type LogFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Name() string
+	Sync() error
+	Truncate(size int64) error
+}
+
+// EventLogPlayerStore keeps every game as a line appended to its file and
+// rebuilds its history from them when it's opened. Once enough events have
+// been appended the log is compacted into a single snapshot.
+type EventLogPlayerStore struct {
+	changeNotifier
+	mu                  sync.Mutex
+	file                LogFile
+	path                string
+	history             History
+	eventsSinceSnapshot int
+	compactionThreshold int
+}
+
+func NewEventLogPlayerStore(file LogFile) (*EventLogPlayerStore, error) {
+	store := &EventLogPlayerStore{
+		file:                file,
+		path:                file.Name(),
+		compactionThreshold: DefaultCompactionThreshold,
+	}
+
+	if err := store.load(); err != nil {
+		return nil, fmt.Errorf("problem loading player store from event log %s, %v", file.Name(), err)
+	}
+
+	return store, nil
+}
+
+func EventLogPlayerStoreFromFile(path string) (*EventLogPlayerStore, func(), error) {
+	db, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem opening %s %v", path, err)
+	}
+
+	store, err := NewEventLogPlayerStore(db)
+
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("problem creating event log player store, %v ", err)
+	}
+
+	return store, store.Close, nil
+}
+
+// SetCompactionThreshold sets how many events are appended before the log is
+// compacted, zero turns compaction off.
+func (s *EventLogPlayerStore) SetCompactionThreshold(events int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.compactionThreshold = events
+}
+
+func (s *EventLogPlayerStore) GetLeague() (League, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.history.League(), nil
+}
+
+func (s *EventLogPlayerStore) GetPlayerScore(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	player := s.history.League().Find(name)
+
+	if player != nil {
+		return player.Wins, nil
+	}
+
+	return 0, ErrPlayerNotFound
+}
+
+func (s *EventLogPlayerStore) RecordWin(name string) error {
+	if _, err := s.RecordGame(winRecord(name)); err != nil {
+		return fmt.Errorf("problem recording win for %s, %v", name, err)
+	}
+	return nil
+}
+
+func (s *EventLogPlayerStore) RecordGame(game GameRecord) (GameRecord, error) {
+	if err := game.Validate(); err != nil {
+		return GameRecord{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	game.ID = s.history.nextGameID()
+	event := storeEvent{Type: gameEvent, Game: &game}
+	if err := s.append(event); err != nil {
+		return GameRecord{}, fmt.Errorf("problem recording game, %v", err)
+	}
+	s.apply(event)
+	s.changed()
+	s.compactIfDue()
+	return game, nil
+}
+
+// RecordGames appends all of the games it doesn't have yet as one event, so
+// a crash part way through the write loses them all.
+func (s *EventLogPlayerStore) RecordGames(games []GameRecord) ([]GameRecord, error) {
+	for _, game := range games {
+		if err := game.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, recorded := s.history.withNewGames(games)
+	if len(recorded) == 0 {
+		return recorded, nil
+	}
+
+	event := storeEvent{Type: gamesEvent, Games: recorded}
+	if err := s.append(event); err != nil {
+		return nil, fmt.Errorf("problem recording games, %v", err)
+	}
+	s.apply(event)
+	s.changed()
+	s.compactIfDue()
+	return recorded, nil
+}
+
+func (s *EventLogPlayerStore) DeleteGame(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.history.Game(id); err != nil {
+		return err
+	}
+
+	event := storeEvent{Type: deleteEvent, GameID: id}
+	if err := s.append(event); err != nil {
+		return fmt.Errorf("problem deleting game %d, %v", id, err)
+	}
+	s.apply(event)
+	s.changed()
+	s.compactIfDue()
+	return nil
+}
+
+func (s *EventLogPlayerStore) RenamePlayer(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.history.renamed(from, to); err != nil {
+		return err
+	}
+
+	event := storeEvent{Type: renameEvent, Player: from, To: to}
+	if err := s.append(event); err != nil {
+		return fmt.Errorf("problem renaming %s, %v", from, err)
+	}
+	s.apply(event)
+	s.changed()
+	s.compactIfDue()
+	return nil
+}
+
+func (s *EventLogPlayerStore) GetGames() ([]GameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	games := make([]GameRecord, len(s.history.Games))
+	copy(games, s.history.Games)
+	return games, nil
+}
+
+func (s *EventLogPlayerStore) GetGame(id int) (GameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.history.Game(id)
+}
+
+func (s *EventLogPlayerStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+func (s *EventLogPlayerStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file.Close()
+}
+
+// append writes event to the end of the log. A write that fails part way
+// through is cut off again, so it can't be followed by the next event.
+func (s *EventLogPlayerStore) append(event storeEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		s.truncateAt(offset)
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		s.truncateAt(offset)
+		return err
+	}
+	return nil
+}
+
+func (s *EventLogPlayerStore) apply(event storeEvent) {
+	switch event.Type {
+	case snapshotEvent:
+		s.history = History{LegacyWins: event.League, Games: event.Games, LastGameID: event.GameID}
+		s.eventsSinceSnapshot = 0
+	case gameEvent:
+		s.history = s.history.withGame(*event.Game)
+		s.eventsSinceSnapshot++
+	case gamesEvent:
+		for _, game := range event.Games {
+			s.history = s.history.withGame(game)
+		}
+		s.eventsSinceSnapshot++
+	case renameEvent:
+		if history, err := s.history.renamed(event.Player, event.To); err == nil {
+			s.history = history
+		}
+		s.eventsSinceSnapshot++
+	case deleteEvent:
+		if history, err := s.history.withoutGame(event.GameID); err == nil {
+			s.history = history
+		}
+		s.eventsSinceSnapshot++
+	case winEvent:
+		player := s.history.LegacyWins.Find(event.Player)
+		if player != nil {
+			player.Wins++
+		} else {
+			s.history.LegacyWins = append(s.history.LegacyWins, Player{Name: event.Player, Wins: 1})
+		}
+		s.eventsSinceSnapshot++
+	}
+}
+
+// load replays the log. A last line that can't be read is what's left of a
+// write that never finished, so it's cut off rather than treated as an error.
+func (s *EventLogPlayerStore) load() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(s.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return s.truncateAt(offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var event storeEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return s.truncateAt(offset)
+			}
+			return fmt.Errorf("problem parsing event at offset %d, %v", offset, err)
+		}
+
+		s.apply(event)
+		offset += int64(len(line))
+	}
+
+	_, err := s.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (s *EventLogPlayerStore) truncateAt(offset int64) error {
+	log.Printf("discarding incomplete event at offset %d of %s", offset, s.path)
+
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// compact writes the league as a snapshot to a new file and swaps it in for
+// the log, so a crash part way through leaves the old log in place. The new
+// file is kept open to carry on appending to, so there's nothing left to
+// fail once it has replaced the old one.
+func (s *EventLogPlayerStore) compact() error {
+	path := s.path
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".compact")
+	if err != nil {
+		return fmt.Errorf("problem creating compacted log, %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	event := storeEvent{Type: snapshotEvent, League: s.history.LegacyWins, Games: s.history.Games}
+	if games := s.history.Games; len(games) == 0 || games[len(games)-1].ID < s.history.LastGameID {
+		event.GameID = s.history.LastGameID
+	}
+
+	snapshot, err := json.Marshal(event)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(append(snapshot, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("problem writing compacted log, %v", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("problem syncing compacted log, %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		tmp.Close()
+		return fmt.Errorf("problem replacing %s with compacted log, %v", path, err)
+	}
+	syncDir(filepath.Dir(path))
+
+	s.file.Close()
+	s.file = tmp
+	s.eventsSinceSnapshot = 0
+	return nil
+}
+
+// compactIfDue compacts the log once enough events have been appended since
+// the last snapshot. The event is already kept, so failing to compact is
+// only logged.
+func (s *EventLogPlayerStore) compactIfDue() {
+	if s.compactionThreshold > 0 && s.eventsSinceSnapshot >= s.compactionThreshold {
+		if err := s.compact(); err != nil {
+			log.Printf("problem compacting %s, %v", s.path, err)
+		}
+	}
+}
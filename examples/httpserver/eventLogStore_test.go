@@ -0,0 +1,319 @@
+package poker_test
+
+import (
+	"errors"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var gameTime = time.Date(2019, time.March, 1, 20, 0, 0, 0, time.UTC)
+
+func TestEventLogStore(t *testing.T) {
+
+	t.Run("rebuilds the league from the log", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"snapshot","league":[{"Name":"Cleo","Wins":10},{"Name":"Chris","Wins":32}]}
+{"type":"win","player":"Chris"}
+{"type":"win","player":"Pepper"}
+`)
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		got, err := store.GetLeague()
+		poker.AssertNoError(t, err)
+		want := poker.League{
+			{Name: "Chris", Wins: 33},
+			{Name: "Cleo", Wins: 10},
+			{Name: "Pepper", Wins: 1},
+		}
+
+		poker.AssertLeague(t, got, want)
+	})
+
+	t.Run("works with an empty file", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		poker.AssertStoreLeague(t, store, poker.League{})
+	})
+
+	t.Run("unknown players are not found", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"win","player":"Cleo"}`+"\n")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		_, err = store.GetPlayerScore("Apollo")
+		poker.AssertError(t, err, poker.ErrPlayerNotFound)
+	})
+
+	t.Run("appends games to the log without rewriting it", func(t *testing.T) {
+		existing := `{"type":"win","player":"Cleo"}` + "\n"
+		database, cleanDatabase := poker.CreateTempFile(t, existing)
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		_, err = store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Chris", "Cleo"}})
+		poker.AssertNoError(t, err)
+
+		want := existing + `{"type":"game","game":{"id":1,"startedAt":"2019-03-01T20:00:00Z","duration":"0s","players":["Chris","Cleo"]}}` + "\n"
+		assertFileContents(t, database.Name(), want)
+	})
+
+	t.Run("rebuilds the league from wins and games", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"snapshot","league":[{"Name":"Cleo","Wins":2}]}
+{"type":"win","player":"Chris"}
+{"type":"game","game":{"id":1,"startedAt":"2019-03-01T20:00:00Z","players":["Chris","Cleo"]}}
+`)
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 2}, {Name: "Chris", Wins: 2}})
+	})
+
+	t.Run("keeps wins when the log is reopened", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		store.RecordWin("Chris")
+		store.RecordWin("Chris")
+
+		reopened := mustReopenEventLog(t, database.Name())
+		poker.AssertStoreScore(t, reopened, "Chris", 2)
+	})
+
+	t.Run("recovers from a truncated last record", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"win","player":"Cleo"}
+{"type":"win","pla`)
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 1}})
+
+		store.RecordWin("Chris")
+
+		reopened := mustReopenEventLog(t, database.Name())
+		poker.AssertStoreLeague(t, reopened, poker.League{{Name: "Cleo", Wins: 1}, {Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("cuts off an event it fails part way through writing", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"win","player":"Cleo"}`+"\n")
+		defer cleanDatabase()
+
+		file := &tornWriteFile{File: database}
+		store, err := poker.NewEventLogPlayerStore(file)
+		poker.AssertNoError(t, err)
+
+		file.fail = true
+		if err := store.RecordWin("Chris"); err == nil {
+			t.Fatal("expected an error but didn't get one")
+		}
+
+		file.fail = false
+		poker.AssertNoError(t, store.RecordWin("Ruth"))
+
+		reopened := mustReopenEventLog(t, database.Name())
+		poker.AssertStoreLeague(t, reopened, poker.League{{Name: "Cleo", Wins: 1}, {Name: "Ruth", Wins: 1}})
+	})
+
+	t.Run("refuses a log that is corrupt before its last record", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `{"type":"win","player":"Cleo"}
+garbage
+{"type":"win","player":"Chris"}
+`)
+		defer cleanDatabase()
+
+		_, err := poker.NewEventLogPlayerStore(database)
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+
+	t.Run("compacts the log into a snapshot", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+		store.SetCompactionThreshold(2)
+
+		store.RecordWin("Chris")
+		store.RecordWin("Cleo")
+		store.RecordWin("Chris")
+
+		lines := strings.Split(strings.TrimSpace(readFile(t, database.Name())), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected a snapshot and one win in the log but got %q", lines)
+		}
+
+		reopened := mustReopenEventLog(t, database.Name())
+		poker.AssertStoreLeague(t, reopened, poker.League{{Name: "Chris", Wins: 2}, {Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("compacts after imports, deletes and renames too", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+		store.SetCompactionThreshold(1)
+
+		assertCompacted := func(t *testing.T) {
+			t.Helper()
+			lines := strings.Split(strings.TrimSpace(readFile(t, database.Name())), "\n")
+			if len(lines) != 1 || !strings.Contains(lines[0], `"type":"snapshot"`) {
+				t.Fatalf("expected only a snapshot in the log but got %q", lines)
+			}
+		}
+
+		_, err = store.RecordGames([]poker.GameRecord{
+			{StartedAt: gameTime, Players: []string{"Chirs"}},
+			{StartedAt: gameTime, Players: []string{"Cleo"}},
+		})
+		poker.AssertNoError(t, err)
+		assertCompacted(t)
+
+		poker.AssertNoError(t, store.DeleteGame(2))
+		assertCompacted(t)
+
+		poker.AssertNoError(t, store.RenamePlayer("Chirs", "Chris"))
+		assertCompacted(t)
+
+		poker.AssertNoError(t, store.RecordWin("Cleo"))
+		reopened := mustReopenEventLog(t, database.Name())
+		poker.AssertStoreLeague(t, reopened, poker.League{{Name: "Chris", Wins: 1}, {Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("compacts on demand", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Chris"}})
+		store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Cleo"}})
+		poker.AssertNoError(t, store.Compact())
+
+		want := `{"type":"snapshot","games":[` +
+			`{"id":1,"startedAt":"2019-03-01T20:00:00Z","duration":"0s","players":["Chris"]},` +
+			`{"id":2,"startedAt":"2019-03-01T20:00:00Z","duration":"0s","players":["Cleo"]}]}` + "\n"
+		assertFileContents(t, database.Name(), want)
+	})
+
+	t.Run("keeps the last game ID when compacting after a delete", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, "")
+		defer cleanDatabase()
+
+		store, err := poker.NewEventLogPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Chris"}})
+		store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Cleo"}})
+		poker.AssertNoError(t, store.DeleteGame(2))
+		poker.AssertNoError(t, store.Compact())
+
+		reopened := mustReopenEventLog(t, database.Name())
+		game, err := reopened.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Ruth"}})
+		poker.AssertNoError(t, err)
+
+		if game.ID != 3 {
+			t.Errorf("got game ID %d want 3", game.ID)
+		}
+	})
+}
+
+func TestPlayerStoreFromFile(t *testing.T) {
+	for _, kind := range []string{poker.JSONStore, poker.EventLogStore} {
+		t.Run("opens a "+kind+" store", func(t *testing.T) {
+			database, cleanDatabase := poker.CreateTempFile(t, "")
+			defer cleanDatabase()
+
+			store, closeStore, err := poker.PlayerStoreFromFile(kind, database.Name())
+			poker.AssertNoError(t, err)
+			defer closeStore()
+
+			store.RecordWin("Chris")
+			poker.AssertStoreScore(t, store, "Chris", 1)
+		})
+	}
+
+	t.Run("errors on an unknown kind of store", func(t *testing.T) {
+		_, _, err := poker.PlayerStoreFromFile("csv", "game.db.csv")
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func mustReopenEventLog(t *testing.T, path string) *poker.EventLogPlayerStore {
+	t.Helper()
+
+	store, closeStore, err := poker.EventLogPlayerStoreFromFile(path)
+	if err != nil {
+		log.Fatalf("problem reopening event log player store, %v", err)
+	}
+	t.Cleanup(closeStore)
+
+	return store
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open %s %v", path, err)
+	}
+	defer file.Close()
+
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("could not read %s %v", path, err)
+	}
+	return string(contents)
+}
+
+func assertFileContents(t *testing.T, path, want string) {
+	t.Helper()
+
+	if got := readFile(t, path); got != want {
+		t.Errorf("contents of %s are wrong, got %q want %q", path, got, want)
+	}
+}
+
+// tornWriteFile writes half of what it's given and then fails, like a disk
+// filling up, when fail is set.
+type tornWriteFile struct {
+	*os.File
+	fail bool
+}
+
+func (f *tornWriteFile) Write(p []byte) (int, error) {
+	if !f.fail {
+		return f.File.Write(p)
+	}
+
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
@@ -4,14 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
 	"sync"
 )
 
 type FileSystemPlayerStore struct {
+	changeNotifier
 	mu       sync.Mutex
+	tape     *Tape
 	database *json.Encoder
-	league   League
+	history  History
 }
 
 func NewFileSystemPlayerStore(file *os.File) (*FileSystemPlayerStore, error) {
@@ -22,15 +23,18 @@ func NewFileSystemPlayerStore(file *os.File) (*FileSystemPlayerStore, error) {
 		return nil, fmt.Errorf("problem initialising player db file, %v", err)
 	}
 
-	league, err := NewLeague(file)
+	history, err := NewHistory(file)
 
 	if err != nil {
 		return nil, fmt.Errorf("problem loading player store from file %s, %v", file.Name(), err)
 	}
 
+	tape := NewTape(file)
+
 	return &FileSystemPlayerStore{
-		database: json.NewEncoder(NewTape(file)),
-		league:   league,
+		tape:     tape,
+		database: json.NewEncoder(tape),
+		history:  history,
 	}, nil
 }
 
@@ -41,50 +45,147 @@ func FileSystemPlayerStoreFromFile(path string) (*FileSystemPlayerStore, func(),
 		return nil, nil, fmt.Errorf("problem opening %s %v", path, err)
 	}
 
-	closeFunc := func() {
-		db.Close()
-	}
-
 	store, err := NewFileSystemPlayerStore(db)
 
 	if err != nil {
+		db.Close()
 		return nil, nil, fmt.Errorf("problem creating file system player store, %v ", err)
 	}
 
-	return store, closeFunc, nil
+	return store, store.Close, nil
 }
 
-func (f *FileSystemPlayerStore) GetLeague() League {
-	sort.Slice(f.league, func(i, j int) bool {
-		return f.league[i].Wins > f.league[j].Wins
-	})
-	return f.league
+func (f *FileSystemPlayerStore) GetLeague() (League, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.history.League(), nil
 }
 
-func (f *FileSystemPlayerStore) GetPlayerScore(name string) int {
+func (f *FileSystemPlayerStore) GetPlayerScore(name string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	player := f.league.Find(name)
+	player := f.history.League().Find(name)
 
 	if player != nil {
-		return player.Wins
+		return player.Wins, nil
 	}
 
-	return 0
+	return 0, ErrPlayerNotFound
+}
+
+func (f *FileSystemPlayerStore) RecordWin(name string) error {
+	if _, err := f.RecordGame(winRecord(name)); err != nil {
+		return fmt.Errorf("problem saving win for %s, %v", name, err)
+	}
+	return nil
 }
 
-func (f *FileSystemPlayerStore) RecordWin(name string) {
+// RecordGame only keeps the game once it has been written to the file.
+func (f *FileSystemPlayerStore) RecordGame(game GameRecord) (GameRecord, error) {
+	if err := game.Validate(); err != nil {
+		return GameRecord{}, err
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	player := f.league.Find(name)
+	game.ID = f.history.nextGameID()
+	history := f.history.withGame(game)
 
-	if player != nil {
-		player.Wins++
-	} else {
-		f.league = append(f.league, Player{name, 1})
+	if err := f.database.Encode(history); err != nil {
+		return GameRecord{}, fmt.Errorf("problem saving game, %v", err)
 	}
 
-	f.database.Encode(f.league)
+	f.history = history
+	f.changed()
+	return game, nil
+}
+
+// RecordGames keeps all of the games it doesn't have yet or, when one can't
+// be, none of them.
+func (f *FileSystemPlayerStore) RecordGames(games []GameRecord) ([]GameRecord, error) {
+	for _, game := range games {
+		if err := game.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	history, recorded := f.history.withNewGames(games)
+	if len(recorded) == 0 {
+		return recorded, nil
+	}
+
+	if err := f.database.Encode(history); err != nil {
+		return nil, fmt.Errorf("problem saving games, %v", err)
+	}
+
+	f.history = history
+	f.changed()
+	return recorded, nil
+}
+
+func (f *FileSystemPlayerStore) DeleteGame(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	history, err := f.history.withoutGame(id)
+	if err != nil {
+		return err
+	}
+
+	if err := f.database.Encode(history); err != nil {
+		return fmt.Errorf("problem deleting game %d, %v", id, err)
+	}
+
+	f.history = history
+	f.changed()
+	return nil
+}
+
+func (f *FileSystemPlayerStore) RenamePlayer(from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	history, err := f.history.renamed(from, to)
+	if err != nil {
+		return err
+	}
+
+	if err := f.database.Encode(history); err != nil {
+		return fmt.Errorf("problem renaming %s, %v", from, err)
+	}
+
+	f.history = history
+	f.changed()
+	return nil
+}
+
+func (f *FileSystemPlayerStore) GetGames() ([]GameRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	games := make([]GameRecord, len(f.history.Games))
+	copy(games, f.history.Games)
+	return games, nil
+}
+
+func (f *FileSystemPlayerStore) GetGame(id int) (GameRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.history.Game(id)
+}
+
+func (f *FileSystemPlayerStore) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tape.Close()
 }
 
 func initialisePlayerDBFile(file *os.File) error {
@@ -2,7 +2,10 @@ package poker_test
 
 import (
 	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -20,17 +23,19 @@ func TestFileSystemStore(t *testing.T) {
 			log.Fatalf("problem creating file system player store, %v", err)
 		}
 
-		got := store.GetLeague()
+		got, err := store.GetLeague()
+		poker.AssertNoError(t, err)
 
 		want := poker.League{
-			{"Chris", 33},
-			{"Cleo", 10},
+			{Name: "Chris", Wins: 33},
+			{Name: "Cleo", Wins: 10},
 		}
 
 		poker.AssertLeague(t, got, want)
 
 		// read again
-		got = store.GetLeague()
+		got, err = store.GetLeague()
+		poker.AssertNoError(t, err)
 		poker.AssertLeague(t, got, want)
 	})
 
@@ -46,7 +51,8 @@ func TestFileSystemStore(t *testing.T) {
 			log.Fatalf("problem creating file system player store, %v", err)
 		}
 
-		got := store.GetPlayerScore("Chris")
+		got, err := store.GetPlayerScore("Chris")
+		poker.AssertNoError(t, err)
 
 		want := 33
 
@@ -67,7 +73,8 @@ func TestFileSystemStore(t *testing.T) {
 
 		store.RecordWin("Chris")
 
-		got := store.GetPlayerScore("Chris")
+		got, err := store.GetPlayerScore("Chris")
+		poker.AssertNoError(t, err)
 		want := 34
 		poker.AssertScoreEquals(t, got, want)
 	})
@@ -86,11 +93,77 @@ func TestFileSystemStore(t *testing.T) {
 
 		store.RecordWin("Pepper")
 
-		got := store.GetPlayerScore("Pepper")
+		got, err := store.GetPlayerScore("Pepper")
+		poker.AssertNoError(t, err)
 		want := 1
 		poker.AssertScoreEquals(t, got, want)
 	})
 
+	t.Run("keeps wins when the file is reopened", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `[
+        {"Name": "Cleo", "Wins": 10}]`)
+		defer cleanDatabase()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		poker.AssertNoError(t, store.RecordWin("Cleo"))
+
+		reopened, closeStore, err := poker.FileSystemPlayerStoreFromFile(database.Name())
+		poker.AssertNoError(t, err)
+		defer closeStore()
+
+		poker.AssertStoreScore(t, reopened, "Cleo", 11)
+	})
+
+	t.Run("returns the error and keeps the old score when a win can't be saved", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "store")
+		poker.AssertNoError(t, err)
+
+		database, err := os.Create(filepath.Join(dir, "game.db.json"))
+		poker.AssertNoError(t, err)
+		defer database.Close()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		os.RemoveAll(dir)
+
+		err = store.RecordWin("Chris")
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+
+		_, err = store.GetPlayerScore("Chris")
+		poker.AssertError(t, err, poker.ErrPlayerNotFound)
+	})
+
+	t.Run("renaming merges wins saved before games were kept", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `[
+            {"Name": "Chirs", "Wins": 2},
+            {"Name": "Chris", "Wins": 1}]`)
+		defer cleanDatabase()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		poker.AssertNoError(t, store.RenamePlayer("Chirs", "Chris"))
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Chris", Wins: 3}})
+	})
+
+	t.Run("unknown players are not found", func(t *testing.T) {
+		database, cleanDatabase := poker.CreateTempFile(t, `[
+        {"Name": "Cleo", "Wins": 10}]`)
+		defer cleanDatabase()
+
+		store, err := poker.NewFileSystemPlayerStore(database)
+		poker.AssertNoError(t, err)
+
+		_, err = store.GetPlayerScore("Apollo")
+		poker.AssertError(t, err, poker.ErrPlayerNotFound)
+	})
+
 	t.Run("works with an empty file", func(t *testing.T) {
 		database, cleanDatabase := poker.CreateTempFile(t, "")
 		defer cleanDatabase()
@@ -112,17 +185,19 @@ func TestFileSystemStore(t *testing.T) {
 			log.Fatalf("problem creating file system player store, %v", err)
 		}
 
-		got := store.GetLeague()
+		got, err := store.GetLeague()
+		poker.AssertNoError(t, err)
 
 		want := []poker.Player{
-			{"Chris", 33},
-			{"Cleo", 10},
+			{Name: "Chris", Wins: 33},
+			{Name: "Cleo", Wins: 10},
 		}
 
 		poker.AssertLeague(t, got, want)
 
 		// read again
-		got = store.GetLeague()
+		got, err = store.GetLeague()
+		poker.AssertNoError(t, err)
 		poker.AssertLeague(t, got, want)
 	})
 }
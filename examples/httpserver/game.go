@@ -1,38 +1,471 @@
 package poker
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/handeval"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
 )
 
 type TexasHoldem struct {
 	alerter BlindAlerter
 	store   PlayerStore
+	blinds  BlindStructure
+	payouts PayoutTable
+	league  string
+	season  string
+
+	mu              sync.Mutex
+	clock           *TournamentClock
+	startedAt       time.Time
+	numberOfPlayers int
+
+	rng        *rand.Rand
+	hand       *holdem.Hand
+	button     int
+	table      []holdem.Seat
+	eliminated []string
 }
 
 type Game interface {
-	Start(numberOfPlayers int, alertsDestination io.Writer)
-	Finish(winner string)
+	Start(ctx context.Context, numberOfPlayers int, alertsDestination io.Writer)
+	Finish(winner string, runnersUp ...string) (GameRecord, error)
+	TournamentControls
+}
+
+// ResumableGame is a game that can be saved part way through and picked up
+// again, like after the server restarts.
+type ResumableGame interface {
+	Game
+	Snapshot() (GameSnapshot, error)
+	Restore(ctx context.Context, snapshot GameSnapshot, alertsDestination io.Writer) error
+}
+
+// GameSnapshot is how far a game has got. A hand being dealt isn't part of
+// it, so a resumed game deals that hand again.
+type GameSnapshot struct {
+	StartedAt       time.Time     `json:"startedAt"`
+	NumberOfPlayers int           `json:"numberOfPlayers"`
+	Clock           ClockSnapshot `json:"clock"`
+	Seats           []holdem.Seat `json:"seats,omitempty"`
+	Button          int           `json:"button,omitempty"`
+	Eliminated      []string      `json:"eliminated,omitempty"`
 }
 
 func NewTexasHoldem(alerter BlindAlerter, store PlayerStore) *TexasHoldem {
+	return NewTexasHoldemWithBlinds(alerter, store, DefaultBlindStructure)
+}
+
+// NewTexasHoldemWithBlinds makes a game played to blinds, paying out prizes
+// from the payouts of the structure when it has them.
+func NewTexasHoldemWithBlinds(alerter BlindAlerter, store PlayerStore, blinds BlindStructure) *TexasHoldem {
 	return &TexasHoldem{
 		alerter: alerter,
 		store:   store,
+		blinds:  blinds,
+		payouts: blinds.PayoutTable(),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
-func (p *TexasHoldem) Start(numberOfPlayers int, alertsDestination io.Writer) {
-	blindIncrement := time.Duration(5+numberOfPlayers) * time.Minute
+// SetPayouts sets how the prizes of the games that follow are paid out.
+func (p *TexasHoldem) SetPayouts(payouts PayoutTable) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.payouts = payouts
+}
+
+// SetRand sets where the shuffles of the decks dealt come from, so a seeded
+// rng deals the same cards every time.
+func (p *TexasHoldem) SetRand(rng *rand.Rand) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rng = rng
+}
+
+// PlayIn makes the games that follow count towards league and season. An
+// empty season means the quarter each game is played in.
+func (p *TexasHoldem) PlayIn(league, season string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.league = league
+	p.season = season
+}
 
-	blinds := []int{100, 200, 300, 400, 500, 600, 800, 1000, 2000, 4000, 8000}
-	blindTime := 0 * time.Second
-	for _, blind := range blinds {
-		p.alerter.ScheduleAlertAt(blindTime, blind, alertsDestination)
-		blindTime = blindTime + blindIncrement
+// Start runs the tournament clock for a game. Its blind alerts are stopped
+// when ctx is cancelled, when the game finishes or when another game is
+// started.
+func (p *TexasHoldem) Start(ctx context.Context, numberOfPlayers int, alertsDestination io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clock != nil {
+		p.clock.Stop()
+	}
+
+	p.clock = NewTournamentClock(ctx, p.alerter, RealClock, p.blinds.Blinds(numberOfPlayers), alertsDestination)
+	p.startedAt = RealClock.Now()
+	p.numberOfPlayers = numberOfPlayers
+	p.hand = nil
+	p.button = 0
+	p.table = nil
+	p.eliminated = nil
+}
+
+// Snapshot is how far the game in play has got.
+func (p *TexasHoldem) Snapshot() (GameSnapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clock == nil {
+		return GameSnapshot{}, ErrGameNotStarted
+	}
+
+	// The table holds the stacks from before a hand in play, so that hand is
+	// dealt again from the same button.
+	button := p.button
+	if p.hand != nil && !p.hand.Done() {
+		button--
 	}
+
+	return GameSnapshot{
+		StartedAt:       p.startedAt.UTC(),
+		NumberOfPlayers: p.numberOfPlayers,
+		Clock:           p.clock.Snapshot(),
+		Seats:           append([]holdem.Seat(nil), p.table...),
+		Button:          button,
+		Eliminated:      append([]string(nil), p.eliminated...),
+	}, nil
+}
+
+// Restore picks a game up from snapshot in place of Start, with the clock
+// running on from where it had got to.
+func (p *TexasHoldem) Restore(ctx context.Context, snapshot GameSnapshot, alertsDestination io.Writer) error {
+	if snapshot.NumberOfPlayers < 1 {
+		return fmt.Errorf("problem restoring game, it has %d players", snapshot.NumberOfPlayers)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clock != nil {
+		p.clock.Stop()
+	}
+
+	blinds := p.blinds.Blinds(snapshot.NumberOfPlayers)
+	p.clock = ResumeTournamentClock(ctx, p.alerter, RealClock, blinds, alertsDestination, snapshot.Clock)
+	p.startedAt = snapshot.StartedAt
+	p.numberOfPlayers = snapshot.NumberOfPlayers
+	p.hand = nil
+	p.button = snapshot.Button
+	p.table = append([]holdem.Seat(nil), snapshot.Seats...)
+	p.eliminated = append([]string(nil), snapshot.Eliminated...)
+	return nil
 }
 
-func (p *TexasHoldem) Finish(winner string) {
-	p.store.RecordWin(winner)
+// Finish records the game with its players in finishing order, runnersUp
+// being everyone after the winner that we know about, and the prizes they
+// won. Without a winner the order is taken from the Standings after the last
+// hand dealt. It returns the game as it was recorded, or ErrGameNotStarted
+// when no game is running.
+func (p *TexasHoldem) Finish(winner string, runnersUp ...string) (GameRecord, error) {
+	if _, err := p.currentClock(); err != nil {
+		return GameRecord{}, err
+	}
+
+	if winner == "" {
+		standings, err := p.Standings()
+		if err != nil {
+			return GameRecord{}, err
+		}
+		winner, runnersUp = standings[0], standings[1:]
+	}
+
+	game := p.record()
+	game.Players = append([]string{winner}, runnersUp...)
+	game.BlindStructure = p.blinds.Name
+	game.Prizes = p.prizes(game)
+
+	// The game is only ended once it is recorded, so a failed record can be
+	// tried again.
+	recorded, err := p.store.RecordGame(game)
+	if err != nil {
+		return GameRecord{}, err
+	}
+	p.stopClock()
+	return recorded, nil
+}
+
+// prizes are the payouts for game, the pool being the buy in of every player
+// in it.
+func (p *TexasHoldem) prizes(game GameRecord) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	numberOfPlayers := game.NumberOfPlayers
+	if len(game.Players) > numberOfPlayers {
+		numberOfPlayers = len(game.Players)
+	}
+	return p.payouts.Prizes(numberOfPlayers)
+}
+
+func (p *TexasHoldem) Pause() error {
+	clock, err := p.currentClock()
+	if err != nil {
+		return err
+	}
+	return clock.Pause()
+}
+
+func (p *TexasHoldem) Resume() error {
+	clock, err := p.currentClock()
+	if err != nil {
+		return err
+	}
+	return clock.Resume()
+}
+
+func (p *TexasHoldem) NextLevel() error {
+	clock, err := p.currentClock()
+	if err != nil {
+		return err
+	}
+	return clock.NextLevel()
+}
+
+func (p *TexasHoldem) PreviousLevel() error {
+	clock, err := p.currentClock()
+	if err != nil {
+		return err
+	}
+	return clock.PreviousLevel()
+}
+
+func (p *TexasHoldem) Status() (ClockState, error) {
+	clock, err := p.currentClock()
+	if err != nil {
+		return ClockState{}, err
+	}
+	return clock.Status()
+}
+
+// Deal shuffles a deck and deals a hand at the blinds of the current level,
+// to seats or when there are none to the players left with chips after the
+// last hand. The button moves on a seat with every hand.
+func (p *TexasHoldem) Deal(seats []holdem.Seat) (holdem.State, error) {
+	clock, err := p.currentClock()
+	if err != nil {
+		return holdem.State{}, err
+	}
+
+	status, err := clock.Status()
+	if err != nil {
+		return holdem.State{}, err
+	}
+	if status.Blind.Break {
+		return holdem.State{}, ErrOnBreak
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hand != nil && !p.hand.Done() {
+		return holdem.State{}, ErrHandInPlay
+	}
+
+	if len(seats) == 0 {
+		seats = p.table
+	}
+	if len(seats) > 0 {
+		p.button %= len(seats)
+	}
+
+	hand, err := holdem.NewHand(seats, p.button, BlindsFor(status.Blind), holdem.NewDeck(p.rng))
+	if err != nil {
+		return holdem.State{}, err
+	}
+
+	p.hand = hand
+	p.table = append([]holdem.Seat(nil), seats...)
+	p.button++
+
+	if err := p.settle(); err != nil {
+		return holdem.State{}, err
+	}
+	return hand.State(), nil
+}
+
+func (p *TexasHoldem) Act(name string, action holdem.Action) (holdem.State, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hand == nil {
+		return holdem.State{}, ErrNoHand
+	}
+
+	if err := p.hand.Act(name, action); err != nil {
+		return holdem.State{}, err
+	}
+
+	if err := p.settle(); err != nil {
+		return holdem.State{}, err
+	}
+	return p.hand.State(), nil
+}
+
+// settle decides a hand that has reached a showdown and, once the hand is
+// over, puts the players who lost all their chips out of the game. Players
+// put out by the same hand finish in order of the chips they started it with.
+func (p *TexasHoldem) settle() error {
+	if p.hand.Street() == holdem.Showdown && !p.hand.Done() {
+		if err := showdown(p.hand); err != nil {
+			return err
+		}
+	}
+
+	if !p.hand.Done() {
+		return nil
+	}
+
+	started := map[string]int{}
+	for _, seat := range p.table {
+		started[seat.Name] = seat.Stack
+	}
+
+	var table, busted []holdem.Seat
+	for _, seat := range p.hand.Seats() {
+		if seat.Stack > 0 {
+			table = append(table, seat)
+		} else {
+			busted = append(busted, holdem.Seat{Name: seat.Name, Stack: started[seat.Name]})
+		}
+	}
+
+	sort.SliceStable(busted, func(i, j int) bool {
+		return busted[i].Stack < busted[j].Stack
+	})
+	for _, seat := range busted {
+		p.eliminated = append(p.eliminated, seat.Name)
+	}
+
+	p.table = table
+	return nil
+}
+
+// showdown shares the pots out between the players still in the hand with
+// the best cards.
+func showdown(hand *holdem.Hand) error {
+	var hands []handeval.Hand
+	for _, name := range hand.Contenders() {
+		cards, err := hand.HoleCards(name)
+		if err != nil {
+			return err
+		}
+		hands = append(hands, handeval.Hand{Name: name, Cards: append(cards, hand.Board()...)})
+	}
+
+	results, err := handeval.Rank(hands)
+	if err != nil {
+		return fmt.Errorf("problem deciding the showdown, %v", err)
+	}
+
+	var ranking [][]string
+	for i, result := range results {
+		if i == 0 || result.Value != results[i-1].Value {
+			ranking = append(ranking, nil)
+		}
+		ranking[len(ranking)-1] = append(ranking[len(ranking)-1], result.Name)
+	}
+	return hand.Settle(ranking...)
+}
+
+// Standings are the players still in the game in order of their stacks, the
+// biggest first, followed by the players put out from the last to the
+// first. It is the finishing order when the game ends after the last hand.
+func (p *TexasHoldem) Standings() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hand == nil {
+		return nil, ErrNoHand
+	}
+	if !p.hand.Done() {
+		return nil, ErrHandInPlay
+	}
+
+	table := append([]holdem.Seat(nil), p.table...)
+	sort.SliceStable(table, func(i, j int) bool {
+		return table[i].Stack > table[j].Stack
+	})
+
+	var standings []string
+	for _, seat := range table {
+		standings = append(standings, seat.Name)
+	}
+	for i := len(p.eliminated) - 1; i >= 0; i-- {
+		standings = append(standings, p.eliminated[i])
+	}
+	return standings, nil
+}
+
+func (p *TexasHoldem) HoleCards(name string) ([]holdem.Card, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hand == nil {
+		return nil, ErrNoHand
+	}
+	return p.hand.HoleCards(name)
+}
+
+func (p *TexasHoldem) currentClock() (*TournamentClock, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clock == nil {
+		return nil, ErrGameNotStarted
+	}
+	return p.clock, nil
+}
+
+// record is what is known about the current game so far.
+func (p *TexasHoldem) record() GameRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := RealClock.Now()
+	game := GameRecord{StartedAt: now.UTC(), League: p.league, Season: p.season}
+
+	if p.clock != nil {
+		game.StartedAt = p.startedAt.UTC()
+		game.Duration = now.Sub(p.startedAt)
+		game.NumberOfPlayers = p.numberOfPlayers
+	}
+	return game
+}
+
+// stopClock ends the current game.
+func (p *TexasHoldem) stopClock() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clock != nil {
+		p.clock.Stop()
+	}
+
+	p.clock = nil
+	p.hand = nil
+	p.table = nil
+	p.eliminated = nil
+	p.startedAt = time.Time{}
+	p.numberOfPlayers = 0
 }
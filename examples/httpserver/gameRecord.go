@@ -0,0 +1,361 @@
+package poker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrGameNotFound = errors.New("game not found")
+
+// GameRecord is a finished game. Players are in finishing order, so the
+// winner comes first, and Prizes are what each place won. NumberOfPlayers is
+// 0 when it isn't known, and League and Season are empty for the default
+// league and the quarter it was played in. Duration is written like 1h30m0s.
+type GameRecord struct {
+	ID              int           `json:"id"`
+	StartedAt       time.Time     `json:"startedAt"`
+	Duration        time.Duration `json:"duration"`
+	NumberOfPlayers int           `json:"numberOfPlayers,omitempty"`
+	Players         []string      `json:"players"`
+	Prizes          []int         `json:"prizes,omitempty"`
+	BlindStructure  string        `json:"blindStructure,omitempty"`
+	League          string        `json:"league,omitempty"`
+	Season          string        `json:"season,omitempty"`
+}
+
+func (g GameRecord) Winner() string {
+	return g.Players[0]
+}
+
+// Position is where name finished in the game, or 0 if they didn't play.
+func (g GameRecord) Position(name string) int {
+	for i, player := range g.Players {
+		if player == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Prize is what name won in the game.
+func (g GameRecord) Prize(name string) int {
+	position := g.Position(name)
+	if position == 0 || position > len(g.Prizes) {
+		return 0
+	}
+	return g.Prizes[position-1]
+}
+
+func (g GameRecord) Validate() error {
+	if len(g.Players) == 0 {
+		return fmt.Errorf("a game needs at least a winner")
+	}
+
+	seen := map[string]bool{}
+	for _, player := range g.Players {
+		if player == "" {
+			return fmt.Errorf("players need a name")
+		}
+		if seen[player] {
+			return fmt.Errorf("%s finished the game more than once", player)
+		}
+		seen[player] = true
+	}
+
+	if strings.Contains(g.League, "/") || strings.Contains(g.Season, "/") {
+		return fmt.Errorf("league and season names can't contain a /")
+	}
+
+	if g.NumberOfPlayers != 0 && g.NumberOfPlayers < len(g.Players) {
+		return fmt.Errorf("%d players finished a game for %d players", len(g.Players), g.NumberOfPlayers)
+	}
+
+	if len(g.Prizes) > len(g.Players) && len(g.Prizes) > g.NumberOfPlayers {
+		return fmt.Errorf("%d prizes were won in a game for %d players", len(g.Prizes), len(g.Players))
+	}
+	for _, prize := range g.Prizes {
+		if prize < 0 {
+			return fmt.Errorf("prizes can't be negative")
+		}
+	}
+
+	return nil
+}
+
+// gameRecordJSON is a GameRecord with its Duration as text. Records written
+// before durations were text have a number of nanoseconds instead.
+type gameRecordJSON struct {
+	ID              int             `json:"id"`
+	StartedAt       time.Time       `json:"startedAt"`
+	Duration        json.RawMessage `json:"duration"`
+	NumberOfPlayers int             `json:"numberOfPlayers,omitempty"`
+	Players         []string        `json:"players"`
+	Prizes          []int           `json:"prizes,omitempty"`
+	BlindStructure  string          `json:"blindStructure,omitempty"`
+	League          string          `json:"league,omitempty"`
+	Season          string          `json:"season,omitempty"`
+}
+
+func (g GameRecord) MarshalJSON() ([]byte, error) {
+	duration, err := json.Marshal(g.Duration.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(gameRecordJSON{
+		ID:              g.ID,
+		StartedAt:       g.StartedAt,
+		Duration:        duration,
+		NumberOfPlayers: g.NumberOfPlayers,
+		Players:         g.Players,
+		Prizes:          g.Prizes,
+		BlindStructure:  g.BlindStructure,
+		League:          g.League,
+		Season:          g.Season,
+	})
+}
+
+func (g *GameRecord) UnmarshalJSON(data []byte) error {
+	var record gameRecordJSON
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+
+	duration, err := parseGameDuration(record.Duration)
+	if err != nil {
+		return err
+	}
+
+	*g = GameRecord{
+		ID:              record.ID,
+		StartedAt:       record.StartedAt,
+		Duration:        duration,
+		NumberOfPlayers: record.NumberOfPlayers,
+		Players:         record.Players,
+		Prizes:          record.Prizes,
+		BlindStructure:  record.BlindStructure,
+		League:          record.League,
+		Season:          record.Season,
+	}
+	return nil
+}
+
+func parseGameDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var nanoseconds int64
+		if err := json.Unmarshal(data, &nanoseconds); err != nil {
+			return 0, fmt.Errorf("duration %s isn't a duration like 1h30m", data)
+		}
+		return time.Duration(nanoseconds), nil
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("duration %q isn't a duration like 1h30m", text)
+	}
+	return duration, nil
+}
+
+// History is everything a store knows about. LegacyWins holds the wins that
+// were recorded before whole games were kept, and LastGameID stops the IDs of
+// deleted games being handed out again.
+type History struct {
+	LegacyWins League       `json:"legacyWins,omitempty"`
+	Games      []GameRecord `json:"games"`
+	LastGameID int          `json:"lastGameID,omitempty"`
+}
+
+// NewHistory reads a history, or a league saved before games were kept.
+func NewHistory(rdr io.Reader) (History, error) {
+	buffered := bufio.NewReader(rdr)
+
+	first, err := firstNonSpace(buffered)
+	if err != nil {
+		return History{}, fmt.Errorf("problem parsing history, %v", err)
+	}
+
+	var history History
+	if first == '[' {
+		err = json.NewDecoder(buffered).Decode(&history.LegacyWins)
+	} else {
+		err = json.NewDecoder(buffered).Decode(&history)
+	}
+
+	if err != nil {
+		return History{}, fmt.Errorf("problem parsing history, %v", err)
+	}
+	return history, nil
+}
+
+func (h History) League() League {
+	league := make(League, len(h.LegacyWins))
+	copy(league, h.LegacyWins)
+
+	for _, game := range h.Games {
+		player := league.Find(game.Winner())
+
+		if player != nil {
+			player.Wins++
+		} else {
+			league = append(league, Player{Name: game.Winner(), Wins: 1})
+		}
+	}
+
+	sort.SliceStable(league, func(i, j int) bool {
+		return league[i].Wins > league[j].Wins
+	})
+	return league
+}
+
+func (h History) Game(id int) (GameRecord, error) {
+	for _, game := range h.Games {
+		if game.ID == id {
+			return game, nil
+		}
+	}
+	return GameRecord{}, ErrGameNotFound
+}
+
+func (h History) nextGameID() int {
+	last := h.LastGameID
+	if len(h.Games) > 0 && h.Games[len(h.Games)-1].ID > last {
+		last = h.Games[len(h.Games)-1].ID
+	}
+	return last + 1
+}
+
+// withGame returns a copy of the history that includes game, leaving h as it
+// was.
+func (h History) withGame(game GameRecord) History {
+	games := make([]GameRecord, len(h.Games), len(h.Games)+1)
+	copy(games, h.Games)
+
+	if game.ID > h.LastGameID {
+		h.LastGameID = game.ID
+	}
+	h.Games = append(games, game)
+	return h
+}
+
+// withGames returns a copy of the history that includes games with IDs given
+// to them, leaving h as it was.
+func (h History) withGames(games []GameRecord) (History, []GameRecord) {
+	recorded := make([]GameRecord, len(games))
+	for i, game := range games {
+		game.ID = h.nextGameID()
+		h = h.withGame(game)
+		recorded[i] = game
+	}
+	return h, recorded
+}
+
+// withNewGames adds the games that aren't in the history already, like an
+// import run again, returning the ones it added.
+func (h History) withNewGames(games []GameRecord) (History, []GameRecord) {
+	fresh := newGames(h.Games, games)
+	return h.withGames(fresh)
+}
+
+// withoutGame returns a copy of the history without the game with id,
+// leaving h as it was.
+func (h History) withoutGame(id int) (History, error) {
+	for i, game := range h.Games {
+		if game.ID == id {
+			h.LastGameID = h.nextGameID() - 1
+
+			games := make([]GameRecord, 0, len(h.Games)-1)
+			games = append(games, h.Games[:i]...)
+			h.Games = append(games, h.Games[i+1:]...)
+			return h, nil
+		}
+	}
+	return h, ErrGameNotFound
+}
+
+// RenameError is why a player's history can't be given to another name.
+type RenameError struct {
+	From, To, Reason string
+}
+
+func (e RenameError) Error() string {
+	return fmt.Sprintf("can't rename %s to %q, %s", e.From, e.To, e.Reason)
+}
+
+// renamed returns a copy of the history with every game and win of from
+// credited to to instead, leaving h as it was.
+func (h History) renamed(from, to string) (History, error) {
+	if to == "" || to == from || strings.Contains(to, "/") {
+		return h, RenameError{From: from, To: to, Reason: "that isn't a new name"}
+	}
+
+	found := false
+
+	league := make(League, 0, len(h.LegacyWins))
+	for _, player := range h.LegacyWins {
+		if player.Name == from {
+			found = true
+			player.Name = to
+		}
+
+		if existing := league.Find(player.Name); existing != nil {
+			existing.Wins += player.Wins
+		} else {
+			league = append(league, player)
+		}
+	}
+
+	games := make([]GameRecord, len(h.Games))
+	for i, game := range h.Games {
+		position := game.Position(from)
+		if position != 0 {
+			if game.Position(to) != 0 {
+				return h, RenameError{From: from, To: to, Reason: fmt.Sprintf("they both played game %d", game.ID)}
+			}
+
+			found = true
+			game.Players = append([]string(nil), game.Players...)
+			game.Players[position-1] = to
+		}
+		games[i] = game
+	}
+
+	if !found {
+		return h, ErrPlayerNotFound
+	}
+
+	if h.LegacyWins != nil {
+		h.LegacyWins = league
+	}
+	h.Games = games
+	return h, nil
+}
+
+func winRecord(name string) GameRecord {
+	return GameRecord{
+		StartedAt: time.Now().UTC(),
+		Players:   []string{name},
+	}
+}
+
+func firstNonSpace(rdr *bufio.Reader) (byte, error) {
+	for {
+		b, err := rdr.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, rdr.UnreadByte()
+		}
+	}
+}
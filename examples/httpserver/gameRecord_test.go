@@ -0,0 +1,127 @@
+package poker_test
+
+import (
+	"encoding/json"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHistory(t *testing.T) {
+	t.Run("reads a league saved before games were kept", func(t *testing.T) {
+		history, err := poker.NewHistory(strings.NewReader(`  [{"Name": "Cleo", "Wins": 10}]`))
+		poker.AssertNoError(t, err)
+
+		poker.AssertLeague(t, history.LegacyWins, poker.League{{Name: "Cleo", Wins: 10}})
+		if len(history.Games) != 0 {
+			t.Errorf("expected no games but got %v", history.Games)
+		}
+	})
+
+	t.Run("reads games and legacy wins", func(t *testing.T) {
+		history, err := poker.NewHistory(strings.NewReader(`{
+            "legacyWins": [{"Name": "Cleo", "Wins": 10}],
+            "games": [{"id": 1, "startedAt": "2019-03-01T20:00:00Z", "numberOfPlayers": 3, "players": ["Chris", "Cleo"], "blindStructure": "turbo"}]}`))
+		poker.AssertNoError(t, err)
+
+		want := []poker.GameRecord{
+			{ID: 1, StartedAt: gameTime, NumberOfPlayers: 3, Players: []string{"Chris", "Cleo"}, BlindStructure: "turbo"},
+		}
+		if !reflect.DeepEqual(history.Games, want) {
+			t.Errorf("got %+v want %+v", history.Games, want)
+		}
+	})
+
+	t.Run("errors on a file that isn't a history", func(t *testing.T) {
+		_, err := poker.NewHistory(strings.NewReader(`"Chris"`))
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestHistory_League(t *testing.T) {
+	history := poker.History{
+		LegacyWins: poker.League{{Name: "Cleo", Wins: 2}},
+		Games: []poker.GameRecord{
+			{ID: 1, Players: []string{"Chris", "Cleo"}},
+			{ID: 2, Players: []string{"Chris", "Cleo", "Ruth"}},
+			{ID: 3, Players: []string{"Ruth", "Chris"}},
+			{ID: 4, Players: []string{"Chris"}},
+		},
+	}
+
+	want := poker.League{{Name: "Chris", Wins: 3}, {Name: "Cleo", Wins: 2}, {Name: "Ruth", Wins: 1}}
+	poker.AssertLeague(t, history.League(), want)
+}
+
+func TestGameRecord(t *testing.T) {
+	game := poker.GameRecord{NumberOfPlayers: 4, Players: []string{"Chris", "Cleo", "Ruth"}, Prizes: []int{300, 100}}
+
+	t.Run("the winner finished first", func(t *testing.T) {
+		poker.AssertResponseBody(t, game.Winner(), "Chris")
+	})
+
+	t.Run("knows where everyone finished", func(t *testing.T) {
+		poker.AssertScoreEquals(t, game.Position("Ruth"), 3)
+		poker.AssertScoreEquals(t, game.Position("Apollo"), 0)
+	})
+
+	t.Run("knows what everyone won", func(t *testing.T) {
+		poker.AssertScoreEquals(t, game.Prize("Cleo"), 100)
+		poker.AssertScoreEquals(t, game.Prize("Ruth"), 0)
+		poker.AssertScoreEquals(t, game.Prize("Apollo"), 0)
+	})
+
+	invalid := map[string]poker.GameRecord{
+		"no players":              {},
+		"a player without name":   {Players: []string{"Chris", ""}},
+		"a player twice":          {Players: []string{"Chris", "Cleo", "Chris"}},
+		"more players than seats": {NumberOfPlayers: 2, Players: []string{"Chris", "Cleo", "Ruth"}},
+		"more prizes than places": {Players: []string{"Chris"}, Prizes: []int{100, 50}},
+		"a negative prize":        {Players: []string{"Chris", "Cleo"}, Prizes: []int{100, -50}},
+	}
+
+	for name, game := range invalid {
+		t.Run("rejects games with "+name, func(t *testing.T) {
+			if game.Validate() == nil {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+
+	t.Run("writes its duration like the csv import reads it", func(t *testing.T) {
+		data, err := json.Marshal(poker.GameRecord{ID: 1, StartedAt: gameTime, Duration: 90 * time.Minute, Players: []string{"Chris"}})
+		poker.AssertNoError(t, err)
+
+		want := `{"id":1,"startedAt":"2019-03-01T20:00:00Z","duration":"1h30m0s","players":["Chris"]}`
+		poker.AssertResponseBody(t, string(data), want)
+	})
+
+	durations := map[string]string{
+		"as text":                 `"1h30m"`,
+		"as nanoseconds in older": `5400000000000`,
+	}
+
+	for name, duration := range durations {
+		t.Run("reads its duration "+name+" records", func(t *testing.T) {
+			var got poker.GameRecord
+			err := json.Unmarshal([]byte(`{"id":1,"duration":`+duration+`,"players":["Chris"]}`), &got)
+			poker.AssertNoError(t, err)
+
+			if got.Duration != 90*time.Minute {
+				t.Errorf("got duration %v want %v", got.Duration, 90*time.Minute)
+			}
+		})
+	}
+
+	t.Run("rejects a duration it can't read", func(t *testing.T) {
+		var got poker.GameRecord
+		if err := json.Unmarshal([]byte(`{"duration":"an hour","players":["Chris"]}`), &got); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
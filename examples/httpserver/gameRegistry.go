@@ -0,0 +1,570 @@
+package poker
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBadTableID    = errors.New("table IDs are letters, digits, - and _, and can't be just a number as those are recorded games")
+	ErrTableNotFound = errors.New("no game is being played at that table")
+	ErrAlreadyOut    = errors.New("that player is already out")
+	ErrBadSession    = errors.New("no game is being played with that session")
+	ErrTableFinished = errors.New("the game at that table has already finished")
+)
+
+var tableIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidTableID is whether id can name a table. IDs that are only digits are
+// left to the recorded games under /games/{id}.
+func ValidTableID(id string) bool {
+	if !tableIDPattern.MatchString(id) {
+		return false
+	}
+	_, err := strconv.Atoi(id)
+	return err != nil
+}
+
+// GameRegistry keeps the tables being played at, so several games can run at
+// once. Each table has a game of its own, made by newGame, with its own
+// clock.
+type GameRegistry struct {
+	mu         sync.Mutex
+	newGame    func() Game
+	tables     map[string]*Table
+	lastID     int
+	sendBuffer int
+	slowPolicy SlowClientPolicy
+	sessions   SessionStore
+	keepFor    time.Duration
+}
+
+func NewGameRegistry(newGame func() Game) *GameRegistry {
+	return &GameRegistry{
+		newGame:    newGame,
+		tables:     map[string]*Table{},
+		sendBuffer: DefaultSendBuffer,
+		slowPolicy: DisconnectSlowClients,
+	}
+}
+
+// SetSendBuffer sets how many messages each client of the tables set up from
+// now on can fall behind by, and what happens to those that fall further.
+func (g *GameRegistry) SetSendBuffer(size int, policy SlowClientPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sendBuffer = size
+	g.slowPolicy = policy
+}
+
+// SetSessionStore saves the games in play to sessions, so they can be
+// restored after a restart. Tables whose games have started are kept for
+// keepFor after everyone leaves, for their players to rejoin.
+func (g *GameRegistry) SetSessionStore(sessions SessionStore, keepFor time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sessions = sessions
+	g.keepFor = keepFor
+}
+
+// Restore sets up the tables saved in the session store with their games
+// picked up where they were, returning how many it restored. Sessions that
+// can't be restored are deleted.
+func (g *GameRegistry) Restore() (int, error) {
+	g.mu.Lock()
+	sessions, keepFor := g.sessions, g.keepFor
+	g.mu.Unlock()
+
+	if sessions == nil {
+		return 0, nil
+	}
+
+	saved, err := sessions.Sessions()
+	if err != nil {
+		return 0, fmt.Errorf("problem getting sessions, %v", err)
+	}
+
+	restored := 0
+	for _, session := range saved {
+		if err := g.restore(session); err != nil {
+			log.Printf("problem restoring table %s, %v", session.ID, err)
+			sessions.DeleteSession(session.ID)
+			continue
+		}
+		restored++
+	}
+
+	g.mu.Lock()
+	for _, table := range g.tables {
+		table.abandonAfter(keepFor)
+	}
+	g.mu.Unlock()
+
+	return restored, nil
+}
+
+func (g *GameRegistry) restore(session TableSession) error {
+	if !ValidTableID(session.ID) {
+		return ErrBadTableID
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, taken := g.tables[session.ID]; taken {
+		return fmt.Errorf("table %s is already being played at", session.ID)
+	}
+
+	table := g.newTable(session.ID, session.Token, session.CreatedAt)
+	game, ok := table.Game.(ResumableGame)
+	if !ok {
+		return fmt.Errorf("its game can't be restored")
+	}
+
+	if err := game.Restore(table.ctx, session.Game, table.broadcast); err != nil {
+		table.cancel()
+		return err
+	}
+
+	table.started = true
+	table.numberOfPlayers = session.Game.NumberOfPlayers
+	table.out = append([]string(nil), session.Out...)
+	g.tables[session.ID] = table
+	return nil
+}
+
+// Join sits client at the table with id, setting the table up when nobody is
+// at it yet. An empty id sets up a new table with an ID of its own.
+func (g *GameRegistry) Join(id string, client io.Writer) (*Table, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if id == "" {
+		id = g.newID()
+	}
+
+	if !ValidTableID(id) {
+		return nil, ErrBadTableID
+	}
+
+	table, ok := g.tables[id]
+	if !ok {
+		token, err := NewSessionToken()
+		if err != nil {
+			return nil, err
+		}
+		table = g.newTable(id, token, RealClock.Now().UTC())
+		g.tables[id] = table
+	}
+
+	table.join(client, false)
+	return table, nil
+}
+
+// Rejoin sits client back at the table whose session has token. A token
+// finds a table again, it doesn't keep anybody away from one: every player
+// at the table is sent it, and anyone can join the table by its ID.
+func (g *GameRegistry) Rejoin(token string, client io.Writer) (*Table, error) {
+	g.mu.Lock()
+	var table *Table
+	for _, t := range g.tables {
+		if token != "" && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			table = t
+		}
+	}
+	g.mu.Unlock()
+
+	if table == nil {
+		return nil, ErrBadSession
+	}
+
+	table.join(client, false)
+	return table, nil
+}
+
+// Watch sits client at the table with id as a spectator, who is sent
+// everything the players are but can't change the game.
+func (g *GameRegistry) Watch(id string, client io.Writer) (*Table, error) {
+	g.mu.Lock()
+	table, ok := g.tables[id]
+	g.mu.Unlock()
+
+	if !ok {
+		return nil, ErrTableNotFound
+	}
+
+	table.join(client, true)
+	return table, nil
+}
+
+// Leave takes client away from table, closing the table when it was the
+// last one there. Tables with a saved game in play are kept a while first,
+// in case their players are coming back.
+func (g *GameRegistry) Leave(table *Table, client io.Writer) {
+	if table.leave(client) > 0 {
+		return
+	}
+
+	g.mu.Lock()
+	keepFor := g.keepFor
+	if g.sessions == nil {
+		keepFor = 0
+	}
+	g.mu.Unlock()
+
+	if keepFor > 0 && table.Started() {
+		table.abandonAfter(keepFor)
+		return
+	}
+	g.close(table)
+}
+
+// Table is the table with id, if it is being played at.
+func (g *GameRegistry) Table(id string) (*Table, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	table, ok := g.tables[id]
+	return table, ok
+}
+
+// Active lists the tables being played at, the oldest first.
+func (g *GameRegistry) Active() []ActiveTable {
+	g.mu.Lock()
+	tables := make([]*Table, 0, len(g.tables))
+	for _, table := range g.tables {
+		tables = append(tables, table)
+	}
+	g.mu.Unlock()
+
+	sort.Slice(tables, func(i, j int) bool {
+		if !tables[i].CreatedAt.Equal(tables[j].CreatedAt) {
+			return tables[i].CreatedAt.Before(tables[j].CreatedAt)
+		}
+		return tables[i].ID < tables[j].ID
+	})
+
+	active := make([]ActiveTable, len(tables))
+	for i, table := range tables {
+		active[i] = table.Status()
+	}
+	return active
+}
+
+// close stops the clock of table, sends everyone at it away and forgets its
+// session.
+func (g *GameRegistry) close(table *Table) {
+	g.mu.Lock()
+	current := g.tables[table.ID] == table
+	if current {
+		delete(g.tables, table.ID)
+	}
+	sessions := g.sessions
+	g.mu.Unlock()
+
+	table.close()
+
+	if current && sessions != nil {
+		if err := sessions.DeleteSession(table.ID); err != nil {
+			log.Printf("problem deleting session of table %s, %v", table.ID, err)
+		}
+	}
+}
+
+// save saves the session of table, when there is somewhere to save it and
+// its game can be resumed.
+func (g *GameRegistry) save(table *Table) {
+	g.mu.Lock()
+	sessions := g.sessions
+	g.mu.Unlock()
+
+	game, ok := table.Game.(ResumableGame)
+	if sessions == nil || !ok {
+		return
+	}
+
+	snapshot, err := game.Snapshot()
+	if err != nil {
+		log.Printf("problem saving table %s, %v", table.ID, err)
+		return
+	}
+
+	table.mu.Lock()
+	session := TableSession{
+		ID:        table.ID,
+		Token:     table.Token,
+		CreatedAt: table.CreatedAt,
+		Out:       append([]string(nil), table.out...),
+		Game:      snapshot,
+	}
+	table.mu.Unlock()
+
+	if err := sessions.SaveSession(session); err != nil {
+		log.Printf("problem saving table %s, %v", table.ID, err)
+	}
+}
+
+// newTable must be called with mu held.
+func (g *GameRegistry) newTable(id, token string, createdAt time.Time) *Table {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Table{
+		ID:        id,
+		Token:     token,
+		Game:      g.newGame(),
+		CreatedAt: createdAt,
+		registry:  g,
+		ctx:       ctx,
+		cancel:    cancel,
+		broadcast: NewBroadcaster(g.sendBuffer, g.slowPolicy),
+		clients:   map[io.Writer]bool{},
+		session:   g.sessions != nil,
+	}
+}
+
+func (g *GameRegistry) newID() string {
+	for {
+		g.lastID++
+		id := fmt.Sprintf("table-%d", g.lastID)
+		if _, taken := g.tables[id]; !taken {
+			return id
+		}
+	}
+}
+
+// ActiveTable is how a table stands, for listing the games being played.
+type ActiveTable struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Clients         int       `json:"clients"`
+	Spectators      int       `json:"spectators"`
+	Started         bool      `json:"started"`
+	NumberOfPlayers int       `json:"numberOfPlayers,omitempty"`
+	Level           int       `json:"level,omitempty"`
+	Paused          bool      `json:"paused,omitempty"`
+	Out             []string  `json:"out,omitempty"`
+}
+
+// Table is a game being played with clients watching it. Everything written
+// to the table, like its blind alerts, is broadcast to every client. Players
+// can get back to the table with its Token, which every one of them is sent.
+type Table struct {
+	ID        string
+	Token     string
+	Game      Game
+	CreatedAt time.Time
+
+	registry  *GameRegistry
+	ctx       context.Context
+	cancel    context.CancelFunc
+	broadcast *Broadcaster
+
+	mu              sync.Mutex
+	clients         map[io.Writer]bool
+	started         bool
+	numberOfPlayers int
+	out             []string
+	session         bool
+	abandon         *time.Timer
+	finished        bool
+}
+
+// Start starts the game of the table for numberOfPlayers, unless a client
+// already has, and reports whether it did.
+func (t *Table) Start(numberOfPlayers int) bool {
+	t.mu.Lock()
+	if t.started {
+		t.mu.Unlock()
+		return false
+	}
+	t.started = true
+	t.numberOfPlayers = numberOfPlayers
+	t.mu.Unlock()
+
+	t.Game.Start(t.ctx, numberOfPlayers, t.broadcast)
+	t.registry.save(t)
+	return true
+}
+
+func (t *Table) Started() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.started
+}
+
+// Eliminate puts player out of the game and tells everyone at the table.
+func (t *Table) Eliminate(player string) error {
+	t.mu.Lock()
+	if !t.started {
+		t.mu.Unlock()
+		return ErrGameNotStarted
+	}
+	for _, out := range t.out {
+		if out == player {
+			t.mu.Unlock()
+			return ErrAlreadyOut
+		}
+	}
+	t.out = append(t.out, player)
+
+	place := t.numberOfPlayers - len(t.out) + 1
+	if place < 2 {
+		place = 0
+	}
+	t.mu.Unlock()
+
+	t.registry.save(t)
+	return t.broadcast.WriteElimination(Elimination{Player: player, Place: place})
+}
+
+// Clock runs a clock command on the game of the table, writing the clock to
+// out when asked for the time.
+func (t *Table) Clock(command string, out io.Writer) error {
+	ok, err := clockCommand(t.Game, command, out)
+	if !ok {
+		return fmt.Errorf("unknown clock command %q", command)
+	}
+	if err == nil && command != "time" {
+		t.registry.save(t)
+	}
+	return err
+}
+
+// Finish records the game of the table, tells everyone at it the result and
+// closes the table. Without runners up, the players put out are the runners
+// up, the last one out first. A table is only finished once, later finishes
+// get ErrTableFinished.
+func (t *Table) Finish(winner string, runnersUp ...string) (GameRecord, error) {
+	t.mu.Lock()
+	if t.finished {
+		t.mu.Unlock()
+		return GameRecord{}, ErrTableFinished
+	}
+	t.finished = true
+	if len(runnersUp) == 0 {
+		for i := len(t.out) - 1; i >= 0; i-- {
+			runnersUp = append(runnersUp, t.out[i])
+		}
+	}
+	t.mu.Unlock()
+
+	recorded, err := t.Game.Finish(winner, runnersUp...)
+	if err != nil {
+		t.mu.Lock()
+		t.finished = false
+		t.mu.Unlock()
+		return GameRecord{}, err
+	}
+
+	t.broadcast.WriteResult(Result{Winner: winner, RunnersUp: runnersUp})
+	t.registry.close(t)
+	return recorded, nil
+}
+
+func (t *Table) Status() ActiveTable {
+	t.mu.Lock()
+	status := ActiveTable{
+		ID:              t.ID,
+		CreatedAt:       t.CreatedAt,
+		Started:         t.started,
+		NumberOfPlayers: t.numberOfPlayers,
+		Out:             append([]string(nil), t.out...),
+	}
+	for _, spectator := range t.clients {
+		if spectator {
+			status.Spectators++
+		} else {
+			status.Clients++
+		}
+	}
+	t.mu.Unlock()
+
+	if status.Started {
+		if clock, err := t.Game.Status(); err == nil {
+			status.Level = clock.Blind.Level
+			status.Paused = clock.Paused
+		}
+	}
+	return status
+}
+
+// join adds client to the table. Players at a table with a session are sent
+// it to rejoin with, and clients joining a game that has started are sent the
+// clock so they can catch up.
+func (t *Table) join(client io.Writer, spectator bool) {
+	t.mu.Lock()
+	t.clients[client] = spectator
+	started := t.started
+	if t.abandon != nil {
+		t.abandon.Stop()
+		t.abandon = nil
+	}
+	session := t.session && !spectator
+	t.mu.Unlock()
+
+	if session {
+		WriteSession(client, t.ID, t.Token)
+	}
+	if started {
+		if clock, err := t.Game.Status(); err == nil {
+			WriteClock(client, clock)
+		}
+	}
+	t.broadcast.Add(client)
+}
+
+// leave takes client away, returning how many clients are left.
+func (t *Table) leave(client io.Writer) int {
+	t.mu.Lock()
+	delete(t.clients, client)
+	left := len(t.clients)
+	t.mu.Unlock()
+
+	t.broadcast.Remove(client)
+	return left
+}
+
+// abandonAfter closes the table if nobody has joined it after d.
+func (t *Table) abandonAfter(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.clients) > 0 || t.abandon != nil {
+		return
+	}
+
+	t.abandon = time.AfterFunc(d, func() {
+		t.mu.Lock()
+		empty := len(t.clients) == 0
+		t.mu.Unlock()
+
+		if empty {
+			t.registry.close(t)
+		}
+	})
+}
+
+// close stops the clock and sends everyone away once they have been sent
+// what is left for them.
+func (t *Table) close() {
+	t.mu.Lock()
+	t.finished = true
+	if t.abandon != nil {
+		t.abandon.Stop()
+	}
+	t.mu.Unlock()
+
+	t.cancel()
+	t.broadcast.Close()
+}
@@ -0,0 +1,374 @@
+package poker_test
+
+import (
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameRegistry(t *testing.T) {
+	newRegistry := func() (*poker.GameRegistry, *[]*poker.GameSpy) {
+		games := &[]*poker.GameSpy{}
+		registry := poker.NewGameRegistry(func() poker.Game {
+			game := &poker.GameSpy{}
+			*games = append(*games, game)
+			return game
+		})
+		return registry, games
+	}
+
+	t.Run("each table plays a game of its own", func(t *testing.T) {
+		registry, games := newRegistry()
+
+		cleos, err := registry.Join("cleos", &syncBuffer{})
+		poker.AssertNoError(t, err)
+		chriss, err := registry.Join("chriss", &syncBuffer{})
+		poker.AssertNoError(t, err)
+
+		cleos.Start(3)
+		chriss.Start(5)
+
+		if len(*games) != 2 {
+			t.Fatalf("got %d games want 2", len(*games))
+		}
+		poker.AssertGameStartedWith(t, (*games)[0], 3)
+		poker.AssertGameStartedWith(t, (*games)[1], 5)
+	})
+
+	t.Run("clients at the same table share its game and its alerts", func(t *testing.T) {
+		registry, games := newRegistry()
+		first, second := &syncBuffer{}, &syncBuffer{}
+
+		table, err := registry.Join("cleos", first)
+		poker.AssertNoError(t, err)
+		again, err := registry.Join("cleos", second)
+		poker.AssertNoError(t, err)
+
+		if table != again || len(*games) != 1 {
+			t.Fatalf("expected both clients at the one table")
+		}
+
+		(*games)[0].BlindAlert = []byte("Blind is 100\n")
+		if !table.Start(3) {
+			t.Error("expected the first start to start the game")
+		}
+		if table.Start(4) {
+			t.Error("didn't expect the game to start twice")
+		}
+
+		registry.Leave(table, first)
+		registry.Leave(table, second)
+
+		poker.AssertGameStartedWith(t, (*games)[0], 3)
+		assertEventuallyGot(t, first, "Blind is 100\n")
+		assertEventuallyGot(t, second, "Blind is 100\n")
+	})
+
+	t.Run("tables without an ID get one", func(t *testing.T) {
+		registry, _ := newRegistry()
+
+		first, err := registry.Join("", &syncBuffer{})
+		poker.AssertNoError(t, err)
+		second, err := registry.Join("", &syncBuffer{})
+		poker.AssertNoError(t, err)
+
+		poker.AssertResponseBody(t, first.ID, "table-1")
+		poker.AssertResponseBody(t, second.ID, "table-2")
+	})
+
+	t.Run("it rejects bad table IDs", func(t *testing.T) {
+		registry, _ := newRegistry()
+
+		for _, id := range []string{"42", "cleo's table", "../games"} {
+			_, err := registry.Join(id, &syncBuffer{})
+			poker.AssertError(t, err, poker.ErrBadTableID)
+		}
+	})
+
+	t.Run("the table closes when the last client leaves", func(t *testing.T) {
+		registry, games := newRegistry()
+		first, second := &syncBuffer{}, &syncBuffer{}
+
+		table, _ := registry.Join("cleos", first)
+		registry.Join("cleos", second)
+		table.Start(3)
+
+		registry.Leave(table, first)
+		if _, ok := registry.Table("cleos"); !ok {
+			t.Fatal("expected the table to stay open while a client is at it")
+		}
+
+		registry.Leave(table, second)
+		if _, ok := registry.Table("cleos"); ok {
+			t.Error("expected the table to close")
+		}
+		poker.AssertStartContextCancelled(t, (*games)[0])
+	})
+
+	t.Run("a table is only finished once", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		registry := poker.NewGameRegistry(func() poker.Game {
+			return poker.NewTexasHoldem(dummyBlindAlerter, store)
+		})
+
+		table, _ := registry.Join("cleos", &syncBuffer{})
+		table.Start(3)
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := table.Finish("Cleo", "Chris")
+				errs <- err
+			}()
+		}
+
+		first, second := <-errs, <-errs
+		if first != nil {
+			first, second = second, first
+		}
+		poker.AssertNoError(t, first)
+		poker.AssertError(t, second, poker.ErrTableFinished)
+
+		_, err := table.Finish("Cleo")
+		poker.AssertError(t, err, poker.ErrTableFinished)
+
+		if len(store.GameCalls) != 1 {
+			t.Errorf("got %d games recorded want 1", len(store.GameCalls))
+		}
+	})
+
+	t.Run("the table closes when its game finishes", func(t *testing.T) {
+		registry, games := newRegistry()
+		client := &closingBuffer{}
+
+		table, _ := registry.Join("cleos", client)
+		table.Start(3)
+
+		_, err := table.Finish("Cleo", "Chris")
+		poker.AssertNoError(t, err)
+
+		poker.AssertFinishCalledWith(t, (*games)[0], "Cleo")
+		assertEventuallyGot(t, client, "Cleo wins over Chris\n")
+		if _, ok := registry.Table("cleos"); ok {
+			t.Error("expected the table to close")
+		}
+		if !retryUntilTrue(client.Closed) {
+			t.Error("expected the client to be sent away")
+		}
+		poker.AssertStartContextCancelled(t, (*games)[0])
+	})
+
+	t.Run("spectators are sent what the players are", func(t *testing.T) {
+		registry, games := newRegistry()
+		player, spectator := &syncBuffer{}, &syncBuffer{}
+
+		_, err := registry.Watch("cleos", spectator)
+		poker.AssertError(t, err, poker.ErrTableNotFound)
+
+		table, _ := registry.Join("cleos", player)
+		_, err = registry.Watch("cleos", spectator)
+		poker.AssertNoError(t, err)
+
+		(*games)[0].BlindAlert = []byte("Blind is 100\n")
+		table.Start(3)
+
+		status := table.Status()
+		if status.Clients != 1 || status.Spectators != 1 {
+			t.Errorf("got %+v want a player and a spectator", status)
+		}
+
+		registry.Leave(table, spectator)
+		assertEventuallyGot(t, spectator, "Blind is 100\n")
+	})
+
+	t.Run("players put out are sent to everyone and finish as the runners up", func(t *testing.T) {
+		registry, games := newRegistry()
+		client := &syncBuffer{}
+
+		table, _ := registry.Join("cleos", client)
+		poker.AssertError(t, table.Eliminate("Chris"), poker.ErrGameNotStarted)
+
+		table.Start(3)
+		poker.AssertNoError(t, table.Eliminate("Chris"))
+		poker.AssertError(t, table.Eliminate("Chris"), poker.ErrAlreadyOut)
+		poker.AssertNoError(t, table.Eliminate("Cleo"))
+		_, err := table.Finish("Ruth")
+		poker.AssertNoError(t, err)
+
+		poker.AssertFinishCalledWith(t, (*games)[0], "Ruth")
+		poker.AssertFinishRunnersUp(t, (*games)[0], "Cleo", "Chris")
+		assertEventuallyGot(t, client, "Chris is out in place 3\nCleo is out in place 2\nRuth wins over Cleo, Chris\n")
+	})
+
+	t.Run("it lists the active tables", func(t *testing.T) {
+		registry, _ := newRegistry()
+
+		cleos, _ := registry.Join("cleos", &syncBuffer{})
+		registry.Join("cleos", &syncBuffer{})
+		registry.Join("chriss", &syncBuffer{})
+		cleos.Start(3)
+
+		got := registry.Active()
+
+		if len(got) != 2 {
+			t.Fatalf("got %d tables want 2, %+v", len(got), got)
+		}
+		for _, table := range got {
+			switch table.ID {
+			case "cleos":
+				if table.Clients != 2 || !table.Started || table.NumberOfPlayers != 3 {
+					t.Errorf("got %+v for cleos", table)
+				}
+			case "chriss":
+				if table.Clients != 1 || table.Started {
+					t.Errorf("got %+v for chriss", table)
+				}
+			default:
+				t.Errorf("didn't expect table %q", table.ID)
+			}
+		}
+	})
+}
+
+func TestGameRegistry_Sessions(t *testing.T) {
+	newRegistry := func(sessions poker.SessionStore, keepFor time.Duration) *poker.GameRegistry {
+		registry := poker.NewGameRegistry(func() poker.Game {
+			return poker.NewTexasHoldem(&poker.SpyBlindAlerter{}, &poker.StubPlayerStore{})
+		})
+		registry.SetSessionStore(sessions, keepFor)
+		return registry
+	}
+
+	t.Run("games in play are saved until they finish", func(t *testing.T) {
+		sessions := &poker.StubSessionStore{}
+		registry := newRegistry(sessions, time.Minute)
+		client := &syncBuffer{}
+
+		table, err := registry.Join("cleos", client)
+		poker.AssertNoError(t, err)
+		if _, saved := sessions.Session("cleos"); saved {
+			t.Error("didn't expect a game that hasn't started to be saved")
+		}
+
+		table.Start(3)
+		poker.AssertNoError(t, table.Eliminate("Chris"))
+		poker.AssertNoError(t, table.Clock("pause", client))
+
+		session, saved := sessions.Session("cleos")
+		if !saved {
+			t.Fatal("expected the game to be saved")
+		}
+		if session.Token != table.Token || session.Game.NumberOfPlayers != 3 || !session.Game.Clock.Paused || !reflect.DeepEqual(session.Out, []string{"Chris"}) {
+			t.Errorf("got %+v", session)
+		}
+
+		_, err = table.Finish("Ruth")
+		poker.AssertNoError(t, err)
+		if _, saved := sessions.Session("cleos"); saved {
+			t.Error("expected the session to be deleted once the game finished")
+		}
+	})
+
+	t.Run("players are sent the session to rejoin with", func(t *testing.T) {
+		registry := newRegistry(&poker.StubSessionStore{}, time.Minute)
+		client := &syncBuffer{}
+
+		table, _ := registry.Join("cleos", client)
+
+		if table.Token == "" || !strings.HasPrefix(client.String(), "Rejoin table cleos with session "+table.Token+"\n") {
+			t.Errorf("got %q, want the session with token %q", client.String(), table.Token)
+		}
+	})
+
+	t.Run("a table is kept for its players to rejoin with its session", func(t *testing.T) {
+		registry := newRegistry(&poker.StubSessionStore{}, time.Minute)
+		first := &syncBuffer{}
+
+		table, _ := registry.Join("cleos", first)
+		table.Start(3)
+		registry.Leave(table, first)
+
+		if _, ok := registry.Table("cleos"); !ok {
+			t.Fatal("expected the table to be kept")
+		}
+
+		again, err := registry.Rejoin(table.Token, &syncBuffer{})
+		poker.AssertNoError(t, err)
+		if again != table {
+			t.Error("expected to rejoin the same table")
+		}
+
+		_, err = registry.Rejoin("not-the-token", &syncBuffer{})
+		poker.AssertError(t, err, poker.ErrBadSession)
+
+		_, err = registry.Rejoin(table.Token[:len(table.Token)-1], &syncBuffer{})
+		poker.AssertError(t, err, poker.ErrBadSession)
+	})
+
+	t.Run("a table nobody rejoins is closed", func(t *testing.T) {
+		sessions := &poker.StubSessionStore{}
+		registry := newRegistry(sessions, 10*time.Millisecond)
+		client := &syncBuffer{}
+
+		table, _ := registry.Join("cleos", client)
+		table.Start(3)
+		registry.Leave(table, client)
+
+		within(t, 500*time.Millisecond, func() {
+			for {
+				if _, ok := registry.Table("cleos"); !ok {
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+		if _, saved := sessions.Session("cleos"); saved {
+			t.Error("expected the session to be deleted")
+		}
+	})
+
+	t.Run("saved tables are restored with their games where they were", func(t *testing.T) {
+		sessions := &poker.StubSessionStore{}
+		before := newRegistry(sessions, time.Minute)
+		client := &syncBuffer{}
+
+		table, _ := before.Join("cleos", client)
+		table.Start(3)
+		poker.AssertNoError(t, table.Clock("next", client))
+		poker.AssertNoError(t, table.Eliminate("Chris"))
+
+		after := newRegistry(sessions, time.Minute)
+		restored, err := after.Restore()
+		poker.AssertNoError(t, err)
+		if restored != 1 {
+			t.Fatalf("got %d tables restored want 1", restored)
+		}
+
+		again, err := after.Rejoin(table.Token, &syncBuffer{})
+		poker.AssertNoError(t, err)
+
+		status := again.Status()
+		if again.ID != "cleos" || !status.Started || status.NumberOfPlayers != 3 || status.Level != 2 || !reflect.DeepEqual(status.Out, []string{"Chris"}) {
+			t.Errorf("got %+v", status)
+		}
+	})
+
+	t.Run("sessions whose games can't be restored are deleted", func(t *testing.T) {
+		sessions := &poker.StubSessionStore{}
+		sessions.SaveSession(poker.TableSession{ID: "cleos", Token: "abc", Game: poker.GameSnapshot{NumberOfPlayers: 3}})
+		registry := poker.NewGameRegistry(func() poker.Game { return &poker.GameSpy{} })
+		registry.SetSessionStore(sessions, time.Minute)
+
+		restored, err := registry.Restore()
+		poker.AssertNoError(t, err)
+
+		if restored != 0 {
+			t.Errorf("got %d tables restored want 0", restored)
+		}
+		if _, saved := sessions.Session("cleos"); saved {
+			t.Error("expected the session to be deleted")
+		}
+	})
+}
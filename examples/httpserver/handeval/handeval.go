@@ -0,0 +1,287 @@
+// Package handeval ranks poker hands of five to seven cards.
+//
+// Cards are folded into a 13 bit mask of ranks for each suit, and tables
+// indexed by those masks give the best straight, the number of ranks and the
+// highest ranks in them, so no combinations of five cards are tried.
+package handeval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+)
+
+var (
+	ErrCardCount     = errors.New("a hand is ranked from 5 to 7 cards")
+	ErrDuplicateCard = errors.New("the same card can't be in a hand twice")
+	ErrInvalidCard   = errors.New("a card needs a rank from two to ace and one of the four suits")
+)
+
+type Category int
+
+const (
+	HighCard Category = iota
+	Pair
+	TwoPair
+	ThreeOfAKind
+	Straight
+	Flush
+	FullHouse
+	FourOfAKind
+	StraightFlush
+)
+
+func (c Category) String() string {
+	return [...]string{
+		"high card", "pair", "two pair", "three of a kind", "straight",
+		"flush", "full house", "four of a kind", "straight flush",
+	}[c]
+}
+
+// Value is how good the best five cards of a hand are. A better hand has a
+// higher value and hands that split a pot have the same value.
+type Value uint32
+
+func (v Value) Category() Category {
+	return Category(v >> 20)
+}
+
+// ranks are the ranks of the five cards that decide the value, in the order
+// they count, like the pair then the kickers.
+func (v Value) ranks() [5]holdem.Rank {
+	var ranks [5]holdem.Rank
+	for i := range ranks {
+		ranks[i] = holdem.Rank(v >> uint(16-4*i) & 0xf)
+	}
+	return ranks
+}
+
+func (v Value) String() string {
+	r := v.ranks()
+	switch v.Category() {
+	case StraightFlush:
+		if r[0] == holdem.Ace {
+			return "royal flush"
+		}
+		return fmt.Sprintf("straight flush, %s high", rankName(r[0]))
+	case FourOfAKind:
+		return fmt.Sprintf("four %s", rankNames(r[0]))
+	case FullHouse:
+		return fmt.Sprintf("full house, %s full of %s", rankNames(r[0]), rankNames(r[3]))
+	case Flush:
+		return fmt.Sprintf("flush, %s high", rankName(r[0]))
+	case Straight:
+		return fmt.Sprintf("straight, %s high", rankName(r[0]))
+	case ThreeOfAKind:
+		return fmt.Sprintf("three %s", rankNames(r[0]))
+	case TwoPair:
+		return fmt.Sprintf("two pair, %s and %s", rankNames(r[0]), rankNames(r[2]))
+	case Pair:
+		return fmt.Sprintf("pair of %s", rankNames(r[0]))
+	default:
+		return fmt.Sprintf("high card %s", rankName(r[0]))
+	}
+}
+
+func rankName(r holdem.Rank) string {
+	return [...]string{"two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "jack", "queen", "king", "ace"}[r-2]
+}
+
+func rankNames(r holdem.Rank) string {
+	if r == 6 {
+		return "sixes"
+	}
+	return rankName(r) + "s"
+}
+
+const allRanks = 1 << 13
+
+var (
+	// bitCount is how many ranks are in a mask.
+	bitCount [allRanks]uint8
+	// highBit is the highest rank in a mask, counting from 0 for a two.
+	highBit [allRanks]uint8
+	// straightHigh is the rank of the top card of the best straight in a
+	// mask, or 0 when there isn't one.
+	straightHigh [allRanks]holdem.Rank
+)
+
+func init() {
+	for mask := 1; mask < allRanks; mask++ {
+		bitCount[mask] = bitCount[mask>>1] + uint8(mask&1)
+		if mask > 1 {
+			highBit[mask] = highBit[mask>>1] + 1
+		}
+
+		for high := holdem.Ace; high >= 6; high-- {
+			run := 0x1f << uint(high-6)
+			if mask&run == run {
+				straightHigh[mask] = high
+				break
+			}
+		}
+
+		wheel := 1<<12 | 0xf
+		if straightHigh[mask] == 0 && mask&wheel == wheel {
+			straightHigh[mask] = 5
+		}
+	}
+}
+
+// Evaluate is the value of the best five cards out of five to seven.
+func Evaluate(cards []holdem.Card) (Value, error) {
+	if len(cards) < 5 || len(cards) > 7 {
+		return 0, ErrCardCount
+	}
+
+	var suits [4]uint16
+	var counts [13]uint8
+	for _, card := range cards {
+		if card.Rank < 2 || card.Rank > holdem.Ace || card.Suit < holdem.Clubs || card.Suit > holdem.Spades {
+			return 0, ErrInvalidCard
+		}
+
+		bit := uint16(1) << uint(card.Rank-2)
+		if suits[card.Suit]&bit != 0 {
+			return 0, ErrDuplicateCard
+		}
+		suits[card.Suit] |= bit
+		counts[card.Rank-2]++
+	}
+
+	return evaluate(suits, counts), nil
+}
+
+func evaluate(suits [4]uint16, counts [13]uint8) Value {
+	ranks := suits[0] | suits[1] | suits[2] | suits[3]
+
+	// Seven cards can't hold a flush as well as four of a kind or a full
+	// house, so a flush is the best hand unless it is also a straight.
+	for _, suit := range suits {
+		if bitCount[suit] >= 5 {
+			if high := straightHigh[suit]; high != 0 {
+				return value(StraightFlush, high)
+			}
+			return value(Flush, top(suit, 5)...)
+		}
+	}
+
+	var quads, trips, pairs []holdem.Rank
+	for i := 12; i >= 0; i-- {
+		switch counts[i] {
+		case 4:
+			quads = append(quads, holdem.Rank(i+2))
+		case 3:
+			trips = append(trips, holdem.Rank(i+2))
+		case 2:
+			pairs = append(pairs, holdem.Rank(i+2))
+		}
+	}
+
+	switch {
+	case len(quads) > 0:
+		q := quads[0]
+		return value(FourOfAKind, append([]holdem.Rank{q, q, q, q}, top(without(ranks, q), 1)...)...)
+	case len(trips) > 0 && len(trips)+len(pairs) > 1:
+		t, p := trips[0], holdem.Rank(0)
+		if len(trips) > 1 {
+			p = trips[1]
+		}
+		if len(pairs) > 0 && pairs[0] > p {
+			p = pairs[0]
+		}
+		return value(FullHouse, t, t, t, p, p)
+	case straightHigh[ranks] != 0:
+		high := straightHigh[ranks]
+		return value(Straight, high)
+	case len(trips) > 0:
+		t := trips[0]
+		return value(ThreeOfAKind, append([]holdem.Rank{t, t, t}, top(without(ranks, t), 2)...)...)
+	case len(pairs) > 1:
+		a, b := pairs[0], pairs[1]
+		return value(TwoPair, append([]holdem.Rank{a, a, b, b}, top(without(ranks, a, b), 1)...)...)
+	case len(pairs) > 0:
+		p := pairs[0]
+		return value(Pair, append([]holdem.Rank{p, p}, top(without(ranks, p), 3)...)...)
+	default:
+		return value(HighCard, top(ranks, 5)...)
+	}
+}
+
+// value packs a category and up to five ranks so that comparing values
+// compares the category first and then each rank in turn. A straight only
+// needs its top card.
+func value(category Category, ranks ...holdem.Rank) Value {
+	v := Value(category) << 20
+	for i, r := range ranks {
+		v |= Value(r) << uint(16-4*i)
+	}
+	return v
+}
+
+// Hand is the cards a player can make their best five from at a showdown,
+// their hole cards and the board.
+type Hand struct {
+	Name  string
+	Cards []holdem.Card
+}
+
+type Result struct {
+	Name  string
+	Value Value
+}
+
+// Rank orders hands from best to worst, hands of the same value staying in
+// the order they were given.
+func Rank(hands []Hand) ([]Result, error) {
+	results := make([]Result, len(hands))
+	for i, hand := range hands {
+		v, err := Evaluate(hand.Cards)
+		if err != nil {
+			return nil, fmt.Errorf("problem ranking %s's hand, %v", hand.Name, err)
+		}
+		results[i] = Result{Name: hand.Name, Value: v}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Value > results[j].Value
+	})
+	return results, nil
+}
+
+// Winners are the players with the best hand, more than one when they split
+// the pot.
+func Winners(hands []Hand) ([]string, error) {
+	results, err := Rank(hands)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+
+	var winners []string
+	for _, result := range results {
+		if result.Value != results[0].Value {
+			break
+		}
+		winners = append(winners, result.Name)
+	}
+	return winners, nil
+}
+
+func top(mask uint16, n int) []holdem.Rank {
+	ranks := make([]holdem.Rank, 0, n)
+	for len(ranks) < n && mask != 0 {
+		high := highBit[mask]
+		ranks = append(ranks, holdem.Rank(high+2))
+		mask &^= 1 << high
+	}
+	return ranks
+}
+
+func without(mask uint16, ranks ...holdem.Rank) uint16 {
+	for _, r := range ranks {
+		mask &^= 1 << uint(r-2)
+	}
+	return mask
+}
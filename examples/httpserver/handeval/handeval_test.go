@@ -0,0 +1,210 @@
+package handeval_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/handeval"
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		cards    string
+		category handeval.Category
+		describe string
+	}{
+		{"As Ks Qs Js Ts 2d 3c", handeval.StraightFlush, "royal flush"},
+		{"9h 8h 7h 6h 5h Ah Kh", handeval.StraightFlush, "straight flush, nine high"},
+		{"5d 4d 3d 2d Ad", handeval.StraightFlush, "straight flush, five high"},
+		{"Qc Qd Qh Qs 2c 7d 9h", handeval.FourOfAKind, "four queens"},
+		{"Kc Kd Kh 7s 7c 2d 3h", handeval.FullHouse, "full house, kings full of sevens"},
+		{"6c 6d 6h 4s 4c 4d 2h", handeval.FullHouse, "full house, sixes full of fours"},
+		{"Ac 9c 7c 4c 2c Kd Qh", handeval.Flush, "flush, ace high"},
+		{"Tc 9d 8h 7s 6c 2d 2h", handeval.Straight, "straight, ten high"},
+		{"Ac 2d 3h 4s 5c Kd Kh", handeval.Straight, "straight, five high"},
+		{"8c 8d 8h As 3c", handeval.ThreeOfAKind, "three eights"},
+		{"Jc Jd 4h 4s Ac 4d Kh", handeval.FullHouse, "full house, fours full of jacks"},
+		{"Jc Jd 4h 4s Ac 2d 2h", handeval.TwoPair, "two pair, jacks and fours"},
+		{"Tc Td 4h 5s Ac 8d Kh", handeval.Pair, "pair of tens"},
+		{"Ac Jd 9h 7s 5c 3d 2h", handeval.HighCard, "high card ace"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.cards, func(t *testing.T) {
+			v := evaluate(t, c.cards)
+
+			if v.Category() != c.category {
+				t.Errorf("got %v, want %v", v.Category(), c.category)
+			}
+			if v.String() != c.describe {
+				t.Errorf("got %q, want %q", v.String(), c.describe)
+			}
+		})
+	}
+
+	t.Run("breaks ties with the kickers", func(t *testing.T) {
+		assertBetter(t, "Ac Ad Kh 7s 5c 3d 2h", "Ac Ad Qh 7s 5c 3d 2h")
+		assertBetter(t, "Jc Jd 4h 4s Ac 2d 3h", "Jc Jd 4h 4s Kc 2d 3h")
+		assertBetter(t, "Ac 9c 7c 4c 3c", "Ac 9c 7c 4c 2c")
+		assertBetter(t, "6c 2d 3h 4s 5c", "Ac 2d 3h 4s 5c")
+		assertBetter(t, "2c 2d 3h 3s 4c", "Ac Ad Kh Qs Jc 9d")
+	})
+
+	t.Run("values the same five cards the same", func(t *testing.T) {
+		board := "Ac Kd Qh Js 9c"
+		if evaluate(t, board+" 2c 3d") != evaluate(t, board+" 2h 4d") {
+			t.Error("expected hands playing the board to tie")
+		}
+	})
+
+	t.Run("rejects the wrong number of cards or the same card twice", func(t *testing.T) {
+		for _, cards := range []string{"Ac Kd Qh Js", "Ac Kd Qh Js 9c 8c 7c 6c"} {
+			if _, err := handeval.Evaluate(parse(t, cards)); err != handeval.ErrCardCount {
+				t.Errorf("got error %v for %s, want %v", err, cards, handeval.ErrCardCount)
+			}
+		}
+
+		if _, err := handeval.Evaluate(parse(t, "Ac Ac Qh Js 9c")); err != handeval.ErrDuplicateCard {
+			t.Errorf("got error %v, want %v", err, handeval.ErrDuplicateCard)
+		}
+	})
+
+	t.Run("rejects cards that aren't in a deck", func(t *testing.T) {
+		for _, bad := range []holdem.Card{{Rank: 1}, {Rank: 15}, {Rank: 0}, {Rank: holdem.Ace, Suit: 4}, {Rank: 2, Suit: -1}} {
+			cards := append(parse(t, "Ac Kd Qh Js"), bad)
+			if _, err := handeval.Evaluate(cards); err != handeval.ErrInvalidCard {
+				t.Errorf("got error %v for %+v, want %v", err, bad, handeval.ErrInvalidCard)
+			}
+		}
+	})
+
+	t.Run("agrees with the best of every five of seven cards", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			deck := holdem.NewDeck(rng)
+			var cards []holdem.Card
+			for j := 0; j < 7; j++ {
+				card, _ := deck.Deal()
+				cards = append(cards, card)
+			}
+
+			got, _ := handeval.Evaluate(cards)
+			if want := bestOfFive(cards); got != want {
+				t.Fatalf("got %v for %v, want %v", got, cards, want)
+			}
+		}
+	})
+}
+
+func TestWinners(t *testing.T) {
+	board := "Kh 9d 5c 2s 2h"
+
+	t.Run("the best hand wins", func(t *testing.T) {
+		winners, err := handeval.Winners([]handeval.Hand{
+			{Name: "Cleo", Cards: parse(t, "Ks Qd "+board)},
+			{Name: "Chris", Cards: parse(t, "Kc Jd "+board)},
+			{Name: "Ruth", Cards: parse(t, "9s 9c "+board)},
+		})
+
+		assertNoError(t, err)
+		assertWinners(t, winners, "Ruth")
+	})
+
+	t.Run("hands of the same value split the pot", func(t *testing.T) {
+		winners, err := handeval.Winners([]handeval.Hand{
+			{Name: "Cleo", Cards: parse(t, "Ks 4d "+board)},
+			{Name: "Chris", Cards: parse(t, "Qs Jd "+board)},
+			{Name: "Ruth", Cards: parse(t, "Kc 3d "+board)},
+		})
+
+		assertNoError(t, err)
+		assertWinners(t, winners, "Cleo", "Ruth")
+	})
+
+	t.Run("ranks every hand from best to worst", func(t *testing.T) {
+		results, err := handeval.Rank([]handeval.Hand{
+			{Name: "Cleo", Cards: parse(t, "5s 4d "+board)},
+			{Name: "Chris", Cards: parse(t, "Qs Jd "+board)},
+			{Name: "Ruth", Cards: parse(t, "Kc 3d "+board)},
+		})
+
+		assertNoError(t, err)
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+		assertWinners(t, names, "Ruth", "Cleo", "Chris")
+	})
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	hands := make([][]holdem.Card, 1000)
+	for i := range hands {
+		deck := holdem.NewDeck(rng)
+		for j := 0; j < 7; j++ {
+			card, _ := deck.Deal()
+			hands[i] = append(hands[i], card)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handeval.Evaluate(hands[i%len(hands)])
+	}
+}
+
+func bestOfFive(cards []holdem.Card) handeval.Value {
+	var best handeval.Value
+	for skip1 := 0; skip1 < len(cards); skip1++ {
+		for skip2 := skip1 + 1; skip2 < len(cards); skip2++ {
+			var five []holdem.Card
+			for i, card := range cards {
+				if i != skip1 && i != skip2 {
+					five = append(five, card)
+				}
+			}
+			if v, _ := handeval.Evaluate(five); v > best {
+				best = v
+			}
+		}
+	}
+	return best
+}
+
+func evaluate(t *testing.T, cards string) handeval.Value {
+	t.Helper()
+	v, err := handeval.Evaluate(parse(t, cards))
+	assertNoError(t, err)
+	return v
+}
+
+func parse(t *testing.T, s string) []holdem.Card {
+	t.Helper()
+	cards, err := holdem.ParseCards(s)
+	assertNoError(t, err)
+	return cards
+}
+
+func assertBetter(t *testing.T, better, worse string) {
+	t.Helper()
+	if b, w := evaluate(t, better), evaluate(t, worse); b <= w {
+		t.Errorf("expected %s (%v) to beat %s (%v)", better, b, worse, w)
+	}
+}
+
+func assertWinners(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
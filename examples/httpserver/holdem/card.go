@@ -0,0 +1,79 @@
+package holdem
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Suit int
+
+const (
+	Clubs Suit = iota
+	Diamonds
+	Hearts
+	Spades
+)
+
+// Rank is the value of a card, from 2 up to 14 for an ace.
+type Rank int
+
+const (
+	Jack  Rank = 11
+	Queen Rank = 12
+	King  Rank = 13
+	Ace   Rank = 14
+)
+
+const ranks = "23456789TJQKA"
+const suits = "cdhs"
+
+type Card struct {
+	Rank Rank
+	Suit Suit
+}
+
+// String writes a card like As for the ace of spades or Td for the ten of
+// diamonds.
+func (c Card) String() string {
+	return string(ranks[c.Rank-2]) + string(suits[c.Suit])
+}
+
+func ParseCard(s string) (Card, error) {
+	if len(s) != 2 {
+		return Card{}, fmt.Errorf("%q isn't a card like As or Td", s)
+	}
+
+	rank := strings.IndexByte(ranks, strings.ToUpper(s[:1])[0])
+	suit := strings.IndexByte(suits, strings.ToLower(s[1:])[0])
+	if rank == -1 || suit == -1 {
+		return Card{}, fmt.Errorf("%q isn't a card like As or Td", s)
+	}
+
+	return Card{Rank: Rank(rank + 2), Suit: Suit(suit)}, nil
+}
+
+// ParseCards reads cards separated by spaces.
+func ParseCards(s string) ([]Card, error) {
+	var cards []Card
+	for _, field := range strings.Fields(s) {
+		card, err := ParseCard(field)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func (c Card) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Card) UnmarshalText(text []byte) error {
+	card, err := ParseCard(string(text))
+	if err != nil {
+		return err
+	}
+	*c = card
+	return nil
+}
@@ -0,0 +1,49 @@
+package holdem
+
+import (
+	"errors"
+	"math/rand"
+)
+
+var ErrDeckEmpty = errors.New("no cards left in the deck")
+
+type Deck struct {
+	cards []Card
+}
+
+// NewDeck is all 52 cards shuffled by rng, so a seeded rng always deals the
+// same cards.
+func NewDeck(rng *rand.Rand) *Deck {
+	cards := make([]Card, 0, 52)
+	for suit := Clubs; suit <= Spades; suit++ {
+		for rank := Rank(2); rank <= Ace; rank++ {
+			cards = append(cards, Card{Rank: rank, Suit: suit})
+		}
+	}
+
+	rng.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+
+	return &Deck{cards: cards}
+}
+
+// NewStackedDeck deals cards in the order given, which is handy for setting
+// up a hand.
+func NewStackedDeck(cards ...Card) *Deck {
+	return &Deck{cards: append([]Card(nil), cards...)}
+}
+
+func (d *Deck) Deal() (Card, error) {
+	if len(d.cards) == 0 {
+		return Card{}, ErrDeckEmpty
+	}
+
+	card := d.cards[0]
+	d.cards = d.cards[1:]
+	return card, nil
+}
+
+func (d *Deck) Len() int {
+	return len(d.cards)
+}
@@ -0,0 +1,88 @@
+package holdem_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+)
+
+func TestDeck(t *testing.T) {
+	t.Run("has every card once", func(t *testing.T) {
+		cards := dealAll(holdem.NewDeck(rand.New(rand.NewSource(1))))
+
+		if len(cards) != 52 {
+			t.Fatalf("got %d cards, want 52", len(cards))
+		}
+
+		seen := map[holdem.Card]bool{}
+		for _, card := range cards {
+			if seen[card] {
+				t.Errorf("%v was dealt twice", card)
+			}
+			seen[card] = true
+		}
+	})
+
+	t.Run("deals the same cards for the same seed", func(t *testing.T) {
+		first := dealAll(holdem.NewDeck(rand.New(rand.NewSource(7))))
+		second := dealAll(holdem.NewDeck(rand.New(rand.NewSource(7))))
+		other := dealAll(holdem.NewDeck(rand.New(rand.NewSource(8))))
+
+		if !reflect.DeepEqual(first, second) {
+			t.Error("decks with the same seed were shuffled differently")
+		}
+		if reflect.DeepEqual(first, other) {
+			t.Error("decks with different seeds were shuffled the same")
+		}
+	})
+
+	t.Run("runs out of cards", func(t *testing.T) {
+		deck := holdem.NewStackedDeck(cards(t, "As")...)
+		deck.Deal()
+
+		_, err := deck.Deal()
+		if err != holdem.ErrDeckEmpty {
+			t.Errorf("got error %v, want %v", err, holdem.ErrDeckEmpty)
+		}
+	})
+}
+
+func TestParseCard(t *testing.T) {
+	for _, name := range []string{"As", "Td", "2c", "Kh"} {
+		card, err := holdem.ParseCard(name)
+		if err != nil {
+			t.Fatalf("didn't expect an error parsing %s, %v", name, err)
+		}
+		if card.String() != name {
+			t.Errorf("got %s, want %s", card, name)
+		}
+	}
+
+	for _, name := range []string{"", "A", "1s", "Ax", "10s"} {
+		if _, err := holdem.ParseCard(name); err == nil {
+			t.Errorf("expected an error parsing %q", name)
+		}
+	}
+}
+
+func dealAll(deck *holdem.Deck) []holdem.Card {
+	var dealt []holdem.Card
+	for {
+		card, err := deck.Deal()
+		if err != nil {
+			return dealt
+		}
+		dealt = append(dealt, card)
+	}
+}
+
+func cards(t *testing.T, s string) []holdem.Card {
+	t.Helper()
+	parsed, err := holdem.ParseCards(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
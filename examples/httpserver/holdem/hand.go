@@ -0,0 +1,632 @@
+package holdem
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrNotEnoughPlayers = errors.New("a hand needs at least two players with chips")
+	ErrTooManyPlayers   = errors.New("there aren't enough cards for that many players")
+	ErrDuplicatePlayer  = errors.New("a player can only have one seat in a hand")
+	ErrHandOver         = errors.New("the hand is over")
+	ErrNotYourTurn      = errors.New("it isn't that player's turn")
+	ErrPlayerNotInHand  = errors.New("player isn't in the hand")
+	ErrNotShowdown      = errors.New("the hand hasn't reached a showdown")
+)
+
+type Street int
+
+const (
+	Preflop Street = iota
+	Flop
+	Turn
+	River
+	Showdown
+)
+
+func (s Street) String() string {
+	switch s {
+	case Preflop:
+		return "preflop"
+	case Flop:
+		return "flop"
+	case Turn:
+		return "turn"
+	case River:
+		return "river"
+	default:
+		return "showdown"
+	}
+}
+
+func (s Street) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type ActionType string
+
+const (
+	Fold  ActionType = "fold"
+	Check ActionType = "check"
+	Call  ActionType = "call"
+	Raise ActionType = "raise"
+	AllIn ActionType = "all-in"
+)
+
+// Action is what a player does on their turn. The Amount of a Raise is what
+// their bet on this street is raised to, and a raise when nobody has bet yet
+// is a bet.
+type Action struct {
+	Type   ActionType `json:"type"`
+	Amount int        `json:"amount,omitempty"`
+}
+
+// IllegalActionError is an action that the rules don't allow right now.
+type IllegalActionError struct {
+	Action Action
+	Reason string
+}
+
+func (e IllegalActionError) Error() string {
+	return fmt.Sprintf("can't %s, %s", e.Action.Type, e.Reason)
+}
+
+// Seat is a player sitting down to a hand with a stack of chips.
+type Seat struct {
+	Name  string `json:"name"`
+	Stack int    `json:"stack"`
+}
+
+// Blinds are the forced bets of a hand. Every player pays the Ante into the
+// pot, and the Small and Big blinds are bets by the two players after the
+// button.
+type Blinds struct {
+	Small int `json:"small"`
+	Big   int `json:"big"`
+	Ante  int `json:"ante,omitempty"`
+}
+
+type player struct {
+	name      string
+	stack     int
+	hole      []Card
+	bet       int
+	committed int
+	folded    bool
+	allIn     bool
+	acted     bool
+	canRaise  bool
+	won       int
+}
+
+// Hand is one hand of Texas Hold'em, from the blinds to the showdown. Players
+// act in turn with Act and the hand deals the flop, turn and river as each
+// round of betting ends.
+type Hand struct {
+	players    []*player
+	button     int
+	blinds     Blinds
+	deck       *Deck
+	street     Street
+	board      []Card
+	toAct      int
+	currentBet int
+	minRaise   int
+	winners    []string
+	over       bool
+}
+
+// NewHand deals a hand to seats, in order around the table, with the button
+// in front of seats[button]. Seats without chips sit the hand out.
+func NewHand(seats []Seat, button int, blinds Blinds, deck *Deck) (*Hand, error) {
+	h := &Hand{blinds: blinds, deck: deck, button: -1, toAct: -1}
+
+	seated := make(map[string]bool, len(seats))
+	for _, seat := range seats {
+		if seated[seat.Name] {
+			return nil, ErrDuplicatePlayer
+		}
+		seated[seat.Name] = true
+	}
+
+	for i, seat := range seats {
+		if seat.Stack <= 0 {
+			continue
+		}
+		if i <= button%len(seats) {
+			h.button = len(h.players)
+		}
+		h.players = append(h.players, &player{name: seat.Name, stack: seat.Stack})
+	}
+
+	if len(h.players) < 2 {
+		return nil, ErrNotEnoughPlayers
+	}
+	if h.button == -1 {
+		h.button = len(h.players) - 1
+	}
+	if 2*len(h.players)+8 > deck.Len() {
+		return nil, ErrTooManyPlayers
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := range h.players {
+			card, _ := deck.Deal()
+			p := h.players[(h.button+1+i)%len(h.players)]
+			p.hole = append(p.hole, card)
+		}
+	}
+
+	for _, p := range h.players {
+		p.canRaise = true
+		h.put(p, blinds.Ante)
+		p.bet = 0
+	}
+
+	small := h.next(h.button)
+	if len(h.players) == 2 {
+		small = h.button
+	}
+	big := h.next(small)
+
+	h.put(h.players[small], blinds.Small)
+	h.put(h.players[big], blinds.Big)
+	h.currentBet = max(h.players[small].bet, h.players[big].bet)
+	h.minRaise = blinds.Big
+
+	h.toAct = h.next(big)
+	h.advance()
+	return h, nil
+}
+
+// Act takes the turn of the named player.
+func (h *Hand) Act(name string, action Action) error {
+	if h.over || h.toAct == -1 {
+		return ErrHandOver
+	}
+
+	p := h.players[h.toAct]
+	if p.name != name {
+		if h.find(name) == nil {
+			return ErrPlayerNotInHand
+		}
+		return ErrNotYourTurn
+	}
+
+	illegal := func(format string, a ...interface{}) error {
+		return IllegalActionError{Action: action, Reason: fmt.Sprintf(format, a...)}
+	}
+
+	switch action.Type {
+	case Fold:
+		p.folded = true
+	case Check:
+		if p.bet < h.currentBet {
+			return illegal("there's %d to call", h.currentBet-p.bet)
+		}
+	case Call:
+		if p.bet == h.currentBet {
+			return illegal("there's nothing to call")
+		}
+		h.put(p, h.currentBet-p.bet)
+	case Raise:
+		allIn := p.bet + p.stack
+		switch {
+		case !p.canRaise:
+			return illegal("nobody has raised since your last turn")
+		case action.Amount <= h.currentBet:
+			return illegal("the bet is already %d", h.currentBet)
+		case action.Amount > allIn:
+			return illegal("you only have %d", allIn)
+		case action.Amount < allIn && action.Amount < h.currentBet+h.minRaise:
+			return illegal("the smallest raise is to %d", h.currentBet+h.minRaise)
+		}
+		h.raiseTo(p, action.Amount)
+	case AllIn:
+		allIn := p.bet + p.stack
+		if allIn <= h.currentBet {
+			h.put(p, p.stack)
+			break
+		}
+		if !p.canRaise {
+			return illegal("nobody has raised since your last turn, call instead")
+		}
+		h.raiseTo(p, allIn)
+	default:
+		return illegal("there's no such action")
+	}
+
+	p.acted = true
+	p.canRaise = false
+
+	h.toAct = h.next(h.toAct)
+	h.advance()
+	return nil
+}
+
+// Settle shares the pots out at a showdown. ranking is the players from the
+// best hand to the worst, with players whose hands tie together, and each
+// pot goes to the best of the players who can win it.
+func (h *Hand) Settle(ranking ...[]string) error {
+	if h.over {
+		return ErrHandOver
+	}
+	if h.street != Showdown {
+		return ErrNotShowdown
+	}
+
+	pots := h.Pots()
+	shares := make([][]*player, len(pots))
+	for i, pot := range pots {
+		for _, tier := range ranking {
+			for _, name := range pot.Eligible {
+				if contains(tier, name) {
+					shares[i] = append(shares[i], h.find(name))
+				}
+			}
+			if len(shares[i]) > 0 {
+				break
+			}
+		}
+
+		if len(shares[i]) == 0 {
+			return ErrPlayerNotInHand
+		}
+	}
+
+	for i, pot := range pots {
+		h.pay(pot.Amount, shares[i]...)
+	}
+	h.finish()
+	return nil
+}
+
+// Pot is chips that only the Eligible players can win. Each player all in
+// for less than the others caps a pot, the chips above it going into a side
+// pot between the players who put them in.
+type Pot struct {
+	Amount   int      `json:"amount"`
+	Eligible []string `json:"eligible"`
+}
+
+// Pots are the main pot followed by any side pots.
+func (h *Hand) Pots() []Pot {
+	top := 0
+	caps := map[int]bool{}
+	for _, p := range h.players {
+		top = max(top, p.committed)
+		if p.allIn && !p.folded {
+			caps[p.committed] = true
+		}
+	}
+	caps[top] = true
+
+	var levels []int
+	for level := range caps {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+
+	var pots []Pot
+	below := 0
+	for _, level := range levels {
+		pot := Pot{}
+		for _, p := range h.players {
+			pot.Amount += min(p.committed, level) - min(p.committed, below)
+		}
+		for _, name := range h.Contenders() {
+			if h.find(name).committed >= level {
+				pot.Eligible = append(pot.Eligible, name)
+			}
+		}
+		below = level
+
+		if pot.Amount == 0 {
+			continue
+		}
+		if len(pot.Eligible) == 0 && len(pots) > 0 {
+			pots[len(pots)-1].Amount += pot.Amount
+			continue
+		}
+		pots = append(pots, pot)
+	}
+	return pots
+}
+
+// Done is whether the pot has been won.
+func (h *Hand) Done() bool {
+	return h.over
+}
+
+func (h *Hand) Street() Street {
+	return h.street
+}
+
+func (h *Hand) Board() []Card {
+	return append([]Card(nil), h.board...)
+}
+
+// HoleCards are the two cards dealt to a player.
+func (h *Hand) HoleCards(name string) ([]Card, error) {
+	p := h.find(name)
+	if p == nil {
+		return nil, ErrPlayerNotInHand
+	}
+	return append([]Card(nil), p.hole...), nil
+}
+
+// Contenders are the players still in the hand, in order from the button.
+func (h *Hand) Contenders() []string {
+	var names []string
+	for i := range h.players {
+		p := h.players[(h.button+1+i)%len(h.players)]
+		if !p.folded {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// Pot is every chip put in during the hand, which stays what the pot was
+// once it has been won.
+func (h *Hand) Pot() int {
+	pot := 0
+	for _, p := range h.players {
+		pot += p.committed
+	}
+	return pot
+}
+
+// Seats are the players and their stacks as they stand, which once the hand
+// is done can be dealt the next hand.
+func (h *Hand) Seats() []Seat {
+	return h.State().Seats()
+}
+
+// PlayerState is how a player stands in a hand. Their hole cards are only
+// shown once they are in a showdown.
+type PlayerState struct {
+	Name   string `json:"name"`
+	Stack  int    `json:"stack"`
+	Bet    int    `json:"bet,omitempty"`
+	Folded bool   `json:"folded,omitempty"`
+	AllIn  bool   `json:"allIn,omitempty"`
+	Cards  []Card `json:"cards,omitempty"`
+	Won    int    `json:"won,omitempty"`
+}
+
+// State is what everyone at the table can see of a hand. ToCall and MinRaise
+// are for the player whose turn it is.
+type State struct {
+	Street   Street        `json:"street"`
+	Board    []Card        `json:"board"`
+	Pot      int           `json:"pot"`
+	Button   string        `json:"button"`
+	Blinds   Blinds        `json:"blinds"`
+	ToAct    string        `json:"toAct,omitempty"`
+	ToCall   int           `json:"toCall,omitempty"`
+	MinRaise int           `json:"minRaise,omitempty"`
+	Pots     []Pot         `json:"pots"`
+	Players  []PlayerState `json:"players"`
+	Winners  []string      `json:"winners,omitempty"`
+}
+
+// Seats are the players with their stacks, ready to be dealt another hand.
+func (s State) Seats() []Seat {
+	seats := make([]Seat, len(s.Players))
+	for i, p := range s.Players {
+		seats[i] = Seat{Name: p.Name, Stack: p.Stack}
+	}
+	return seats
+}
+
+func (h *Hand) State() State {
+	state := State{
+		Street:  h.street,
+		Board:   h.Board(),
+		Pot:     h.Pot(),
+		Pots:    h.Pots(),
+		Button:  h.players[h.button].name,
+		Blinds:  h.blinds,
+		Winners: append([]string(nil), h.winners...),
+	}
+
+	if state.Board == nil {
+		state.Board = []Card{}
+	}
+
+	if !h.over && h.toAct != -1 {
+		p := h.players[h.toAct]
+		state.ToAct = p.name
+		state.ToCall = min(h.currentBet-p.bet, p.stack)
+		if p.canRaise && p.bet+p.stack > h.currentBet {
+			state.MinRaise = min(h.currentBet+h.minRaise, p.bet+p.stack)
+		}
+	}
+
+	for _, p := range h.players {
+		player := PlayerState{
+			Name:   p.name,
+			Stack:  p.stack,
+			Bet:    p.bet,
+			Folded: p.folded,
+			AllIn:  p.allIn,
+			Won:    p.won,
+		}
+		if h.street == Showdown && !p.folded {
+			player.Cards = append([]Card(nil), p.hole...)
+		}
+		state.Players = append(state.Players, player)
+	}
+	return state
+}
+
+func (h *Hand) put(p *player, amount int) {
+	amount = min(amount, p.stack)
+	p.stack -= amount
+	p.bet += amount
+	p.committed += amount
+	if p.stack == 0 {
+		p.allIn = true
+	}
+}
+
+// raiseTo makes p's bet to. A raise by at least the last raise reopens the
+// betting for everyone; a smaller all-in only asks them to call the
+// difference.
+func (h *Hand) raiseTo(p *player, to int) {
+	raise := to - h.currentBet
+	h.put(p, to-p.bet)
+	h.currentBet = to
+
+	full := raise >= h.minRaise
+	if full {
+		h.minRaise = raise
+	}
+
+	for _, other := range h.players {
+		if other == p {
+			continue
+		}
+		other.acted = false
+		if full {
+			other.canRaise = true
+		}
+	}
+}
+
+// advance moves the turn on to the next player who has to act, dealing the
+// next street when the betting is over and giving the pot away when
+// everyone else has folded.
+func (h *Hand) advance() {
+	for !h.over && h.street != Showdown {
+		contenders := h.Contenders()
+		if len(contenders) == 1 {
+			h.pay(h.Pot(), h.find(contenders[0]))
+			h.finish()
+			return
+		}
+
+		for i := range h.players {
+			next := (h.toAct + i) % len(h.players)
+			if h.needsToAct(h.players[next]) {
+				h.toAct = next
+				return
+			}
+		}
+
+		h.nextStreet()
+	}
+
+	h.toAct = -1
+}
+
+func (h *Hand) needsToAct(p *player) bool {
+	if p.folded || p.allIn {
+		return false
+	}
+
+	if p.acted {
+		return p.bet < h.currentBet
+	}
+
+	// Nobody is left to bet against a player who has everyone else covered.
+	for _, other := range h.players {
+		if other != p && !other.folded && !other.allIn {
+			return true
+		}
+	}
+	return p.bet < h.currentBet
+}
+
+func (h *Hand) nextStreet() {
+	for _, p := range h.players {
+		p.bet = 0
+		p.acted = false
+		p.canRaise = true
+	}
+	h.currentBet = 0
+	h.minRaise = h.blinds.Big
+
+	h.street++
+	if h.street == Showdown {
+		return
+	}
+
+	cards := 1
+	if h.street == Flop {
+		cards = 3
+	}
+
+	h.deck.Deal()
+	for i := 0; i < cards; i++ {
+		card, _ := h.deck.Deal()
+		h.board = append(h.board, card)
+	}
+
+	h.toAct = h.next(h.button)
+}
+
+// next is the seat after i in order of play.
+func (h *Hand) next(i int) int {
+	return (i + 1) % len(h.players)
+}
+
+func (h *Hand) find(name string) *player {
+	for _, p := range h.players {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// pay shares amount between winners, who are in order from the button, the
+// odd chips going to the first of them.
+func (h *Hand) pay(amount int, winners ...*player) {
+	share := amount / len(winners)
+	for i, p := range winners {
+		won := share
+		if i == 0 {
+			won += amount - share*len(winners)
+		}
+		p.stack += won
+		p.won += won
+	}
+}
+
+func (h *Hand) finish() {
+	for _, p := range h.players {
+		p.bet = 0
+		if p.won > 0 {
+			h.winners = append(h.winners, p.name)
+		}
+	}
+	h.over = true
+	h.toAct = -1
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
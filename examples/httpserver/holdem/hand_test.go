@@ -0,0 +1,359 @@
+package holdem_test
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver/holdem"
+)
+
+var blinds = holdem.Blinds{Small: 50, Big: 100}
+
+func TestNewHand(t *testing.T) {
+	t.Run("posts the blinds after the button and starts with the player after the big blind", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth", "Adam"), 0, blinds)
+
+		state := hand.State()
+		assertStreet(t, state.Street, holdem.Preflop)
+		assertToAct(t, state, "Adam", 100)
+		assertInt(t, "pot", state.Pot, 150)
+		assertInt(t, "Chris's bet", state.Players[1].Bet, 50)
+		assertInt(t, "Ruth's bet", state.Players[2].Bet, 100)
+		assertInt(t, "min raise", state.MinRaise, 200)
+	})
+
+	t.Run("deals two hole cards each, one at a time from after the button", func(t *testing.T) {
+		deck := holdem.NewStackedDeck(cards(t, "As Kd Qh Jc Ts 9d")...)
+		hand, err := holdem.NewHand(seats("Cleo", "Chris", "Ruth"), 0, blinds, padded(deck))
+		assertNoError(t, err)
+
+		assertHole(t, hand, "Chris", "As Jc")
+		assertHole(t, hand, "Ruth", "Kd Ts")
+		assertHole(t, hand, "Cleo", "Qh 9d")
+	})
+
+	t.Run("heads up the button posts the small blind and acts first", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris"), 1, blinds)
+
+		state := hand.State()
+		assertInt(t, "Chris's bet", state.Players[1].Bet, 50)
+		assertToAct(t, state, "Chris", 50)
+	})
+
+	t.Run("collects antes from everyone", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, holdem.Blinds{Small: 50, Big: 100, Ante: 10})
+
+		assertInt(t, "pot", hand.Pot(), 180)
+		assertToAct(t, hand.State(), "Cleo", 100)
+	})
+
+	t.Run("leaves out players without chips", func(t *testing.T) {
+		players := seats("Cleo", "Chris", "Ruth")
+		players[1].Stack = 0
+		hand := newHand(t, players, 0, blinds)
+
+		if got := hand.Contenders(); !reflect.DeepEqual(got, []string{"Ruth", "Cleo"}) {
+			t.Errorf("got contenders %v, want Ruth then Cleo", got)
+		}
+	})
+
+	t.Run("needs two players", func(t *testing.T) {
+		_, err := holdem.NewHand(seats("Cleo"), 0, blinds, holdem.NewDeck(rand.New(rand.NewSource(1))))
+		if err != holdem.ErrNotEnoughPlayers {
+			t.Errorf("got error %v, want %v", err, holdem.ErrNotEnoughPlayers)
+		}
+	})
+
+	t.Run("seats each player once", func(t *testing.T) {
+		_, err := holdem.NewHand(seats("Cleo", "Chris", "Cleo"), 0, blinds, holdem.NewDeck(rand.New(rand.NewSource(1))))
+		if err != holdem.ErrDuplicatePlayer {
+			t.Errorf("got error %v, want %v", err, holdem.ErrDuplicatePlayer)
+		}
+	})
+}
+
+func TestHandBetting(t *testing.T) {
+	t.Run("gives the pot to the last player left", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Fold})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Fold})
+
+		if !hand.Done() {
+			t.Fatal("expected the hand to be over")
+		}
+		assertWinners(t, hand.State(), "Ruth")
+		assertStacks(t, hand, 1000, 950, 1050)
+	})
+
+	t.Run("gives the big blind an option and deals the flop", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Call})
+		assertToAct(t, hand.State(), "Ruth", 0)
+		assertInt(t, "cards shown", len(hand.State().Players[0].Cards), 0)
+		act(t, hand, "Ruth", holdem.Action{Type: holdem.Check})
+
+		state := hand.State()
+		assertStreet(t, state.Street, holdem.Flop)
+		assertInt(t, "board", len(state.Board), 3)
+		assertInt(t, "pot", state.Pot, 300)
+		assertToAct(t, state, "Chris", 0)
+	})
+
+	t.Run("plays every street to a showdown", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Check})
+		for _, street := range []holdem.Street{holdem.Flop, holdem.Turn, holdem.River} {
+			assertStreet(t, hand.Street(), street)
+			act(t, hand, "Chris", holdem.Action{Type: holdem.Check})
+			act(t, hand, "Cleo", holdem.Action{Type: holdem.Check})
+		}
+
+		assertStreet(t, hand.Street(), holdem.Showdown)
+		assertInt(t, "board", len(hand.Board()), 5)
+
+		assertNoError(t, hand.Settle([]string{"Cleo", "Chris"}))
+		assertStacks(t, hand, 1000, 1000)
+		for _, player := range hand.State().Players {
+			assertInt(t, player.Name+"'s cards shown", len(player.Cards), 2)
+		}
+	})
+
+	t.Run("makes everyone answer a raise", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Raise, Amount: 300})
+		assertToAct(t, hand.State(), "Ruth", 200)
+		act(t, hand, "Ruth", holdem.Action{Type: holdem.Call})
+		assertToAct(t, hand.State(), "Cleo", 200)
+		assertInt(t, "min raise", hand.State().MinRaise, 500)
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+
+		assertStreet(t, hand.Street(), holdem.Flop)
+		assertInt(t, "pot", hand.Pot(), 900)
+	})
+
+	t.Run("rejects actions the rules don't allow", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, blinds)
+
+		assertError(t, hand.Act("Chris", holdem.Action{Type: holdem.Fold}), holdem.ErrNotYourTurn)
+		assertError(t, hand.Act("Adam", holdem.Action{Type: holdem.Fold}), holdem.ErrPlayerNotInHand)
+		assertIllegal(t, hand.Act("Cleo", holdem.Action{Type: holdem.Check}))
+		assertIllegal(t, hand.Act("Cleo", holdem.Action{Type: holdem.Raise, Amount: 150}))
+		assertIllegal(t, hand.Act("Cleo", holdem.Action{Type: holdem.Raise, Amount: 5000}))
+		assertToAct(t, hand.State(), "Cleo", 100)
+	})
+
+	t.Run("runs the board out when everyone is all in", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.AllIn})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Call})
+
+		assertStreet(t, hand.Street(), holdem.Showdown)
+		assertInt(t, "board", len(hand.Board()), 5)
+		assertInt(t, "pot", hand.Pot(), 2000)
+
+		assertNoError(t, hand.Settle([]string{"Chris"}))
+		assertStacks(t, hand, 0, 2000)
+	})
+
+	t.Run("a short all in doesn't reopen the betting", func(t *testing.T) {
+		players := seats("Cleo", "Chris", "Ruth")
+		players[2].Stack = 350
+		hand := newHand(t, players, 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Raise, Amount: 300})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Ruth", holdem.Action{Type: holdem.AllIn})
+
+		state := hand.State()
+		assertToAct(t, state, "Cleo", 50)
+		assertInt(t, "min raise", state.MinRaise, 0)
+		assertIllegal(t, hand.Act("Cleo", holdem.Action{Type: holdem.Raise, Amount: 1000}))
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Call})
+
+		assertStreet(t, hand.Street(), holdem.Flop)
+		assertToAct(t, hand.State(), "Chris", 0)
+	})
+
+	t.Run("can't settle before the showdown", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris"), 0, blinds)
+		assertError(t, hand.Settle([]string{"Cleo"}), holdem.ErrNotShowdown)
+	})
+}
+
+func TestHandPots(t *testing.T) {
+	allIn := func(t *testing.T) *holdem.Hand {
+		t.Helper()
+		players := []holdem.Seat{{Name: "Cleo", Stack: 1000}, {Name: "Chris", Stack: 300}, {Name: "Ruth", Stack: 600}}
+		hand := newHand(t, players, 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.AllIn})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.AllIn})
+		act(t, hand, "Ruth", holdem.Action{Type: holdem.AllIn})
+		return hand
+	}
+
+	t.Run("makes side pots for players all in for less", func(t *testing.T) {
+		hand := allIn(t)
+
+		want := []holdem.Pot{
+			{Amount: 900, Eligible: []string{"Chris", "Ruth", "Cleo"}},
+			{Amount: 600, Eligible: []string{"Ruth", "Cleo"}},
+			{Amount: 400, Eligible: []string{"Cleo"}},
+		}
+		if got := hand.Pots(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got pots %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("gives each pot to the best hand that can win it", func(t *testing.T) {
+		hand := allIn(t)
+
+		assertNoError(t, hand.Settle([]string{"Chris"}, []string{"Ruth"}, []string{"Cleo"}))
+
+		assertStacks(t, hand, 400, 900, 600)
+		assertWinners(t, hand.State(), "Cleo", "Chris", "Ruth")
+	})
+
+	t.Run("splits a pot between tied hands", func(t *testing.T) {
+		hand := allIn(t)
+
+		assertNoError(t, hand.Settle([]string{"Chris", "Cleo"}, []string{"Ruth"}))
+
+		assertStacks(t, hand, 450+600+400, 450, 0)
+	})
+
+	t.Run("leaves folded players out of the pots", func(t *testing.T) {
+		hand := newHand(t, seats("Cleo", "Chris", "Ruth"), 0, blinds)
+
+		act(t, hand, "Cleo", holdem.Action{Type: holdem.Call})
+		act(t, hand, "Chris", holdem.Action{Type: holdem.Fold})
+
+		want := []holdem.Pot{{Amount: 250, Eligible: []string{"Ruth", "Cleo"}}}
+		if got := hand.Pots(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got pots %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("needs a winner for every pot", func(t *testing.T) {
+		hand := allIn(t)
+		assertError(t, hand.Settle([]string{"Ruth"}), holdem.ErrPlayerNotInHand)
+	})
+}
+
+func newHand(t *testing.T, players []holdem.Seat, button int, blinds holdem.Blinds) *holdem.Hand {
+	t.Helper()
+	hand, err := holdem.NewHand(players, button, blinds, holdem.NewDeck(rand.New(rand.NewSource(1))))
+	assertNoError(t, err)
+	return hand
+}
+
+func seats(names ...string) []holdem.Seat {
+	var players []holdem.Seat
+	for _, name := range names {
+		players = append(players, holdem.Seat{Name: name, Stack: 1000})
+	}
+	return players
+}
+
+// padded fills up a stacked deck with the cards it doesn't have yet.
+func padded(deck *holdem.Deck) *holdem.Deck {
+	stacked := dealAll(deck)
+	seen := map[holdem.Card]bool{}
+	for _, card := range stacked {
+		seen[card] = true
+	}
+	for _, card := range dealAll(holdem.NewDeck(rand.New(rand.NewSource(1)))) {
+		if !seen[card] {
+			stacked = append(stacked, card)
+		}
+	}
+	return holdem.NewStackedDeck(stacked...)
+}
+
+func act(t *testing.T, hand *holdem.Hand, name string, action holdem.Action) {
+	t.Helper()
+	if err := hand.Act(name, action); err != nil {
+		t.Fatalf("%s couldn't %s, %v", name, action.Type, err)
+	}
+}
+
+func assertToAct(t *testing.T, state holdem.State, name string, toCall int) {
+	t.Helper()
+	if state.ToAct != name || state.ToCall != toCall {
+		t.Errorf("got %q to act with %d to call, want %q with %d", state.ToAct, state.ToCall, name, toCall)
+	}
+}
+
+func assertStreet(t *testing.T, got, want holdem.Street) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got street %v, want %v", got, want)
+	}
+}
+
+func assertInt(t *testing.T, what string, got, want int) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got %s %d, want %d", what, got, want)
+	}
+}
+
+func assertHole(t *testing.T, hand *holdem.Hand, name, want string) {
+	t.Helper()
+	got, err := hand.HoleCards(name)
+	assertNoError(t, err)
+	if !reflect.DeepEqual(got, cards(t, want)) {
+		t.Errorf("got %v for %s, want %s", got, name, want)
+	}
+}
+
+func assertWinners(t *testing.T, state holdem.State, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(state.Winners, want) {
+		t.Errorf("got winners %v, want %v", state.Winners, want)
+	}
+}
+
+func assertStacks(t *testing.T, hand *holdem.Hand, want ...int) {
+	t.Helper()
+	var got []int
+	for _, seat := range hand.Seats() {
+		got = append(got, seat.Stack)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got stacks %v, want %v", got, want)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
+
+func assertError(t *testing.T, got, want error) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
+
+func assertIllegal(t *testing.T, err error) {
+	t.Helper()
+	var illegal holdem.IllegalActionError
+	if !errors.As(err, &illegal) {
+		t.Errorf("got error %v, want an illegal action", err)
+	}
+}
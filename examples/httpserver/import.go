@@ -0,0 +1,223 @@
+package poker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownImportFormat = errors.New("unknown import format, use csv or json")
+
+// ImportError is a problem with one row of an import. Rows of a CSV count
+// from its header, rows of JSON from its first game, and row 0 is the whole
+// file.
+type ImportError struct {
+	Row int    `json:"row"`
+	Err string `json:"error"`
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("row %d, %s", e.Row, e.Err)
+}
+
+// ImportReport says what an import did. When there are Errors nothing was
+// imported.
+type ImportReport struct {
+	Imported []GameRecord  `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+// ImportGames records the games read from rdr, in format CSVFormat or
+// JSONFormat, in one store update. The store skips the games it already has
+// so the same file can be imported again without counting twice.
+//
+// A CSV needs a header naming its columns. startedAt and players, the names in
+// finishing order separated by semicolons, are needed; a winner column can be
+// given instead of players. league, season, numberOfPlayers, blindStructure
+// and duration are optional. JSON is a list of games like /games serves.
+func ImportGames(store PlayerStore, rdr io.Reader, format string) (ImportReport, error) {
+	var games []GameRecord
+	var errs []ImportError
+
+	switch format {
+	case CSVFormat:
+		games, errs = readCSVGames(rdr)
+	case JSONFormat:
+		games, errs = readJSONGames(rdr)
+	default:
+		return ImportReport{}, ErrUnknownImportFormat
+	}
+
+	report := ImportReport{Imported: []GameRecord{}, Errors: errs}
+	if len(errs) > 0 {
+		return report, nil
+	}
+
+	imported, err := store.RecordGames(games)
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("problem importing games, %v", err)
+	}
+
+	report.Imported = append(report.Imported, imported...)
+	report.Skipped = len(games) - len(imported)
+	return report, nil
+}
+
+// newGames leaves out the imported games already in existing. A game that
+// appears n times in existing accounts for its first n appearances in
+// imported.
+func newGames(existing, imported []GameRecord) []GameRecord {
+	seen := map[string]int{}
+	for _, game := range existing {
+		seen[importKey(game)]++
+	}
+
+	var fresh []GameRecord
+	for _, game := range imported {
+		key := importKey(game)
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		fresh = append(fresh, game)
+	}
+	return fresh
+}
+
+func importKey(game GameRecord) string {
+	return strings.Join([]string{
+		game.StartedAt.UTC().Format(time.RFC3339Nano),
+		game.LeagueName(),
+		game.SeasonName(),
+		strings.Join(game.Players, "\x00"),
+	}, "\x01")
+}
+
+func readJSONGames(rdr io.Reader) ([]GameRecord, []ImportError) {
+	var games []GameRecord
+	if err := json.NewDecoder(rdr).Decode(&games); err != nil {
+		return nil, []ImportError{{Row: 0, Err: fmt.Sprintf("problem parsing games, %v", err)}}
+	}
+
+	var errs []ImportError
+	for i := range games {
+		games[i].ID = 0
+		games[i].StartedAt = games[i].StartedAt.UTC()
+
+		if games[i].StartedAt.IsZero() {
+			errs = append(errs, ImportError{Row: i + 1, Err: "startedAt is needed"})
+		} else if err := games[i].Validate(); err != nil {
+			errs = append(errs, ImportError{Row: i + 1, Err: err.Error()})
+		}
+	}
+	return games, errs
+}
+
+func readCSVGames(rdr io.Reader) ([]GameRecord, []ImportError) {
+	records := csv.NewReader(rdr)
+	records.FieldsPerRecord = -1
+	records.TrimLeadingSpace = true
+
+	header, err := records.Read()
+	if err != nil {
+		return nil, []ImportError{{Row: 1, Err: fmt.Sprintf("problem reading csv header, %v", err)}}
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	_, hasPlayers := columns["players"]
+	_, hasWinner := columns["winner"]
+	if _, ok := columns["startedAt"]; !ok || !(hasPlayers || hasWinner) {
+		return nil, []ImportError{{Row: 1, Err: "csv header needs startedAt and players or winner columns"}}
+	}
+
+	var games []GameRecord
+	var errs []ImportError
+	for row := 2; ; row++ {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, append(errs, ImportError{Row: row, Err: fmt.Sprintf("problem reading csv, %v", err)})
+		}
+
+		game, err := csvGame(columns, record)
+		if err == nil {
+			err = game.Validate()
+		}
+		if err != nil {
+			errs = append(errs, ImportError{Row: row, Err: err.Error()})
+			continue
+		}
+		games = append(games, game)
+	}
+	return games, errs
+}
+
+func csvGame(columns map[string]int, record []string) (GameRecord, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var game GameRecord
+	var err error
+
+	if game.StartedAt, err = parseImportTime(field("startedAt")); err != nil {
+		return GameRecord{}, err
+	}
+
+	if players := field("players"); players != "" {
+		for _, name := range strings.Split(players, ";") {
+			game.Players = append(game.Players, strings.TrimSpace(name))
+		}
+	} else if winner := field("winner"); winner != "" {
+		game.Players = []string{winner}
+	}
+
+	game.League = field("league")
+	game.Season = field("season")
+	game.BlindStructure = field("blindStructure")
+
+	if n := field("numberOfPlayers"); n != "" {
+		if game.NumberOfPlayers, err = strconv.Atoi(n); err != nil {
+			return GameRecord{}, fmt.Errorf("numberOfPlayers %q isn't a number", n)
+		}
+	}
+
+	if d := field("duration"); d != "" {
+		if game.Duration, err = time.ParseDuration(d); err != nil {
+			return GameRecord{}, fmt.Errorf("duration %q isn't a duration like 1h30m", d)
+		}
+	}
+
+	return game, nil
+}
+
+// parseImportTime reads a time like 2019-03-01T20:00:00Z, or a day like
+// 2019-03-01 which is taken as midnight UTC.
+func parseImportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("startedAt is needed")
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("startedAt %q isn't a time like 2019-03-01 or 2019-03-01T20:00:00Z", value)
+}
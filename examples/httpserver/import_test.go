@@ -0,0 +1,156 @@
+package poker_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+const importCSV = `startedAt,players,league,numberOfPlayers,duration
+2019-03-01,Cleo;Chris;Ruth,Friday cash,5,1h30m
+2019-03-08T21:00:00Z,Chris;Cleo,,,
+`
+
+func TestImportGames(t *testing.T) {
+	t.Run("imports games from csv", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		report, err := poker.ImportGames(store, strings.NewReader(importCSV), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+		assertImported(t, report, 2, 0)
+
+		poker.AssertGameRecord(t, report.Imported[0], poker.GameRecord{
+			ID:              1,
+			StartedAt:       time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Duration:        90 * time.Minute,
+			NumberOfPlayers: 5,
+			Players:         []string{"Cleo", "Chris", "Ruth"},
+			League:          "Friday cash",
+		})
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 1}, {Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("imports wins from a winner column", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		csv := "startedAt,winner\n2019-03-01,Cleo\n2019-03-02,Cleo\n"
+		report, err := poker.ImportGames(store, strings.NewReader(csv), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+		assertImported(t, report, 2, 0)
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 2}})
+	})
+
+	t.Run("imports games from json", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		json := `[{"id":7,"startedAt":"2019-03-01T20:00:00Z","duration":0,"players":["Ruth","Cleo"]}]`
+		report, err := poker.ImportGames(store, strings.NewReader(json), poker.JSONFormat)
+		poker.AssertNoError(t, err)
+		assertImported(t, report, 1, 0)
+
+		if report.Imported[0].ID != 1 {
+			t.Errorf("expected the game to get a new ID but got %d", report.Imported[0].ID)
+		}
+	})
+
+	t.Run("reports every bad row and imports nothing", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		csv := "startedAt,players\n2019-03-01,Cleo\nyesterday,Cleo\n2019-03-03,\n2019-03-04,Cleo;Cleo\n"
+		report, err := poker.ImportGames(store, strings.NewReader(csv), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+
+		var rows []int
+		for _, rowErr := range report.Errors {
+			rows = append(rows, rowErr.Row)
+		}
+		if len(rows) != 3 || rows[0] != 3 || rows[1] != 4 || rows[2] != 5 {
+			t.Errorf("got errors for rows %v want 3, 4 and 5", report.Errors)
+		}
+
+		games, err := store.GetGames()
+		poker.AssertNoError(t, err)
+		if len(games) != 0 {
+			t.Errorf("expected nothing to be imported but got %v", games)
+		}
+	})
+
+	t.Run("reports a csv without the columns it needs", func(t *testing.T) {
+		report, err := poker.ImportGames(&poker.StubPlayerStore{}, strings.NewReader("date,who\n"), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+
+		if len(report.Errors) != 1 || report.Errors[0].Row != 1 {
+			t.Errorf("expected an error for the header but got %v", report.Errors)
+		}
+	})
+
+	t.Run("doesn't count games twice when imported again", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		csv := "startedAt,winner\n2019-03-01,Cleo\n2019-03-01,Cleo\n"
+		_, err := poker.ImportGames(store, strings.NewReader(csv), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+
+		report, err := poker.ImportGames(store, strings.NewReader(csv+"2019-03-01,Cleo\n"), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+		assertImported(t, report, 1, 2)
+
+		poker.AssertStoreLeague(t, store, poker.League{{Name: "Cleo", Wins: 3}})
+	})
+
+	t.Run("doesn't count games twice when imported at the same time", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := poker.ImportGames(store, strings.NewReader(importCSV), poker.CSVFormat); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		games, err := store.GetGames()
+		poker.AssertNoError(t, err)
+		if len(games) != 2 {
+			t.Errorf("got %d games want 2", len(games))
+		}
+	})
+
+	t.Run("refuses unknown formats", func(t *testing.T) {
+		_, err := poker.ImportGames(&poker.StubPlayerStore{}, strings.NewReader(""), "xml")
+		poker.AssertError(t, err, poker.ErrUnknownImportFormat)
+	})
+
+	t.Run("an import can be reversed from the audit log", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+
+		_, err := poker.ImportGames(store, strings.NewReader(importCSV), poker.CSVFormat)
+		poker.AssertNoError(t, err)
+
+		entry, err := audit.Entry(1)
+		poker.AssertNoError(t, err)
+		assertAuditEntry(t, entry, "#1 "+entry.At.Format("2006-01-02 15:04")+" cleo (cli) imported 2 games")
+
+		_, err = store.Reverse(1)
+		poker.AssertNoError(t, err)
+		poker.AssertStoreLeague(t, store, poker.League{})
+	})
+}
+
+func assertImported(t *testing.T, report poker.ImportReport, imported, skipped int) {
+	t.Helper()
+	if len(report.Errors) > 0 {
+		t.Fatalf("didn't expect errors but got %v", report.Errors)
+	}
+	if len(report.Imported) != imported || report.Skipped != skipped {
+		t.Errorf("got %d imported and %d skipped want %d and %d", len(report.Imported), report.Skipped, imported, skipped)
+	}
+}
@@ -1,10 +1,6 @@
 package poker
 
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-)
+import "time"
 
 type League []Player
 
@@ -17,12 +13,24 @@ func (l League) Find(name string) *Player {
 	return nil
 }
 
-func NewLeague(rdr *os.File) (League, error) {
-	var league League
-	err := json.NewDecoder(rdr).Decode(&league)
-	if err != nil {
-		err = fmt.Errorf("problem parsing league, %v", err)
+// withLastPlayed returns a copy of the league with when each player last
+// played one of games.
+func (l League) withLastPlayed(games []GameRecord) League {
+	last := map[string]time.Time{}
+	for _, game := range games {
+		for _, name := range game.Players {
+			if game.StartedAt.After(last[name]) {
+				last[name] = game.StartedAt
+			}
+		}
 	}
 
-	return league, err
+	league := make(League, len(l))
+	for i, player := range l {
+		if played, ok := last[player.Name]; ok {
+			player.LastPlayed = &played
+		}
+		league[i] = player
+	}
+	return league
 }
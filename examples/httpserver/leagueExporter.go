@@ -0,0 +1,169 @@
+package poker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const CSVContentType = "text/csv"
+const HTMLContentType = "text/html"
+const TextContentType = "text/plain"
+const LeagueTemplatePath = "static/league.html"
+
+// The formats a league can be exported in.
+const (
+	JSONFormat = "json"
+	CSVFormat  = "csv"
+	HTMLFormat = "html"
+	TextFormat = "text"
+)
+
+var ErrUnknownFormat = errors.New("unknown format, use json, csv, html or text")
+var ErrNoLeagueTemplate = errors.New("no template to export the league as html")
+
+var formatContentTypes = map[string]string{
+	JSONFormat: JsonContentType,
+	CSVFormat:  CSVContentType,
+	HTMLFormat: HTMLContentType,
+	TextFormat: TextContentType,
+}
+
+// LeagueExporter writes a league out in one of the formats. Its zero value
+// can't write html.
+type LeagueExporter struct {
+	template *template.Template
+}
+
+func NewLeagueExporter(templatePath string) (*LeagueExporter, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+
+	if err != nil {
+		return nil, fmt.Errorf("problem opening %s %v", templatePath, err)
+	}
+
+	return &LeagueExporter{template: tmpl}, nil
+}
+
+// ContentType is the content type of format, or "" when it isn't one.
+func ContentType(format string) string {
+	return formatContentTypes[format]
+}
+
+// Export writes league in format. Players that haven't been ranked are given
+// their place in the league as their position.
+func (e *LeagueExporter) Export(w io.Writer, format string, league League) error {
+	league = withPositions(league)
+
+	switch format {
+	case JSONFormat:
+		if league == nil {
+			league = League{}
+		}
+		return json.NewEncoder(w).Encode(league)
+	case CSVFormat:
+		return writeLeagueCSV(w, league)
+	case HTMLFormat:
+		if e.template == nil {
+			return ErrNoLeagueTemplate
+		}
+		return e.template.Execute(w, league)
+	case TextFormat:
+		return writeLeagueText(w, league)
+	}
+	return ErrUnknownFormat
+}
+
+func writeLeagueCSV(w io.Writer, league League) error {
+	records := csv.NewWriter(w)
+	records.Write([]string{"position", "name", "wins", "rating"})
+
+	for _, player := range league {
+		records.Write([]string{
+			strconv.Itoa(player.Position),
+			player.Name,
+			strconv.Itoa(player.Wins),
+			strconv.FormatFloat(player.Rating, 'f', -1, 64),
+		})
+	}
+
+	records.Flush()
+	return records.Error()
+}
+
+func writeLeagueText(w io.Writer, league League) error {
+	if len(league) == 0 {
+		_, err := fmt.Fprintln(w, "Nobody has won yet")
+		return err
+	}
+
+	for _, player := range league {
+		if _, err := fmt.Fprintf(w, "%d. %s, %d wins, rated %.0f\n", player.Position, player.Name, player.Wins, player.Rating); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func withPositions(league League) League {
+	positioned := make(League, len(league))
+	for i, player := range league {
+		if player.Position == 0 {
+			player.Position = i + 1
+		}
+		positioned[i] = player
+	}
+	return positioned
+}
+
+// negotiateFormat picks the format to send for an Accept header, preferring
+// what the client weighs highest and JSON when it will take anything. It's
+// false when there is nothing the client accepts.
+func negotiateFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return JSONFormat, true
+	}
+
+	best, bestQuality := "", 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if parsed, err := strconv.ParseFloat(q[2:], 64); err == nil {
+					quality = parsed
+				}
+			}
+		}
+
+		format := formatFor(mediaType)
+		if format != "" && quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+
+	return best, best != ""
+}
+
+func formatFor(mediaType string) string {
+	switch mediaType {
+	case "*/*", "application/*":
+		return JSONFormat
+	case "text/*":
+		return TextFormat
+	}
+
+	for format, contentType := range formatContentTypes {
+		if contentType == mediaType {
+			return format
+		}
+	}
+	return ""
+}
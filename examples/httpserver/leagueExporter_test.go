@@ -0,0 +1,65 @@
+package poker_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+func TestLeagueExporter(t *testing.T) {
+	league := poker.League{
+		{Name: "Cleo", Wins: 3, Rating: 1516},
+		{Name: "Chris, Jr", Wins: 1, Rating: 1484.5},
+	}
+
+	exporter, err := poker.NewLeagueExporter(poker.LeagueTemplatePath)
+	poker.AssertNoError(t, err)
+
+	export := func(format string, league poker.League) string {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		poker.AssertNoError(t, exporter.Export(buf, format, league))
+		return buf.String()
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		got := export(poker.CSVFormat, league)
+		want := "position,name,wins,rating\n1,Cleo,3,1516\n2,\"Chris, Jr\",1,1484.5\n"
+		poker.AssertResponseBody(t, got, want)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		got := export(poker.TextFormat, league)
+		want := "1. Cleo, 3 wins, rated 1516\n2. Chris, Jr, 1 wins, rated 1484\n"
+		poker.AssertResponseBody(t, got, want)
+
+		poker.AssertResponseBody(t, export(poker.TextFormat, nil), "Nobody has won yet\n")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		got := export(poker.JSONFormat, nil)
+		poker.AssertResponseBody(t, got, "[]\n")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		got := export(poker.HTMLFormat, league)
+
+		for _, want := range []string{"<td>Cleo</td>", "<td>Chris, Jr</td>", `<td class="number">1516</td>`} {
+			if !strings.Contains(got, want) {
+				t.Errorf("expected %q in %q", want, got)
+			}
+		}
+	})
+
+	t.Run("html needs a template", func(t *testing.T) {
+		err := (&poker.LeagueExporter{}).Export(&bytes.Buffer{}, poker.HTMLFormat, league)
+		poker.AssertError(t, err, poker.ErrNoLeagueTemplate)
+	})
+
+	t.Run("unknown formats", func(t *testing.T) {
+		err := exporter.Export(&bytes.Buffer{}, "xml", league)
+		poker.AssertError(t, err, poker.ErrUnknownFormat)
+	})
+}
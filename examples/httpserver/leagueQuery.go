@@ -0,0 +1,225 @@
+package poker
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The orders a league can be sorted in.
+const (
+	SortByWins       = "wins"
+	SortByName       = "name"
+	SortByRating     = "rating"
+	SortByLastPlayed = "last_played"
+)
+
+const (
+	Ascending  = "asc"
+	Descending = "desc"
+)
+
+var ErrBadCursor = errors.New("cursor isn't for this league")
+
+// LeagueQuery picks out part of a league. Players are ranked by Sort before
+// they are filtered, so positions are the same whatever is filtered out, and
+// players tied on it share a position. Ties are listed by name.
+type LeagueQuery struct {
+	Sort    string
+	Order   string
+	MinWins int
+	Prefix  string
+	Limit   int
+	Offset  int
+	Cursor  string
+}
+
+// LeaguePage is what a query picked out. Total is how many players matched
+// before paging, and NextCursor carries on after the last player when there
+// are more.
+type LeaguePage struct {
+	Players    League
+	Total      int
+	Offset     int
+	NextCursor string
+}
+
+// ParseLeagueQuery reads a query from sort, order, min_wins, prefix, limit,
+// offset and cursor.
+func ParseLeagueQuery(values url.Values) (LeagueQuery, error) {
+	q := LeagueQuery{
+		Sort:   values.Get("sort"),
+		Order:  values.Get("order"),
+		Prefix: values.Get("prefix"),
+		Cursor: values.Get("cursor"),
+	}
+
+	if q.Sort == "" {
+		q.Sort = SortByWins
+	}
+	if _, ok := descendingByDefault[q.Sort]; !ok {
+		return LeagueQuery{}, fmt.Errorf("can't sort by %q, use wins, name, rating or last_played", q.Sort)
+	}
+
+	if q.Order != "" && q.Order != Ascending && q.Order != Descending {
+		return LeagueQuery{}, fmt.Errorf("order %q should be asc or desc", q.Order)
+	}
+
+	for name, field := range map[string]*int{"min_wins": &q.MinWins, "limit": &q.Limit, "offset": &q.Offset} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return LeagueQuery{}, fmt.Errorf("%s %q should be a number that isn't negative", name, value)
+		}
+		*field = n
+	}
+
+	if q.Cursor != "" && q.Offset != 0 {
+		return LeagueQuery{}, fmt.Errorf("use either a cursor or an offset")
+	}
+
+	return q, nil
+}
+
+var descendingByDefault = map[string]bool{
+	SortByWins:       true,
+	SortByName:       false,
+	SortByRating:     true,
+	SortByLastPlayed: true,
+}
+
+// Run ranks the league and returns the page of it the query asks for. The
+// league it is given is left as it was.
+func (q LeagueQuery) Run(league League) (LeaguePage, error) {
+	ranked := q.rank(league)
+
+	var matched League
+	for _, player := range ranked {
+		if player.Wins >= q.MinWins && strings.HasPrefix(strings.ToLower(player.Name), strings.ToLower(q.Prefix)) {
+			matched = append(matched, player)
+		}
+	}
+
+	start := q.Offset
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return LeaguePage{}, err
+		}
+
+		start = -1
+		for i, player := range matched {
+			if player.Name == after {
+				start = i + 1
+			}
+		}
+		if start == -1 {
+			return LeaguePage{}, ErrBadCursor
+		}
+	}
+
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := LeaguePage{Players: matched[start:end], Total: len(matched), Offset: start}
+	if end < len(matched) && end > start {
+		page.NextCursor = encodeCursor(matched[end-1].Name)
+	}
+	return page, nil
+}
+
+func (q LeagueQuery) rank(league League) League {
+	ranked := make(League, len(league))
+	copy(ranked, league)
+
+	compare := compareBy(q.Sort)
+	descending := descendingByDefault[q.Sort]
+	if q.Order != "" {
+		descending = q.Order == Descending
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		c := compare(ranked[i], ranked[j])
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			return ranked[i].Name < ranked[j].Name
+		}
+		return c < 0
+	})
+
+	for i := range ranked {
+		if i > 0 && compare(ranked[i], ranked[i-1]) == 0 {
+			ranked[i].Position = ranked[i-1].Position
+		} else {
+			ranked[i].Position = i + 1
+		}
+	}
+	return ranked
+}
+
+func compareBy(key string) func(a, b Player) int {
+	switch key {
+	case SortByName:
+		return func(a, b Player) int { return strings.Compare(a.Name, b.Name) }
+	case SortByRating:
+		return func(a, b Player) int { return compareFloats(a.Rating, b.Rating) }
+	case SortByLastPlayed:
+		return func(a, b Player) int { return compareTimes(a.LastPlayed, b.LastPlayed) }
+	}
+	return func(a, b Player) int { return a.Wins - b.Wins }
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareTimes puts players who have never played before everyone else.
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case a.Before(*b):
+		return -1
+	case a.After(*b):
+		return 1
+	}
+	return 0
+}
+
+func encodeCursor(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	name, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrBadCursor
+	}
+	return string(name), nil
+}
@@ -0,0 +1,150 @@
+package poker_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+func TestParseLeagueQuery(t *testing.T) {
+	t.Run("sorts by wins unless asked otherwise", func(t *testing.T) {
+		q, err := poker.ParseLeagueQuery(url.Values{})
+		poker.AssertNoError(t, err)
+
+		if q.Sort != poker.SortByWins {
+			t.Errorf("got sort %q want %q", q.Sort, poker.SortByWins)
+		}
+	})
+
+	t.Run("reads every parameter", func(t *testing.T) {
+		values, _ := url.ParseQuery("sort=name&order=desc&min_wins=2&prefix=ch&limit=10&offset=20")
+		q, err := poker.ParseLeagueQuery(values)
+		poker.AssertNoError(t, err)
+
+		want := poker.LeagueQuery{Sort: "name", Order: "desc", MinWins: 2, Prefix: "ch", Limit: 10, Offset: 20}
+		if q != want {
+			t.Errorf("got %+v want %+v", q, want)
+		}
+	})
+
+	for _, query := range []string{"sort=luck", "order=up", "limit=ten", "offset=-1", "min_wins=x", "offset=2&cursor=abc"} {
+		t.Run("refuses "+query, func(t *testing.T) {
+			values, _ := url.ParseQuery(query)
+			if _, err := poker.ParseLeagueQuery(values); err == nil {
+				t.Errorf("expected an error for %s", query)
+			}
+		})
+	}
+}
+
+func TestLeagueQuery_Run(t *testing.T) {
+	earlier := time.Date(2019, time.March, 1, 20, 0, 0, 0, time.UTC)
+	later := earlier.Add(24 * time.Hour)
+
+	league := poker.League{
+		{Name: "Cleo", Wins: 3, Rating: 1510, LastPlayed: &earlier},
+		{Name: "Ruth", Wins: 1, Rating: 1490, LastPlayed: &later},
+		{Name: "Chris", Wins: 3, Rating: 1520},
+		{Name: "Tiest", Wins: 0, Rating: 1480, LastPlayed: &later},
+	}
+
+	run := func(t *testing.T, q poker.LeagueQuery) poker.LeaguePage {
+		t.Helper()
+		page, err := q.Run(league)
+		poker.AssertNoError(t, err)
+		return page
+	}
+
+	t.Run("ties share a position and are listed by name", func(t *testing.T) {
+		page := run(t, poker.LeagueQuery{Sort: poker.SortByWins})
+
+		assertStandings(t, page.Players, poker.League{
+			{Name: "Chris", Wins: 3, Position: 1},
+			{Name: "Cleo", Wins: 3, Position: 1},
+			{Name: "Ruth", Wins: 1, Position: 3},
+			{Name: "Tiest", Wins: 0, Position: 4},
+		})
+	})
+
+	t.Run("sorts by every key in either order", func(t *testing.T) {
+		cases := []struct {
+			q    poker.LeagueQuery
+			want []string
+		}{
+			{poker.LeagueQuery{Sort: poker.SortByName}, []string{"Chris", "Cleo", "Ruth", "Tiest"}},
+			{poker.LeagueQuery{Sort: poker.SortByName, Order: poker.Descending}, []string{"Tiest", "Ruth", "Cleo", "Chris"}},
+			{poker.LeagueQuery{Sort: poker.SortByRating}, []string{"Chris", "Cleo", "Ruth", "Tiest"}},
+			{poker.LeagueQuery{Sort: poker.SortByWins, Order: poker.Ascending}, []string{"Tiest", "Ruth", "Chris", "Cleo"}},
+			{poker.LeagueQuery{Sort: poker.SortByLastPlayed}, []string{"Ruth", "Tiest", "Cleo", "Chris"}},
+		}
+
+		for _, c := range cases {
+			assertPlayerNames(t, run(t, c.q).Players, c.want...)
+		}
+	})
+
+	t.Run("keeps positions from the whole league when filtering", func(t *testing.T) {
+		page := run(t, poker.LeagueQuery{Sort: poker.SortByWins, Prefix: "r", MinWins: 1})
+
+		assertStandings(t, page.Players, poker.League{{Name: "Ruth", Wins: 1, Position: 3}})
+		if page.Total != 1 {
+			t.Errorf("got total %d want 1", page.Total)
+		}
+	})
+
+	t.Run("pages by offset", func(t *testing.T) {
+		page := run(t, poker.LeagueQuery{Sort: poker.SortByName, Limit: 2, Offset: 1})
+
+		assertPlayerNames(t, page.Players, "Cleo", "Ruth")
+		if page.Total != 4 || page.NextCursor == "" {
+			t.Errorf("got total %d and next cursor %q", page.Total, page.NextCursor)
+		}
+
+		last := run(t, poker.LeagueQuery{Sort: poker.SortByName, Limit: 2, Offset: 3})
+		assertPlayerNames(t, last.Players, "Tiest")
+		if last.NextCursor != "" {
+			t.Errorf("didn't expect a next cursor on the last page but got %q", last.NextCursor)
+		}
+	})
+
+	t.Run("pages by cursor", func(t *testing.T) {
+		first := run(t, poker.LeagueQuery{Sort: poker.SortByName, Limit: 3})
+		second := run(t, poker.LeagueQuery{Sort: poker.SortByName, Limit: 3, Cursor: first.NextCursor})
+
+		assertPlayerNames(t, second.Players, "Tiest")
+		if second.Offset != 3 {
+			t.Errorf("got offset %d want 3", second.Offset)
+		}
+	})
+
+	t.Run("refuses cursors for players that aren't there", func(t *testing.T) {
+		_, err := poker.LeagueQuery{Sort: poker.SortByName, Cursor: "bm9ib2R5"}.Run(league)
+		poker.AssertError(t, err, poker.ErrBadCursor)
+	})
+
+	t.Run("leaves the league it was given alone", func(t *testing.T) {
+		run(t, poker.LeagueQuery{Sort: poker.SortByName})
+
+		if league[0].Name != "Cleo" || league[0].Position != 0 {
+			t.Errorf("the league was changed to %v", league)
+		}
+	})
+}
+
+func assertPlayerNames(t *testing.T, league poker.League, want ...string) {
+	t.Helper()
+	var got []string
+	for _, player := range league {
+		got = append(got, player.Name)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got players %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got players %v want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package poker
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"time"
+)
+
+const EventStreamContentType = "text/event-stream"
+
+// LeagueEventType is the event the standings are sent in on /league/stream.
+const LeagueEventType = "league"
+
+// DefaultLeagueHeartbeat is how often a quiet league stream sends a comment,
+// so proxies don't take it for a dead connection.
+const DefaultLeagueHeartbeat = 15 * time.Second
+
+// leagueRetry is how long clients wait before reconnecting to a stream they
+// lost.
+const leagueRetry = 3 * time.Second
+
+// leagueEvents writes the standings as server-sent events. Each event's ID is
+// a hash of its standings, so a client resuming with the Last-Event-ID it
+// was sent isn't sent the same standings again, even after a restart.
+type leagueEvents struct {
+	w      io.Writer
+	lastID string
+}
+
+func (e *leagueEvents) retry(d time.Duration) error {
+	_, err := fmt.Fprintf(e.w, "retry: %d\n\n", int64(d/time.Millisecond))
+	return err
+}
+
+// send writes players unless they are what was sent last.
+func (e *leagueEvents) send(players League) error {
+	if players == nil {
+		players = League{}
+	}
+
+	data, err := json.Marshal(players)
+	if err != nil {
+		return fmt.Errorf("problem encoding league, %v", err)
+	}
+
+	id := leagueEventID(data)
+	if id == e.lastID {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(e.w, "id: %s\nevent: %s\ndata: %s\n\n", id, LeagueEventType, data); err != nil {
+		return err
+	}
+	e.lastID = id
+	return nil
+}
+
+func (e *leagueEvents) heartbeat() error {
+	_, err := io.WriteString(e.w, ": heartbeat\n\n")
+	return err
+}
+
+func leagueEventID(data []byte) string {
+	h := fnv.New64a()
+	h.Write(data)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
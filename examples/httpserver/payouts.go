@@ -0,0 +1,87 @@
+package poker
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PayoutTable shares out the prize pool of a tournament, which is the BuyIn
+// of every player. Places are the percentage of the pool won by each
+// finishing place, from the winner down, and add up to 100.
+type PayoutTable struct {
+	BuyIn  int       `json:"buyIn"`
+	Places []float64 `json:"places"`
+}
+
+// DefaultPayoutTable pays the first three places, but has no buy in so there
+// are no prizes until one is set.
+var DefaultPayoutTable = PayoutTable{Places: []float64{50, 30, 20}}
+
+// ParsePayoutPlaces reads percentages for each place like "50,30,20".
+func ParsePayoutPlaces(input string) ([]float64, error) {
+	var places []float64
+	for _, field := range strings.Split(input, ",") {
+		percentage, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("payout %q isn't a percentage", field)
+		}
+		places = append(places, percentage)
+	}
+	return places, nil
+}
+
+func (t PayoutTable) Validate() error {
+	if t.BuyIn < 0 {
+		return fmt.Errorf("the buy in can't be negative")
+	}
+
+	if len(t.Places) == 0 {
+		return fmt.Errorf("payouts need at least one place")
+	}
+
+	total := 0.0
+	for i, percentage := range t.Places {
+		if percentage <= 0 {
+			return fmt.Errorf("place %d needs a payout above 0%%", i+1)
+		}
+		total += percentage
+	}
+
+	if math.Abs(total-100) > 0.001 {
+		return fmt.Errorf("payouts add up to %g%%, not 100%%", total)
+	}
+	return nil
+}
+
+// Prizes are what each place wins in a game for numberOfPlayers, from the
+// winner down. When fewer play than there are places, the places that can
+// be paid share the whole pool in proportion. Chips rounded off go to the
+// winner.
+func (t PayoutTable) Prizes(numberOfPlayers int) []int {
+	pool := t.BuyIn * numberOfPlayers
+	if pool <= 0 {
+		return nil
+	}
+
+	places := t.Places
+	if len(places) > numberOfPlayers {
+		places = places[:numberOfPlayers]
+	}
+
+	total := 0.0
+	for _, percentage := range places {
+		total += percentage
+	}
+
+	prizes := make([]int, len(places))
+	paid := 0
+	for i, percentage := range places {
+		prizes[i] = int(float64(pool) * percentage / total)
+		paid += prizes[i]
+	}
+	prizes[0] += pool - paid
+
+	return prizes
+}
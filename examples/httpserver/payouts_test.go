@@ -0,0 +1,66 @@
+package poker_test
+
+import (
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"testing"
+)
+
+func TestPayoutTable(t *testing.T) {
+	table := poker.PayoutTable{BuyIn: 100, Places: []float64{50, 30, 20}}
+
+	t.Run("shares the buy ins between the places", func(t *testing.T) {
+		assertPrizes(t, table.Prizes(10), 500, 300, 200)
+	})
+
+	t.Run("gives the chips rounded off to the winner", func(t *testing.T) {
+		assertPrizes(t, poker.PayoutTable{BuyIn: 10, Places: []float64{60, 40}}.Prizes(3), 18, 12)
+		assertPrizes(t, poker.PayoutTable{BuyIn: 1, Places: []float64{50, 30, 20}}.Prizes(7), 4, 2, 1)
+	})
+
+	t.Run("shares the whole pool when fewer play than there are places", func(t *testing.T) {
+		assertPrizes(t, table.Prizes(2), 125, 75)
+	})
+
+	t.Run("pays nothing without a buy in", func(t *testing.T) {
+		assertPrizes(t, poker.DefaultPayoutTable.Prizes(10))
+	})
+
+	t.Run("reads the places", func(t *testing.T) {
+		places, err := poker.ParsePayoutPlaces("65, 35")
+		poker.AssertNoError(t, err)
+		if !reflect.DeepEqual(places, []float64{65, 35}) {
+			t.Errorf("got %v, want 65 and 35", places)
+		}
+
+		if _, err := poker.ParsePayoutPlaces("65,thirty five"); err == nil {
+			t.Error("expected an error reading a place that isn't a number")
+		}
+	})
+
+	invalid := map[string]poker.PayoutTable{
+		"no places":            {BuyIn: 100},
+		"a negative buy in":    {BuyIn: -1, Places: []float64{100}},
+		"a place paying 0":     {BuyIn: 100, Places: []float64{100, 0}},
+		"places short of 100%": {BuyIn: 100, Places: []float64{50, 30}},
+		"places over 100%":     {BuyIn: 100, Places: []float64{70, 40}},
+	}
+
+	for name, table := range invalid {
+		t.Run("rejects payouts with "+name, func(t *testing.T) {
+			if table.Validate() == nil {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+}
+
+func assertPrizes(t *testing.T, got []int, want ...int) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got prizes %v, want %v", got, want)
+	}
+}
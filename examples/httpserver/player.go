@@ -1,6 +1,13 @@
 package poker
 
+import "time"
+
+// Player is someone in a league. Position and LastPlayed are only known once
+// the league has been ranked and its games looked at.
 type Player struct {
-	Name string
-	Wins int
+	Name       string
+	Wins       int
+	Rating     float64    `json:",omitempty"`
+	Position   int        `json:",omitempty"`
+	LastPlayed *time.Time `json:",omitempty"`
 }
@@ -0,0 +1,127 @@
+package poker
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// The kinds of streak a player can be on.
+const (
+	WinningStreak = "win"
+	LosingStreak  = "loss"
+)
+
+// PlayerStats is how a player has done in the games they played. Wins
+// recorded before whole games were kept aren't in it.
+type PlayerStats struct {
+	Name            string       `json:"name"`
+	GamesPlayed     int          `json:"gamesPlayed"`
+	Wins            int          `json:"wins"`
+	WinRate         float64      `json:"winRate"`
+	AveragePosition float64      `json:"averagePosition"`
+	Winnings        int          `json:"winnings,omitempty"`
+	Streak          Streak       `json:"streak"`
+	LastPlayed      time.Time    `json:"lastPlayed"`
+	HeadToHead      []HeadToHead `json:"headToHead"`
+}
+
+// Streak is how many games in a row, up to the last one played, a player
+// has either won or not won.
+type Streak struct {
+	Type   string `json:"type"`
+	Length int    `json:"length"`
+}
+
+// HeadToHead is how a player has done against an opponent in the games they
+// both played, Ahead being the games they finished in front.
+type HeadToHead struct {
+	Opponent string `json:"opponent"`
+	Games    int    `json:"games"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+}
+
+// StatsFor works out the stats of name from games, which it returns
+// ErrPlayerNotFound for when name didn't play any of them.
+func StatsFor(name string, games []GameRecord) (PlayerStats, error) {
+	played := make([]GameRecord, 0)
+	for _, game := range games {
+		if game.Position(name) != 0 {
+			played = append(played, game)
+		}
+	}
+
+	if len(played) == 0 {
+		return PlayerStats{}, ErrPlayerNotFound
+	}
+
+	sort.SliceStable(played, func(i, j int) bool {
+		return played[i].StartedAt.Before(played[j].StartedAt)
+	})
+
+	stats := PlayerStats{Name: name, GamesPlayed: len(played), HeadToHead: []HeadToHead{}}
+	opponents := map[string]*HeadToHead{}
+	positions := 0
+
+	for _, game := range played {
+		position := game.Position(name)
+		positions += position
+
+		won := position == 1
+		if won {
+			stats.Wins++
+		}
+		stats.Winnings += game.Prize(name)
+
+		streakType := LosingStreak
+		if won {
+			streakType = WinningStreak
+		}
+		if stats.Streak.Type == streakType {
+			stats.Streak.Length++
+		} else {
+			stats.Streak = Streak{Type: streakType, Length: 1}
+		}
+
+		if game.StartedAt.After(stats.LastPlayed) {
+			stats.LastPlayed = game.StartedAt
+		}
+
+		for i, opponent := range game.Players {
+			if opponent == name {
+				continue
+			}
+
+			record, ok := opponents[opponent]
+			if !ok {
+				record = &HeadToHead{Opponent: opponent}
+				opponents[opponent] = record
+			}
+
+			record.Games++
+			if position < i+1 {
+				record.Ahead++
+			} else {
+				record.Behind++
+			}
+		}
+	}
+
+	stats.WinRate = roundTo(float64(stats.Wins)/float64(stats.GamesPlayed), 3)
+	stats.AveragePosition = roundTo(float64(positions)/float64(stats.GamesPlayed), 2)
+
+	for _, record := range opponents {
+		stats.HeadToHead = append(stats.HeadToHead, *record)
+	}
+	sort.Slice(stats.HeadToHead, func(i, j int) bool {
+		return stats.HeadToHead[i].Opponent < stats.HeadToHead[j].Opponent
+	})
+
+	return stats, nil
+}
+
+func roundTo(f float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(f*scale) / scale
+}
@@ -0,0 +1,63 @@
+package poker_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+func TestStatsFor(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, time.March, d, 20, 0, 0, 0, time.UTC)
+	}
+
+	games := []poker.GameRecord{
+		{ID: 1, StartedAt: day(1), Players: []string{"Chris", "Cleo", "Ruth"}},
+		{ID: 2, StartedAt: day(8), Players: []string{"Cleo", "Chris"}},
+		{ID: 4, StartedAt: day(22), Players: []string{"Cleo", "Ruth", "Chris"}},
+		{ID: 3, StartedAt: day(15), Players: []string{"Cleo"}},
+		{ID: 5, StartedAt: day(29), Players: []string{"Ruth"}},
+	}
+
+	t.Run("works out the stats of a player from their games", func(t *testing.T) {
+		got, err := poker.StatsFor("Cleo", games)
+		poker.AssertNoError(t, err)
+
+		want := poker.PlayerStats{
+			Name:            "Cleo",
+			GamesPlayed:     4,
+			Wins:            3,
+			WinRate:         0.75,
+			AveragePosition: 1.25,
+			Streak:          poker.Streak{Type: poker.WinningStreak, Length: 3},
+			LastPlayed:      day(22),
+			HeadToHead: []poker.HeadToHead{
+				{Opponent: "Chris", Games: 3, Ahead: 2, Behind: 1},
+				{Opponent: "Ruth", Games: 2, Ahead: 2, Behind: 0},
+			},
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v want %+v", got, want)
+		}
+	})
+
+	t.Run("counts a losing streak", func(t *testing.T) {
+		got, err := poker.StatsFor("Chris", games)
+		poker.AssertNoError(t, err)
+
+		if got.Streak != (poker.Streak{Type: poker.LosingStreak, Length: 2}) {
+			t.Errorf("got streak %+v", got.Streak)
+		}
+		if got.WinRate != 0.333 || got.AveragePosition != 2 {
+			t.Errorf("got win rate %v and average position %v", got.WinRate, got.AveragePosition)
+		}
+	})
+
+	t.Run("players without games aren't found", func(t *testing.T) {
+		_, err := poker.StatsFor("Tiest", games)
+		poker.AssertError(t, err, poker.ErrPlayerNotFound)
+	})
+}
@@ -1,7 +1,94 @@
 package poker
 
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrPlayerNotFound = errors.New("player not found")
+
+const (
+	JSONStore     = "json"
+	EventLogStore = "log"
+)
+
 type PlayerStore interface {
-	GetPlayerScore(name string) int
-	RecordWin(name string)
-	GetLeague() League
+	GetPlayerScore(name string) (int, error)
+	RecordWin(name string) error
+	GetLeague() (League, error)
+	RecordGame(game GameRecord) (GameRecord, error)
+	// RecordGames records, all at once, the games that aren't kept already
+	// and returns the ones it recorded. A game kept n times accounts for its
+	// first n appearances in games.
+	RecordGames(games []GameRecord) ([]GameRecord, error)
+	GetGames() ([]GameRecord, error)
+	GetGame(id int) (GameRecord, error)
+	DeleteGame(id int) error
+	RenamePlayer(from, to string) error
+}
+
+// ChangeNotifier is implemented by stores that can say when what they keep
+// has changed, so the league can be sent out again without polling for it.
+type ChangeNotifier interface {
+	// OnChange calls notify after every change to the store until the
+	// returned function is called. notify is called while the store is
+	// still being changed, so it mustn't block or use the store.
+	OnChange(notify func()) (stop func())
+}
+
+// changeNotifier is the ChangeNotifier the stores embed.
+type changeNotifier struct {
+	mu     sync.Mutex
+	nextID int
+	notify map[int]func()
+}
+
+func (c *changeNotifier) OnChange(notify func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.notify == nil {
+		c.notify = map[int]func(){}
+	}
+	id := c.nextID
+	c.nextID++
+	c.notify[id] = notify
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.notify, id)
+	}
+}
+
+func (c *changeNotifier) changed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, notify := range c.notify {
+		notify()
+	}
+}
+
+// PlayerStoreFromFile opens the kind of store named by kind, either JSONStore
+// or EventLogStore, at path.
+func PlayerStoreFromFile(kind, path string) (PlayerStore, func(), error) {
+	switch kind {
+	case JSONStore:
+		store, closeFunc, err := FileSystemPlayerStoreFromFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, closeFunc, nil
+	case EventLogStore:
+		store, closeFunc, err := EventLogPlayerStoreFromFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, closeFunc, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown player store %q, use %q or %q", kind, JSONStore, EventLogStore)
 }
@@ -0,0 +1,417 @@
+package poker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// PlayerStoreFactory opens the store kept at path, creating it if needed, and
+// returns it with a function that closes it. Opening the same path again has
+// to give back everything recorded before it was closed.
+type PlayerStoreFactory func(t *testing.T, path string) (PlayerStore, func())
+
+// PlayerStoreContract runs the behaviour every PlayerStore has to have against
+// the stores made by open.
+func PlayerStoreContract(t *testing.T, open PlayerStoreFactory) {
+	t.Helper()
+
+	t.Run("unknown players are not found", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		_, err := store.GetPlayerScore("Apollo")
+		AssertError(t, err, ErrPlayerNotFound)
+	})
+
+	t.Run("an empty store has an empty league", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		league, err := store.GetLeague()
+		AssertNoError(t, err)
+
+		if len(league) != 0 {
+			t.Errorf("expected an empty league but got %v", league)
+		}
+	})
+
+	t.Run("records wins for new and existing players", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordWins(t, store, "Pepper", 1)
+		AssertStoreScore(t, store, "Pepper", 1)
+
+		mustRecordWins(t, store, "Pepper", 2)
+		AssertStoreScore(t, store, "Pepper", 3)
+	})
+
+	t.Run("league is ordered by wins", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordWins(t, store, "Cleo", 1)
+		mustRecordWins(t, store, "Chris", 3)
+		mustRecordWins(t, store, "Pepper", 2)
+
+		AssertStoreLeague(t, store, League{{Name: "Chris", Wins: 3}, {Name: "Pepper", Wins: 2}, {Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("records concurrent wins", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		wins := 50
+		var wg sync.WaitGroup
+		wg.Add(wins)
+
+		for i := 0; i < wins; i++ {
+			go func() {
+				defer wg.Done()
+				if err := store.RecordWin("Chris"); err != nil {
+					t.Errorf("didn't expect an error recording a win but got one, %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		AssertStoreLeague(t, store, League{{Name: "Chris", Wins: wins}})
+	})
+
+	t.Run("keeps wins when reopened", func(t *testing.T) {
+		path := contractStorePath(t)
+
+		store, closeStore := open(t, path)
+		mustRecordWins(t, store, "Cleo", 2)
+		mustRecordWins(t, store, "Chris", 1)
+		closeStore()
+
+		reopened, closeReopened := open(t, path)
+		defer closeReopened()
+
+		AssertStoreLeague(t, reopened, League{{Name: "Cleo", Wins: 2}, {Name: "Chris", Wins: 1}})
+	})
+
+	playerStoreGamesContract(t, open)
+	playerStoreRenameContract(t, open)
+	playerStoreChangesContract(t, open)
+}
+
+func playerStoreGamesContract(t *testing.T, open PlayerStoreFactory) {
+	t.Helper()
+
+	game := GameRecord{
+		StartedAt:       time.Date(2019, time.March, 1, 20, 0, 0, 0, time.UTC),
+		Duration:        90 * time.Minute,
+		NumberOfPlayers: 5,
+		Players:         []string{"Cleo", "Chris", "Ruth"},
+		BlindStructure:  DefaultBlindStructureName,
+	}
+
+	t.Run("records games with increasing IDs", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		first := mustRecordGame(t, store, game)
+		second := mustRecordGame(t, store, game)
+
+		if second.ID <= first.ID {
+			t.Errorf("expected IDs to increase but got %d then %d", first.ID, second.ID)
+		}
+
+		got, err := store.GetGame(second.ID)
+		AssertNoError(t, err)
+		AssertGameRecord(t, got, second)
+	})
+
+	t.Run("the league counts the winners of games", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordGame(t, store, game)
+		mustRecordWins(t, store, "Chris", 2)
+
+		AssertStoreLeague(t, store, League{{Name: "Chris", Wins: 2}, {Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("unknown games are not found", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		_, err := store.GetGame(42)
+		AssertError(t, err, ErrGameNotFound)
+	})
+
+	t.Run("refuses games without players", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		_, err := store.RecordGame(GameRecord{})
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+
+	t.Run("keeps games when reopened", func(t *testing.T) {
+		path := contractStorePath(t)
+
+		store, closeStore := open(t, path)
+		recorded := mustRecordGame(t, store, game)
+		closeStore()
+
+		reopened, closeReopened := open(t, path)
+		defer closeReopened()
+
+		games, err := reopened.GetGames()
+		AssertNoError(t, err)
+
+		if len(games) != 1 {
+			t.Fatalf("expected 1 game but got %v", games)
+		}
+		AssertGameRecord(t, games[0], recorded)
+	})
+
+	t.Run("records many games at once", func(t *testing.T) {
+		path := contractStorePath(t)
+
+		store, closeStore := open(t, path)
+		mustRecordWins(t, store, "Chris", 1)
+		recorded, err := store.RecordGames([]GameRecord{game, game})
+		AssertNoError(t, err)
+		closeStore()
+
+		if len(recorded) != 2 || recorded[0].ID >= recorded[1].ID {
+			t.Fatalf("expected two games with increasing IDs but got %v", recorded)
+		}
+
+		reopened, closeReopened := open(t, path)
+		defer closeReopened()
+
+		got, err := reopened.GetGame(recorded[1].ID)
+		AssertNoError(t, err)
+		AssertGameRecord(t, got, recorded[1])
+		AssertStoreLeague(t, reopened, League{{Name: "Cleo", Wins: 2}, {Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("records only the games it doesn't have yet", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordGame(t, store, game)
+		recorded, err := store.RecordGames([]GameRecord{game, game})
+		AssertNoError(t, err)
+
+		if len(recorded) != 1 {
+			t.Fatalf("expected only the second game to be recorded but got %v", recorded)
+		}
+		AssertStoreLeague(t, store, League{{Name: "Cleo", Wins: 2}})
+	})
+
+	t.Run("records games given at the same time once", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := store.RecordGames([]GameRecord{game}); err != nil {
+					t.Errorf("didn't expect an error recording games but got one, %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		AssertStoreLeague(t, store, League{{Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("records none of the games when one is bad", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		_, err := store.RecordGames([]GameRecord{game, {}})
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+
+		AssertStoreLeague(t, store, League{})
+	})
+
+	t.Run("deleted games leave the league", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordWins(t, store, "Chris", 1)
+		recorded := mustRecordGame(t, store, game)
+
+		AssertNoError(t, store.DeleteGame(recorded.ID))
+
+		_, err := store.GetGame(recorded.ID)
+		AssertError(t, err, ErrGameNotFound)
+		AssertStoreLeague(t, store, League{{Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("deleting an unknown game is an error", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		AssertError(t, store.DeleteGame(42), ErrGameNotFound)
+	})
+
+	t.Run("IDs of deleted games are not used again", func(t *testing.T) {
+		path := contractStorePath(t)
+
+		store, closeStore := open(t, path)
+		deleted := mustRecordGame(t, store, game)
+		AssertNoError(t, store.DeleteGame(deleted.ID))
+		closeStore()
+
+		reopened, closeReopened := open(t, path)
+		defer closeReopened()
+
+		games, err := reopened.GetGames()
+		AssertNoError(t, err)
+		if len(games) != 0 {
+			t.Fatalf("expected the game to stay deleted but got %v", games)
+		}
+
+		next := mustRecordGame(t, reopened, game)
+		if next.ID <= deleted.ID {
+			t.Errorf("got ID %d after deleting game %d", next.ID, deleted.ID)
+		}
+	})
+}
+
+func playerStoreRenameContract(t *testing.T, open PlayerStoreFactory) {
+	t.Helper()
+
+	t.Run("renaming moves a player's wins and games", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordWins(t, store, "Chirs", 2)
+		mustRecordWins(t, store, "Chris", 1)
+		recorded := mustRecordGame(t, store, GameRecord{StartedAt: time.Now().UTC(), Players: []string{"Cleo", "Chirs"}})
+
+		AssertNoError(t, store.RenamePlayer("Chirs", "Chris"))
+
+		AssertStoreLeague(t, store, League{{Name: "Chris", Wins: 3}, {Name: "Cleo", Wins: 1}})
+		game, err := store.GetGame(recorded.ID)
+		AssertNoError(t, err)
+		if game.Position("Chris") != 2 {
+			t.Errorf("expected Chris to have finished second but got %v", game.Players)
+		}
+	})
+
+	t.Run("renaming an unknown player is an error", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		AssertError(t, store.RenamePlayer("Chirs", "Chris"), ErrPlayerNotFound)
+	})
+
+	t.Run("refuses to merge two players from the same game", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		mustRecordGame(t, store, GameRecord{StartedAt: time.Now().UTC(), Players: []string{"Cleo", "Chris"}})
+
+		if err := store.RenamePlayer("Cleo", "Chris"); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+		AssertStoreLeague(t, store, League{{Name: "Cleo", Wins: 1}})
+	})
+
+	t.Run("keeps renames when reopened", func(t *testing.T) {
+		path := contractStorePath(t)
+
+		store, closeStore := open(t, path)
+		mustRecordWins(t, store, "Chirs", 1)
+		AssertNoError(t, store.RenamePlayer("Chirs", "Chris"))
+		closeStore()
+
+		reopened, closeReopened := open(t, path)
+		defer closeReopened()
+
+		AssertStoreLeague(t, reopened, League{{Name: "Chris", Wins: 1}})
+	})
+}
+
+// playerStoreChangesContract is only run against stores that are a
+// ChangeNotifier.
+func playerStoreChangesContract(t *testing.T, open PlayerStoreFactory) {
+	t.Helper()
+
+	t.Run("notifies of every change until stopped", func(t *testing.T) {
+		store, closeStore := open(t, contractStorePath(t))
+		defer closeStore()
+
+		notifier, ok := store.(ChangeNotifier)
+		if !ok {
+			t.Skip("the store doesn't notify of changes")
+		}
+
+		var mu sync.Mutex
+		changes := 0
+		stop := notifier.OnChange(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			changes++
+		})
+
+		mustRecordWins(t, store, "Chirs", 2)
+		recorded := mustRecordGame(t, store, GameRecord{StartedAt: time.Now().UTC(), Players: []string{"Cleo", "Chirs"}})
+		AssertNoError(t, store.RenamePlayer("Chirs", "Chris"))
+		AssertNoError(t, store.DeleteGame(recorded.ID))
+		AssertError(t, store.RenamePlayer("Ruth", "Chris"), ErrPlayerNotFound)
+
+		stop()
+		mustRecordWins(t, store, "Chris", 1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if changes != 5 {
+			t.Errorf("got %d changes want 5", changes)
+		}
+	})
+}
+
+func contractStorePath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("could not create temp dir %v", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	return filepath.Join(dir, "game.db")
+}
+
+func mustRecordGame(t *testing.T, store PlayerStore, game GameRecord) GameRecord {
+	t.Helper()
+
+	recorded, err := store.RecordGame(game)
+	if err != nil {
+		t.Fatalf("didn't expect an error recording a game but got one, %v", err)
+	}
+	return recorded
+}
+
+func mustRecordWins(t *testing.T, store PlayerStore, name string, wins int) {
+	t.Helper()
+
+	for i := 0; i < wins; i++ {
+		if err := store.RecordWin(name); err != nil {
+			t.Fatalf("didn't expect an error recording a win for %s but got one, %v", name, err)
+		}
+	}
+}
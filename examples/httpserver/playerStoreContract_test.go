@@ -0,0 +1,44 @@
+package poker_test
+
+import (
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"testing"
+)
+
+func TestFileSystemPlayerStoreContract(t *testing.T) {
+	poker.PlayerStoreContract(t, func(t *testing.T, path string) (poker.PlayerStore, func()) {
+		store, closeStore, err := poker.FileSystemPlayerStoreFromFile(path)
+		if err != nil {
+			t.Fatalf("problem creating file system player store, %v", err)
+		}
+		return store, closeStore
+	})
+}
+
+func TestEventLogPlayerStoreContract(t *testing.T) {
+	poker.PlayerStoreContract(t, func(t *testing.T, path string) (poker.PlayerStore, func()) {
+		store, closeStore, err := poker.EventLogPlayerStoreFromFile(path)
+		if err != nil {
+			t.Fatalf("problem creating event log player store, %v", err)
+		}
+		return store, closeStore
+	})
+}
+
+func TestAuditedPlayerStoreContract(t *testing.T) {
+	poker.PlayerStoreContract(t, func(t *testing.T, path string) (poker.PlayerStore, func()) {
+		store, closeStore, err := poker.FileSystemPlayerStoreFromFile(path)
+		if err != nil {
+			t.Fatalf("problem creating file system player store, %v", err)
+		}
+		audit, closeAudit, err := poker.AuditLogFromFile(path + ".audit")
+		if err != nil {
+			closeStore()
+			t.Fatalf("problem creating audit log, %v", err)
+		}
+		return poker.NewAuditedPlayerStore(store, audit, poker.Actor{Who: "contract", Via: poker.ViaCLI}), func() {
+			closeAudit()
+			closeStore()
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package poker
+
+import "math"
+
+// RatingSystem works out every player's rating by replaying the games in the
+// order they were recorded, so ratings can always be recomputed from history.
+type RatingSystem interface {
+	Rate(league League, games []GameRecord) League
+}
+
+// Elo rates a game of many players as if everyone played everyone below them
+// in the finishing order, sharing K between those pairings.
+type Elo struct {
+	Initial float64
+	K       float64
+}
+
+var DefaultRatingSystem = Elo{Initial: 1500, K: 32}
+
+func (e Elo) Rate(league League, games []GameRecord) League {
+	ratings := map[string]float64{}
+	rating := func(name string) float64 {
+		if r, ok := ratings[name]; ok {
+			return r
+		}
+		return e.Initial
+	}
+
+	for _, game := range games {
+		if len(game.Players) < 2 {
+			continue
+		}
+
+		before := make([]float64, len(game.Players))
+		for i, player := range game.Players {
+			before[i] = rating(player)
+		}
+
+		k := e.K / float64(len(game.Players)-1)
+		after := make([]float64, len(game.Players))
+		copy(after, before)
+
+		for winner := range game.Players {
+			for loser := winner + 1; loser < len(game.Players); loser++ {
+				expected := 1 / (1 + math.Pow(10, (before[loser]-before[winner])/400))
+				change := k * (1 - expected)
+				after[winner] += change
+				after[loser] -= change
+			}
+		}
+
+		for i, player := range game.Players {
+			ratings[player] = after[i]
+		}
+	}
+
+	rated := make(League, len(league))
+	for i, player := range league {
+		player.Rating = math.Round(rating(player.Name)*10) / 10
+		rated[i] = player
+	}
+	return rated
+}
+
+// RatedLeague is the store's league with every player's rating and when they
+// last played filled in.
+func RatedLeague(store PlayerStore, system RatingSystem) (League, error) {
+	league, err := store.GetLeague()
+	if err != nil {
+		return nil, err
+	}
+
+	games, err := store.GetGames()
+	if err != nil {
+		return nil, err
+	}
+
+	return rateLeague(system, league, games), nil
+}
+
+func rateLeague(system RatingSystem, league League, games []GameRecord) League {
+	return system.Rate(league, games).withLastPlayed(games)
+}
@@ -0,0 +1,57 @@
+package poker_test
+
+import (
+	"testing"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+func TestElo(t *testing.T) {
+	elo := poker.Elo{Initial: 1500, K: 32}
+
+	t.Run("players without rated games keep the initial rating", func(t *testing.T) {
+		league := poker.League{{Name: "Cleo", Wins: 3}}
+		games := []poker.GameRecord{{ID: 1, Players: []string{"Cleo"}}}
+
+		got := elo.Rate(league, games)
+		poker.AssertLeague(t, got, poker.League{{Name: "Cleo", Wins: 3, Rating: 1500}})
+	})
+
+	t.Run("the winner takes rating from the loser", func(t *testing.T) {
+		league := poker.League{{Name: "Cleo", Wins: 1}, {Name: "Chris"}}
+		games := []poker.GameRecord{{ID: 1, Players: []string{"Cleo", "Chris"}}}
+
+		got := elo.Rate(league, games)
+		poker.AssertLeague(t, got, poker.League{{Name: "Cleo", Wins: 1, Rating: 1516}, {Name: "Chris", Rating: 1484}})
+	})
+
+	t.Run("K is shared between everyone in a bigger game", func(t *testing.T) {
+		league := poker.League{{Name: "Cleo", Wins: 1}, {Name: "Chris"}, {Name: "Ruth"}}
+		games := []poker.GameRecord{{ID: 1, Players: []string{"Cleo", "Chris", "Ruth"}}}
+
+		got := elo.Rate(league, games)
+		poker.AssertLeague(t, got, poker.League{
+			{Name: "Cleo", Wins: 1, Rating: 1516},
+			{Name: "Chris", Rating: 1500},
+			{Name: "Ruth", Rating: 1484},
+		})
+	})
+
+	t.Run("it does not change the league it was given", func(t *testing.T) {
+		league := poker.League{{Name: "Cleo", Wins: 1}}
+
+		elo.Rate(league, nil)
+		poker.AssertLeague(t, league, poker.League{{Name: "Cleo", Wins: 1}})
+	})
+}
+
+func TestRatedLeague(t *testing.T) {
+	store := &poker.StubPlayerStore{
+		League: poker.League{{Name: "Cleo", Wins: 1}, {Name: "Chris"}},
+		Games:  []poker.GameRecord{{ID: 1, Players: []string{"Cleo", "Chris"}}},
+	}
+
+	got, err := poker.RatedLeague(store, poker.DefaultRatingSystem)
+	poker.AssertNoError(t, err)
+	poker.AssertLeague(t, got, poker.League{{Name: "Cleo", Wins: 1, Rating: 1516}, {Name: "Chris", Rating: 1484}})
+}
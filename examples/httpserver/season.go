@@ -0,0 +1,91 @@
+package poker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultLeagueName is the league games are played in when no other league is
+// chosen.
+const DefaultLeagueName = "default"
+
+// LeagueName is the league the game was played in.
+func (g GameRecord) LeagueName() string {
+	if g.League == "" {
+		return DefaultLeagueName
+	}
+	return g.League
+}
+
+// SeasonName is the season the game was played in, which is the quarter it
+// started in unless a season was chosen.
+func (g GameRecord) SeasonName() string {
+	if g.Season == "" {
+		return Quarter(g.StartedAt)
+	}
+	return g.Season
+}
+
+// Quarter names the season t falls in, like 2019-Q1.
+func Quarter(t time.Time) string {
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+}
+
+// GameFilter picks out the games of a league, a season or both. An empty
+// field matches every game.
+type GameFilter struct {
+	League string
+	Season string
+}
+
+func (f GameFilter) Matches(game GameRecord) bool {
+	if f.League != "" && game.LeagueName() != f.League {
+		return false
+	}
+	if f.Season != "" && game.SeasonName() != f.Season {
+		return false
+	}
+	return true
+}
+
+func (f GameFilter) Games(games []GameRecord) []GameRecord {
+	var matched []GameRecord
+	for _, game := range games {
+		if f.Matches(game) {
+			matched = append(matched, game)
+		}
+	}
+	return matched
+}
+
+// LeagueOf adds up the winners of games. Unlike GetLeague it leaves out
+// legacy wins, which were recorded before leagues and seasons were kept.
+func LeagueOf(games []GameRecord) League {
+	return History{Games: games}.League()
+}
+
+// LeagueNames lists the leagues games have been played in.
+func LeagueNames(games []GameRecord) []string {
+	return names(games, GameRecord.LeagueName)
+}
+
+// SeasonNames lists the seasons games have been played in.
+func SeasonNames(games []GameRecord) []string {
+	return names(games, GameRecord.SeasonName)
+}
+
+func names(games []GameRecord, name func(GameRecord) string) []string {
+	seen := map[string]bool{}
+	found := []string{}
+
+	for _, game := range games {
+		if n := name(game); !seen[n] {
+			seen[n] = true
+			found = append(found, n)
+		}
+	}
+
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,82 @@
+package poker_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+)
+
+func TestQuarter(t *testing.T) {
+	cases := []struct {
+		month time.Month
+		want  string
+	}{
+		{time.January, "2019-Q1"},
+		{time.March, "2019-Q1"},
+		{time.April, "2019-Q2"},
+		{time.September, "2019-Q3"},
+		{time.December, "2019-Q4"},
+	}
+
+	for _, c := range cases {
+		got := poker.Quarter(time.Date(2019, c.month, 15, 0, 0, 0, 0, time.UTC))
+		if got != c.want {
+			t.Errorf("got %q for %v want %q", got, c.month, c.want)
+		}
+	}
+}
+
+func TestGameFilter(t *testing.T) {
+	games := []poker.GameRecord{
+		{ID: 1, StartedAt: gameTime, Players: []string{"Cleo"}},
+		{ID: 2, StartedAt: gameTime, League: "Friday cash", Players: []string{"Chris"}},
+		{ID: 3, StartedAt: gameTime, League: "Friday cash", Season: "spring", Players: []string{"Ruth"}},
+	}
+
+	t.Run("an empty filter matches every game", func(t *testing.T) {
+		got := poker.GameFilter{}.Games(games)
+		assertGameIDs(t, got, 1, 2, 3)
+	})
+
+	t.Run("games without a league are in the default league", func(t *testing.T) {
+		got := poker.GameFilter{League: poker.DefaultLeagueName}.Games(games)
+		assertGameIDs(t, got, 1)
+	})
+
+	t.Run("games without a season are in the quarter they were played", func(t *testing.T) {
+		got := poker.GameFilter{League: "Friday cash", Season: "2019-Q1"}.Games(games)
+		assertGameIDs(t, got, 2)
+	})
+
+	t.Run("it names the leagues and seasons played", func(t *testing.T) {
+		leagues := poker.LeagueNames(games)
+		if !reflect.DeepEqual(leagues, []string{"Friday cash", "default"}) {
+			t.Errorf("got leagues %v", leagues)
+		}
+
+		seasons := poker.SeasonNames(games)
+		if !reflect.DeepEqual(seasons, []string{"2019-Q1", "spring"}) {
+			t.Errorf("got seasons %v", seasons)
+		}
+	})
+
+	t.Run("league and season names can't contain a slash", func(t *testing.T) {
+		game := poker.GameRecord{League: "Friday/cash", Players: []string{"Cleo"}}
+		if game.Validate() == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func assertGameIDs(t *testing.T, games []poker.GameRecord, ids ...int) {
+	t.Helper()
+	var got []int
+	for _, game := range games {
+		got = append(got, game.ID)
+	}
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("got games %v want %v", got, ids)
+	}
+}
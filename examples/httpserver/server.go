@@ -2,139 +2,718 @@ package poker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type PlayerServer struct {
 	store PlayerStore
 	http.Handler
 	template *template.Template
-	game     Game
+	exporter *LeagueExporter
+	games    *GameRegistry
+
+	leagueHeartbeat time.Duration
 }
 
 const JsonContentType = "application/json"
+const LeagueErrMsg = "Could not get the league"
+const ScoreErrMsg = "Could not get the score"
+const GamesErrMsg = "Could not get the games"
+const StatsErrMsg = "Could not get the player's stats"
+const RenameErrMsg = "Could not rename the player"
+const ReverseErrMsg = "Could not reverse the change"
+const UserHeader = "X-Poker-User"
+const ImportErrMsg = "Could not import the games"
+const maxImportSize = 10 << 20
 const htmlTemplatePath = "static/game.html"
+const SpectatorErrMsg = "Spectators can only watch the game"
+const LeagueStreamErrMsg = "The league can't be streamed from this store"
 
-var wsUpgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+// NewPlayerServer makes a server where each table plays a game of its own,
+// made by newGame.
+func NewPlayerServer(store PlayerStore, newGame func() Game) (*PlayerServer, error) {
+	return NewPlayerServerWithGames(store, NewGameRegistry(newGame))
 }
 
-type playerServerWS struct {
-	*websocket.Conn
+// NewPlayerServerWithGames makes a server that plays a game from games at
+// each table.
+func NewPlayerServerWithGames(store PlayerStore, games *GameRegistry) (*PlayerServer, error) {
+	p := new(PlayerServer)
+
+	tmpl, err := template.ParseFiles(htmlTemplatePath)
+
+	if err != nil {
+		return nil, fmt.Errorf("problem opening %s %v", htmlTemplatePath, err)
+	}
+
+	p.template = tmpl
+
+	exporter, err := NewLeagueExporter(LeagueTemplatePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.exporter = exporter
+
+	p.store = store
+	p.games = games
+	p.leagueHeartbeat = DefaultLeagueHeartbeat
+
+	router := http.NewServeMux()
+	router.Handle("/league", http.HandlerFunc(p.leagueHandler))
+	router.Handle("/league/stream", http.HandlerFunc(p.leagueStreamHandler))
+	router.Handle("/leagues", http.HandlerFunc(p.leaguesHandler))
+	router.Handle("/leagues/", http.HandlerFunc(p.namedLeagueHandler))
+	router.Handle("/players/", http.HandlerFunc(p.playersHandler))
+	router.Handle("/game", http.HandlerFunc(p.playGame))
+	router.Handle("/ws", http.HandlerFunc(p.webSocket))
+	router.Handle("/games", http.HandlerFunc(p.gamesHandler))
+	router.Handle("/games/", http.HandlerFunc(p.gameHandler))
+	router.Handle("/import", http.HandlerFunc(p.importHandler))
+	router.Handle("/audit", http.HandlerFunc(p.auditHandler))
+	router.Handle("/audit/", http.HandlerFunc(p.auditHandler))
+
+	p.Handler = router
+	return p, nil
 }
 
-func newPlayerServerWS(w http.ResponseWriter, r *http.Request) *playerServerWS {
-	conn, err := wsUpgrader.Upgrade(w, r, nil)
+// SetLeagueHeartbeat sets how often a quiet /league/stream is sent a
+// heartbeat.
+func (p *PlayerServer) SetLeagueHeartbeat(every time.Duration) {
+	p.leagueHeartbeat = every
+}
 
+// webSocket plays a game at a table of its own.
+func (p *PlayerServer) webSocket(w http.ResponseWriter, r *http.Request) {
+	p.playAtTable(w, r, "")
+}
+
+// playAtTable plays the game at the table with id over a websocket. The
+// first client to send a start request starts the game, and the others join
+// it as it is. Players coming back give the session they were sent in the
+// session query parameter instead.
+func (p *PlayerServer) playAtTable(w http.ResponseWriter, r *http.Request, id string) {
+	ws, err := newPlayerServerWS(w, r)
 	if err != nil {
-		log.Printf("problem upgrading connection to WebSockets %v\n", err)
+		return
+	}
+
+	var table *Table
+	if token := r.URL.Query().Get("session"); token != "" {
+		table, err = p.games.Rejoin(token, ws)
+		if err != nil {
+			ws.WriteError(wsErrorf(BadSessionCode, "%v", err))
+			ws.Close()
+			return
+		}
+	} else {
+		table, err = p.games.Join(id, ws)
+		if err != nil {
+			ws.WriteError(wsErrorf(InvalidCode, "%v", err))
+			ws.Close()
+			return
+		}
+	}
+	// Leaving sends the client what is left for it and then closes the
+	// connection, in the background.
+	defer p.games.Leave(table, ws)
+
+	for {
+		request, err := ws.ReadRequest()
+		var wsErr WSError
+		if errors.As(err, &wsErr) {
+			ws.WriteError(wsErr)
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		finished, err := playRequest(table, request, ws)
+		if err != nil {
+			ws.WriteError(err)
+		}
+		if finished {
+			return
+		}
+	}
+}
+
+// playRequest does what a player asked of the game at table, reporting
+// whether the game is over.
+func playRequest(table *Table, request WSRequest, ws *playerServerWS) (bool, error) {
+	if request.Type == PingType {
+		return false, ws.WritePong()
+	}
+
+	if request.Type == StartType {
+		if !table.Start(request.Players) {
+			return false, wsErrorf(AlreadyStartedCode, "the game at %s has already started", table.ID)
+		}
+		return false, nil
+	}
+
+	if !table.Started() {
+		return false, wsErrorf(NotStartedCode, "%v", ErrGameNotStarted)
 	}
 
-	return &playerServerWS{conn}
+	switch request.Type {
+	case ClockType:
+		return false, table.Clock(request.Command, ws)
+	case OutType:
+		return false, table.Eliminate(request.Player)
+	case FinishType:
+		if _, err := table.Finish(request.Winner, request.RunnersUp...); err != nil {
+			log.Printf("problem finishing game at %s, %v", table.ID, err)
+			return false, fmt.Errorf("%s, %v", RecordWinErrMsg, err)
+		}
+		return true, nil
+	}
+	return false, nil
 }
 
-func (w *playerServerWS) WaitForMsg() string {
-	_, msg, err := w.ReadMessage()
+// watchTable sends everything that happens at the table with id down a
+// websocket, without letting the client change the game.
+func (p *PlayerServer) watchTable(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := p.games.Table(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws, err := newPlayerServerWS(w, r)
+	if err != nil {
+		return
+	}
+
+	table, err := p.games.Watch(id, ws)
 	if err != nil {
-		log.Printf("error reading from websocket %v\n", err)
+		ws.WriteError(err)
+		ws.Close()
+		return
+	}
+	defer p.games.Leave(table, ws)
+
+	for {
+		request, err := ws.ReadRequest()
+		var wsErr WSError
+		switch {
+		case errors.As(err, &wsErr):
+			ws.WriteError(wsErr)
+		case err != nil:
+			return
+		case request.Type == PingType:
+			ws.WritePong()
+		default:
+			ws.WriteError(WSError{Code: SpectatorCode, Message: SpectatorErrMsg})
+		}
 	}
-	return string(msg)
 }
 
-func (w *playerServerWS) Write(p []byte) (n int, err error) {
-	err = w.WriteMessage(1, p)
+func (p *PlayerServer) playGame(w http.ResponseWriter, r *http.Request) {
+	p.template.Execute(w, nil)
+}
 
+func (p *PlayerServer) leagueHandler(w http.ResponseWriter, r *http.Request) {
+	league, err := RatedLeague(p.store, DefaultRatingSystem)
 	if err != nil {
-		return 0, err
+		log.Printf("problem getting league, %v", err)
+		http.Error(w, LeagueErrMsg, http.StatusInternalServerError)
+		return
 	}
 
-	return len(p), nil
+	p.writeLeague(w, r, league)
 }
 
-func NewPlayerServer(store PlayerStore, game Game) (*PlayerServer, error) {
-	p := new(PlayerServer)
+// leagueStreamHandler sends the standings picked out by the LeagueQuery in r
+// as server-sent events, and again whenever the store changes them. Clients
+// resuming with a Last-Event-ID are only sent standings they don't have.
+func (p *PlayerServer) leagueStreamHandler(w http.ResponseWriter, r *http.Request) {
+	notifier, ok := p.store.(ChangeNotifier)
+	flusher, canFlush := w.(http.Flusher)
+	if !ok || !canFlush {
+		http.Error(w, LeagueStreamErrMsg, http.StatusNotImplemented)
+		return
+	}
 
-	tmpl, err := template.ParseFiles(htmlTemplatePath)
+	query, err := ParseLeagueQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	changes := make(chan struct{}, 1)
+	stop := notifier.OnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	defer stop()
+
+	league, err := RatedLeague(p.store, DefaultRatingSystem)
 	if err != nil {
-		return nil, fmt.Errorf("problem opening %s %v", htmlTemplatePath, err)
+		log.Printf("problem getting league, %v", err)
+		http.Error(w, LeagueErrMsg, http.StatusInternalServerError)
+		return
 	}
 
-	p.template = tmpl
+	page, err := query.Run(league)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	p.store = store
-	p.game = game
+	w.Header().Set("content-type", EventStreamContentType)
+	w.Header().Set("cache-control", "no-cache")
 
-	router := http.NewServeMux()
-	router.Handle("/league", http.HandlerFunc(p.leagueHandler))
-	router.Handle("/players/", http.HandlerFunc(p.playersHandler))
-	router.Handle("/game", http.HandlerFunc(p.playGame))
-	router.Handle("/ws", http.HandlerFunc(p.webSocket))
+	events := &leagueEvents{w: w, lastID: r.Header.Get("Last-Event-ID")}
+	if err := events.retry(leagueRetry); err != nil {
+		return
+	}
+	if err := events.send(page.Players); err != nil {
+		return
+	}
+	flusher.Flush()
 
-	p.Handler = router
-	return p, nil
+	heartbeat := time.NewTicker(p.leagueHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = events.heartbeat()
+		case <-changes:
+			if league, err = RatedLeague(p.store, DefaultRatingSystem); err != nil {
+				log.Printf("problem getting league, %v", err)
+				continue
+			}
+			if page, err = query.Run(league); err != nil {
+				log.Printf("problem paging league, %v", err)
+				continue
+			}
+			err = events.send(page.Players)
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
 }
 
-func (p *PlayerServer) webSocket(w http.ResponseWriter, r *http.Request) {
-	ws := newPlayerServerWS(w, r)
+func (p *PlayerServer) leaguesHandler(w http.ResponseWriter, r *http.Request) {
+	games, err := p.store.GetGames()
+	if err != nil {
+		log.Printf("problem getting games, %v", err)
+		http.Error(w, LeagueErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, LeagueNames(games))
+}
+
+// namedLeagueHandler serves /leagues/{name}, the all-time standings of a
+// league, /leagues/{name}/seasons and /leagues/{name}/seasons/{season}.
+func (p *PlayerServer) namedLeagueHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/leagues/"), "/")
 
-	numberOfPlayersMsg := ws.WaitForMsg()
-	numberOfPlayers, _ := strconv.Atoi(numberOfPlayersMsg)
-	p.game.Start(numberOfPlayers, ws) //todo: Don't discard the blinds messages!
+	games, err := p.store.GetGames()
+	if err != nil {
+		log.Printf("problem getting games, %v", err)
+		http.Error(w, LeagueErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	games = GameFilter{League: parts[0]}.Games(games)
+	if len(games) == 0 || (len(parts) > 1 && parts[1] != "seasons") || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
 
-	winner := ws.WaitForMsg()
-	p.game.Finish(winner)
+	switch len(parts) {
+	case 1:
+		p.writeLeague(w, r, rateLeague(DefaultRatingSystem, LeagueOf(games), games))
+	case 2:
+		writeJSON(w, SeasonNames(games))
+	case 3:
+		games = GameFilter{Season: parts[2]}.Games(games)
+		if len(games) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		p.writeLeague(w, r, rateLeague(DefaultRatingSystem, LeagueOf(games), games))
+	}
 }
 
-func (p *PlayerServer) playGame(w http.ResponseWriter, r *http.Request) {
-	p.template.Execute(w, nil)
+// writeLeague sends the part of league picked out by the LeagueQuery in
+// r, in the format asked for by ?format= or failing that the Accept header.
+func (p *PlayerServer) writeLeague(w http.ResponseWriter, r *http.Request, league League) {
+	query, err := ParseLeagueQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := query.Run(league)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		negotiated, ok := negotiateFormat(r.Header.Get("Accept"))
+		if !ok {
+			http.Error(w, ErrUnknownFormat.Error(), http.StatusNotAcceptable)
+			return
+		}
+		format = negotiated
+	}
+
+	contentType := ContentType(format)
+	if contentType == "" {
+		http.Error(w, ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
+	w.Header().Add("vary", "Accept")
+	w.Header().Set("x-total-count", strconv.Itoa(page.Total))
+	if links := leagueLinks(r.URL, query, page); len(links) > 0 {
+		w.Header().Set("link", strings.Join(links, ", "))
+	}
+
+	if err := p.exporter.Export(w, format, page.Players); err != nil {
+		log.Printf("problem exporting league as %s, %v", format, err)
+	}
 }
 
-func (p *PlayerServer) leagueHandler(w http.ResponseWriter, r *http.Request) {
+// leagueLinks are the Link header values for the pages around page. They
+// page by cursor when the query did and by offset otherwise.
+func leagueLinks(u *url.URL, query LeagueQuery, page LeaguePage) []string {
+	if query.Limit == 0 {
+		return nil
+	}
+
+	link := func(rel string, set map[string]string) string {
+		values := u.Query()
+		values.Del("offset")
+		values.Del("cursor")
+		for key, value := range set {
+			values.Set(key, value)
+		}
+
+		linked := *u
+		linked.RawQuery = values.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", linked.RequestURI(), rel)
+	}
+
+	links := []string{link("first", nil)}
+
+	if page.NextCursor != "" {
+		if query.Cursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": page.NextCursor}))
+		} else {
+			next := page.Offset + len(page.Players)
+			links = append(links, link("next", map[string]string{"offset": strconv.Itoa(next)}))
+		}
+	}
+
+	if query.Cursor == "" && page.Offset > 0 {
+		prev := page.Offset - query.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link("prev", map[string]string{"offset": strconv.Itoa(prev)}))
+	}
+
+	return links
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("content-type", JsonContentType)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("problem encoding %T, %v", v, err)
+	}
+}
+
+func (p *PlayerServer) gamesHandler(w http.ResponseWriter, r *http.Request) {
+	games, err := p.store.GetGames()
+	if err != nil {
+		log.Printf("problem getting games, %v", err)
+		http.Error(w, GamesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	if games == nil {
+		games = []GameRecord{}
+	}
+
 	w.Header().Set("content-type", JsonContentType)
-	err := json.NewEncoder(w).Encode(p.store.GetLeague())
+	if err := json.NewEncoder(w).Encode(games); err != nil {
+		log.Printf("problem encoding games, %v", err)
+	}
+}
+
+// gameHandler serves /games/{id}. Numeric IDs are recorded games, and other
+// IDs are the tables being played at, with /games/{id}/ws to play at one,
+// /games/{id}/watch to follow one and /games/active listing them.
+func (p *PlayerServer) gameHandler(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path[len("/games/"):]
+
+	if path == "active" {
+		writeJSON(w, p.games.Active())
+		return
+	}
+
+	if id := strings.TrimSuffix(path, "/watch"); id != path {
+		p.watchTable(w, r, id)
+		return
+	}
+
+	if id := strings.TrimSuffix(path, "/ws"); id != path {
+		if !ValidTableID(id) {
+			http.Error(w, ErrBadTableID.Error(), http.StatusBadRequest)
+			return
+		}
+		p.playAtTable(w, r, id)
+		return
+	}
+
+	id, err := strconv.Atoi(path)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Fatalf("Failed to encode object %q", p.getLeagueTable())
+		p.showTable(w, r, path)
+		return
+	}
+
+	game, err := p.store.GetGame(id)
+	if errors.Is(err, ErrGameNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		log.Printf("problem getting game %d, %v", id, err)
+		http.Error(w, GamesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", JsonContentType)
+	if err := json.NewEncoder(w).Encode(game); err != nil {
+		log.Printf("problem encoding game %d, %v", id, err)
+	}
+}
+
+func (p *PlayerServer) showTable(w http.ResponseWriter, r *http.Request, id string) {
+	table, ok := p.games.Table(id)
+	if !ok {
+		http.NotFound(w, r)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+
+	writeJSON(w, table.Status())
 }
 
 func (p *PlayerServer) playersHandler(w http.ResponseWriter, r *http.Request) {
 	player := r.URL.Path[len("/players/"):]
 
-	switch r.Method {
-	case http.MethodPost:
-		p.processWin(w, player)
-	case http.MethodGet:
+	switch {
+	case strings.HasSuffix(player, "/rename"):
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		p.renamePlayer(w, r, strings.TrimSuffix(player, "/rename"))
+	case strings.HasSuffix(player, "/stats"):
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		p.showStats(w, r, strings.TrimSuffix(player, "/stats"))
+	case strings.Contains(player, "/"):
+		http.NotFound(w, r)
+	case r.Method == http.MethodPost:
+		p.processWin(w, r, player)
+	case r.Method == http.MethodGet:
 		p.showScore(w, player)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
 	}
 }
 
+// methodNotAllowed tells the client which of the methods it can use instead.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("allow", strings.Join(allowed, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
 func (p *PlayerServer) showScore(w http.ResponseWriter, player string) {
-	score := p.store.GetPlayerScore(player)
+	score, err := p.store.GetPlayerScore(player)
 
-	if score == 0 {
+	if errors.Is(err, ErrPlayerNotFound) {
 		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, 0)
+		return
+	}
+
+	if err != nil {
+		log.Printf("problem getting score for %s, %v", player, err)
+		http.Error(w, ScoreErrMsg, http.StatusInternalServerError)
+		return
 	}
 
 	fmt.Fprint(w, score)
 }
 
-func (p *PlayerServer) processWin(w http.ResponseWriter, player string) {
-	p.store.RecordWin(player)
+func (p *PlayerServer) showStats(w http.ResponseWriter, r *http.Request, player string) {
+	games, err := p.store.GetGames()
+	if err != nil {
+		log.Printf("problem getting games, %v", err)
+		http.Error(w, StatsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := StatsFor(player, games)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, stats)
+}
+
+func (p *PlayerServer) processWin(w http.ResponseWriter, r *http.Request, player string) {
+	if err := p.storeFor(r).RecordWin(player); err != nil {
+		log.Printf("problem recording win, %v", err)
+		http.Error(w, RecordWinErrMsg, http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusAccepted)
 }
 
-func (p *PlayerServer) getLeagueTable() League {
-	return League{
-		{"Chris", 20},
+// renamePlayer gives everything player has done to the name in the to form
+// value.
+func (p *PlayerServer) renamePlayer(w http.ResponseWriter, r *http.Request, player string) {
+	err := p.storeFor(r).RenamePlayer(player, r.FormValue("to"))
+
+	var renameErr RenameError
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		http.NotFound(w, r)
+	case errors.As(err, &renameErr):
+		http.Error(w, renameErr.Error(), http.StatusConflict)
+	case err != nil:
+		log.Printf("problem renaming %s, %v", player, err)
+		http.Error(w, RenameErrMsg, http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// importHandler imports the games posted to it as CSV or JSON, going by
+// ?format= or the content type. It answers with the ImportReport, which has
+// the row errors when nothing could be imported.
+func (p *PlayerServer) importHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatFor(strings.TrimSpace(strings.Split(r.Header.Get("content-type"), ";")[0]))
+	}
+
+	report, err := ImportGames(p.storeFor(r), http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if errors.Is(err, ErrUnknownImportFormat) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err != nil {
+		log.Printf("problem importing games, %v", err)
+		http.Error(w, ImportErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		w.Header().Set("content-type", JsonContentType)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+// auditHandler serves the audit log and reverses entries posted to
+// /audit/{id}/reverse. It's only there when the store is audited.
+func (p *PlayerServer) auditHandler(w http.ResponseWriter, r *http.Request) {
+	audited, ok := p.store.(*AuditedPlayerStore)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Path == "/audit" && r.Method == http.MethodGet {
+		writeJSON(w, audited.AuditLog().Entries())
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/audit/")
+	id, err := strconv.Atoi(strings.TrimSuffix(path, "/reverse"))
+	if err != nil || !strings.HasSuffix(path, "/reverse") || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	entry, err := audited.As(requestActor(r)).Reverse(id)
+	var reverseErr ReverseError
+	switch {
+	case errors.Is(err, ErrAuditEntryNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, ErrAlreadyReversed), errors.Is(err, ErrNotReversible), errors.Is(err, ErrGameNotFound):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &reverseErr):
+		log.Printf("problem reversing audit entry %d, %v", id, err)
+		http.Error(w, reverseErr.Error(), http.StatusInternalServerError)
+	case err != nil:
+		log.Printf("problem reversing audit entry %d, %v", id, err)
+		http.Error(w, ReverseErrMsg, http.StatusInternalServerError)
+	default:
+		writeJSON(w, entry)
+	}
+}
+
+// storeFor is the store to make the changes asked for by r through, so they
+// are audited as whoever sent it.
+func (p *PlayerServer) storeFor(r *http.Request) PlayerStore {
+	if audited, ok := p.store.(*AuditedPlayerStore); ok {
+		return audited.As(requestActor(r))
+	}
+	return p.store
+}
+
+// requestActor is the user named in the UserHeader of r, or where it came
+// from when there isn't one.
+func requestActor(r *http.Request) Actor {
+	who := r.Header.Get(UserHeader)
+	if who == "" {
+		who = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			who = host
+		}
 	}
+	return Actor{Who: who, Via: ViaHTTP}
 }
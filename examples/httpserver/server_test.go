@@ -1,7 +1,10 @@
 package poker_test
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
@@ -9,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -17,12 +21,10 @@ import (
 
 func TestGETPlayers(t *testing.T) {
 	store := poker.StubPlayerStore{
-		map[string]int{
+		Scores: map[string]int{
 			"Pepper": 20,
 			"Floyd":  10,
 		},
-		nil,
-		nil,
 	}
 	server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
 
@@ -57,13 +59,23 @@ func TestGETPlayers(t *testing.T) {
 		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
 	})
 
+	t.Run("returns 500 when the score can't be read", func(t *testing.T) {
+		failingStore := &poker.StubPlayerStore{ScoreError: errors.New("disk on fire")}
+		server := mustMakePlayerServer(t, failingStore, &poker.GameSpy{})
+
+		request := newGetScoreRequest("Pepper")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusInternalServerError)
+	})
+
 }
 
 func TestStoreWins(t *testing.T) {
 	store := poker.StubPlayerStore{
-		map[string]int{},
-		nil,
-		nil,
+		Scores: map[string]int{},
 	}
 	server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
 
@@ -94,6 +106,18 @@ func TestStoreWins(t *testing.T) {
 			t.Errorf("did not store correct winner got %q want %q", store.WinCalls[0], player)
 		}
 	})
+
+	t.Run("it returns 500 when the win can't be recorded", func(t *testing.T) {
+		failingStore := &poker.StubPlayerStore{WinError: errors.New("disk full")}
+		server := mustMakePlayerServer(t, failingStore, &poker.GameSpy{})
+
+		request := newPostWinRequest("Pepper")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusInternalServerError)
+	})
 }
 
 func TestRecordingWinsAndRetrievingThem(t *testing.T) {
@@ -126,9 +150,9 @@ func TestRecordingWinsAndRetrievingThem(t *testing.T) {
 
 		got := getLeagueFromResponse(t, response.Body)
 		want := poker.League{
-			{"Pepper", 3},
+			{Name: "Pepper", Wins: 3, Position: 1},
 		}
-		poker.AssertLeague(t, got, want)
+		assertStandings(t, got, want)
 	})
 }
 
@@ -202,12 +226,12 @@ func TestLeague(t *testing.T) {
 
 	t.Run("it returns the league table as JSON", func(t *testing.T) {
 		wantedLeague := poker.League{
-			{"Cleo", 32},
-			{"Chris", 20},
-			{"Tiest", 14},
+			{Name: "Cleo", Wins: 32},
+			{Name: "Chris", Wins: 20},
+			{Name: "Tiest", Wins: 14},
 		}
 
-		store := poker.StubPlayerStore{nil, nil, wantedLeague}
+		store := poker.StubPlayerStore{League: wantedLeague}
 		server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
 
 		request := newLeagueRequest()
@@ -217,132 +241,1256 @@ func TestLeague(t *testing.T) {
 
 		got := getLeagueFromResponse(t, response.Body)
 		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
-		poker.AssertLeague(t, got, wantedLeague)
+		poker.AssertLeague(t, got, poker.League{
+			{Name: "Cleo", Wins: 32, Rating: 1500, Position: 1},
+			{Name: "Chris", Wins: 20, Rating: 1500, Position: 2},
+			{Name: "Tiest", Wins: 14, Rating: 1500, Position: 3},
+		})
 
 		poker.AssertContentType(t, response, poker.JsonContentType)
 
 	})
-}
 
-func TestGame(t *testing.T) {
-	tenMs := 10 * time.Millisecond
-	t.Run("GET /game returns 200", func(t *testing.T) {
-		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+	t.Run("it returns 500 when the league can't be read", func(t *testing.T) {
+		store := poker.StubPlayerStore{LeagueError: errors.New("disk on fire")}
+		server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
 
-		request := newGameRequest()
+		request := newLeagueRequest()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusInternalServerError)
+	})
+
+	t.Run("it sorts the league by rating", func(t *testing.T) {
+		store := poker.StubPlayerStore{
+			League: poker.League{{Name: "Cleo", Wins: 2}, {Name: "Chris", Wins: 1}},
+			Games: []poker.GameRecord{
+				{ID: 1, Players: []string{"Cleo", "Chris"}},
+				{ID: 2, Players: []string{"Chris", "Cleo"}},
+				{ID: 3, Players: []string{"Cleo"}},
+			},
+		}
+		server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/league?sort=rating", nil)
 		response := httptest.NewRecorder()
 
 		server.ServeHTTP(response, request)
 
 		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertLeague(t, getLeagueFromResponse(t, response.Body), poker.League{
+			{Name: "Chris", Wins: 1, Rating: 1501.5, Position: 1},
+			{Name: "Cleo", Wins: 2, Rating: 1498.5, Position: 2},
+		})
 	})
 
-	t.Run("when we get a message over a websocket it is a winner of a game", func(t *testing.T) {
-		store := &poker.StubPlayerStore{}
-		game := &poker.GameSpy{}
-		winner := "Ruth"
-		server := httptest.NewServer(mustMakePlayerServer(t, store, game))
-		defer server.Close()
+	t.Run("it pages the league with Link headers", func(t *testing.T) {
+		store := poker.StubPlayerStore{League: poker.League{
+			{Name: "Cleo", Wins: 4}, {Name: "Chris", Wins: 3}, {Name: "Ruth", Wins: 2}, {Name: "Tiest", Wins: 1},
+		}}
+		server := mustMakePlayerServer(t, &store, &poker.GameSpy{})
 
-		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+		request, _ := http.NewRequest(http.MethodGet, "/league?limit=2&offset=1", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
 
-		ws := mustDialWS(t, wsURL)
-		defer ws.Close()
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		assertPlayerNames(t, getLeagueFromResponse(t, response.Body), "Chris", "Ruth")
 
-		writeWSMessage(t, ws, "3")
-		writeWSMessage(t, ws, winner)
+		wantLinks := `</league?limit=2>; rel="first", </league?limit=2&offset=3>; rel="next", </league?limit=2&offset=0>; rel="prev"`
+		if got := response.Header().Get("link"); got != wantLinks {
+			t.Errorf("got Link %q want %q", got, wantLinks)
+		}
+		if got := response.Header().Get("x-total-count"); got != "4" {
+			t.Errorf("got total count %q want 4", got)
+		}
+	})
 
-		time.Sleep(tenMs)
+	t.Run("it returns 400 for a bad cursor", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
 
-		poker.AssertFinishCalledWith(t, game, winner)
+		request, _ := http.NewRequest(http.MethodGet, "/league?cursor=bm9ib2R5", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusBadRequest)
 	})
 
-	t.Run("start a game with 3 players and declare Ruth the winner", func(t *testing.T) {
-		game := &poker.GameSpy{}
-		winner := "Ruth"
-		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
-		defer server.Close()
+	t.Run("it returns 400 for an unknown sort", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
 
-		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
-		defer ws.Close()
+		request, _ := http.NewRequest(http.MethodGet, "/league?sort=luck", nil)
+		response := httptest.NewRecorder()
 
-		writeWSMessage(t, ws, "3")
-		writeWSMessage(t, ws, winner)
+		server.ServeHTTP(response, request)
 
-		time.Sleep(tenMs)
-		poker.AssertGameStartedWith(t, game, 3)
-		poker.AssertFinishCalledWith(t, game, winner)
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusBadRequest)
 	})
+}
 
-	t.Run("start a game with 3 players, send some blind alerts down WS and declare Ruth the winner", func(t *testing.T) {
-		wantedBlindAlert := "Blind is 100"
-		winner := "Ruth"
+func TestLeagueStream(t *testing.T) {
+	newServer := func(t *testing.T) (*httptest.Server, func()) {
+		database, cleanDatabase := poker.CreateTempFile(t, `[]`)
+		store, err := poker.NewFileSystemPlayerStore(database)
+		if err != nil {
+			t.Fatalf("problem creating file system player store, %v", err)
+		}
 
-		game := &poker.GameSpy{BlindAlert: []byte(wantedBlindAlert)}
-		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
-		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+		server.SetLeagueHeartbeat(20 * time.Millisecond)
+		httpServer := httptest.NewServer(server)
+		return httpServer, func() {
+			httpServer.Close()
+			cleanDatabase()
+		}
+	}
 
-		defer server.Close()
-		defer ws.Close()
+	client := &http.Client{Timeout: time.Second}
 
-		writeWSMessage(t, ws, "3")
-		writeWSMessage(t, ws, winner)
+	stream := func(t *testing.T, server *httptest.Server, lastEventID string) (*bufio.Reader, func()) {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/league/stream", nil)
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
 
-		time.Sleep(tenMs)
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poker.AssertResponseStatusCode(t, response.StatusCode, http.StatusOK)
+		poker.AssertResponseBody(t, response.Header.Get("content-type"), poker.EventStreamContentType)
 
-		poker.AssertGameStartedWith(t, game, 3)
-		poker.AssertFinishCalledWith(t, game, winner)
-		within(t, tenMs, func() { poker.AssertWebsocketGotMsg(t, ws, wantedBlindAlert) })
+		events := bufio.NewReader(response.Body)
+		if retry := readEvent(t, events); retry["retry"] == "" {
+			t.Fatalf("got %v want the retry first", retry)
+		}
+		return events, func() { response.Body.Close() }
+	}
+
+	readLeague := func(t *testing.T, event map[string]string) (league poker.League) {
+		t.Helper()
+		if event["event"] != poker.LeagueEventType || event["id"] == "" {
+			t.Fatalf("got %v want a league event", event)
+		}
+		if err := json.Unmarshal([]byte(event["data"]), &league); err != nil {
+			t.Fatalf("Unable to parse the league, '%v'", err)
+		}
+		return league
+	}
+
+	t.Run("it sends the standings and again whenever a win is recorded", func(t *testing.T) {
+		server, closeServer := newServer(t)
+		defer closeServer()
+
+		events, closeStream := stream(t, server, "")
+		defer closeStream()
+
+		if league := readLeague(t, readEvent(t, events)); len(league) != 0 {
+			t.Errorf("got %v want an empty league", league)
+		}
+
+		response, err := client.Post(server.URL+"/players/Pepper", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		event := readEvent(t, events)
+		for event[":"] != "" {
+			event = readEvent(t, events)
+		}
+
+		league := readLeague(t, event)
+		if len(league) != 1 || league[0].Name != "Pepper" || league[0].Wins != 1 {
+			t.Errorf("got %v want Pepper with a win", league)
+		}
 	})
-}
 
-func newGetScoreRequest(name string) *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/players/%s", name), nil)
-	return req
-}
+	t.Run("it doesn't resend the standings a resuming client has and keeps it alive", func(t *testing.T) {
+		server, closeServer := newServer(t)
+		defer closeServer()
 
-func newPostWinRequest(name string) *http.Request {
-	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/players/%s", name), nil)
-	return req
-}
+		first, closeFirst := stream(t, server, "")
+		event := readEvent(t, first)
+		closeFirst()
 
-func newGameRequest() *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, "/game", nil)
-	return req
+		events, closeStream := stream(t, server, event["id"])
+		defer closeStream()
+
+		if heartbeat := readEvent(t, events); heartbeat[":"] != "heartbeat" {
+			t.Errorf("got %v want a heartbeat", heartbeat)
+		}
+	})
+
+	t.Run("it returns 400 for a bad query", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+		request, _ := http.NewRequest(http.MethodGet, "/league/stream?sort=luck", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("it returns 501 when the store can't say when it changes", func(t *testing.T) {
+		store := struct{ poker.PlayerStore }{&poker.StubPlayerStore{}}
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+		request, _ := http.NewRequest(http.MethodGet, "/league/stream", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotImplemented)
+	})
 }
 
-func getLeagueFromResponse(t *testing.T, body io.Reader) (league poker.League) {
+// readEvent reads the fields of the next server-sent event, with comments
+// under ":".
+func readEvent(t *testing.T, events *bufio.Reader) map[string]string {
 	t.Helper()
-	err := json.NewDecoder(body).Decode(&league)
+	event := map[string]string{}
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read an event, %v", err)
+		}
 
-	if err != nil {
-		t.Fatalf("Unable to parse response from server %q into slice of Player, '%v'", body, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		if strings.HasPrefix(line, ":") {
+			event[":"] = strings.TrimSpace(line[1:])
+			continue
+		}
+		field := strings.SplitN(line, ": ", 2)
+		if len(field) == 2 {
+			event[field[0]] = field[1]
+		}
 	}
-
-	return
 }
 
-func newLeagueRequest() *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, "/league", nil)
-	return req
+func TestLeagueFormats(t *testing.T) {
+	store := &poker.StubPlayerStore{League: poker.League{{Name: "Cleo", Wins: 3}}}
+	server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	cases := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+	}{
+		{"json without an Accept header", "/league", "", poker.JsonContentType},
+		{"json for anything", "/league", "*/*", poker.JsonContentType},
+		{"csv from Accept", "/league", "text/csv", poker.CSVContentType},
+		{"the best weighted type", "/league", "text/csv;q=0.5, text/html", poker.HTMLContentType},
+		{"text for any text", "/league", "text/*", poker.TextContentType},
+		{"the format parameter over Accept", "/league?format=text", "text/csv", poker.TextContentType},
+		{"html for a named league", "/leagues/default?format=html", "", poker.HTMLContentType},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store.Games = []poker.GameRecord{{ID: 1, StartedAt: gameTime, Players: []string{"Cleo"}}}
+
+			response := get(c.path, c.accept)
+			poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+			poker.AssertContentType(t, response, c.contentType)
+		})
+	}
+
+	t.Run("it sends csv", func(t *testing.T) {
+		response := get("/league?format=csv", "")
+		poker.AssertResponseBody(t, response.Body.String(), "position,name,wins,rating\n1,Cleo,3,1500\n")
+	})
+
+	t.Run("it returns 406 when nothing accepted can be sent", func(t *testing.T) {
+		response := get("/league", "application/xml")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotAcceptable)
+	})
+
+	t.Run("it returns 400 for unknown formats", func(t *testing.T) {
+		response := get("/league?format=xml", "")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusBadRequest)
+	})
 }
 
-func mustMakePlayerServer(t *testing.T, store poker.PlayerStore, game poker.Game) *poker.PlayerServer {
-	server, err := poker.NewPlayerServer(store, game)
-	if err != nil {
-		t.Fatal("problem creating player server", err)
+func TestLeagues(t *testing.T) {
+	winter := time.Date(2019, time.February, 1, 20, 0, 0, 0, time.UTC)
+	summer := time.Date(2019, time.July, 1, 20, 0, 0, 0, time.UTC)
+
+	store := &poker.StubPlayerStore{
+		League: poker.League{{Name: "Tiest", Wins: 10}},
+		Games: []poker.GameRecord{
+			{ID: 1, StartedAt: winter, League: "Friday cash", Players: []string{"Cleo", "Chris"}},
+			{ID: 2, StartedAt: summer, League: "Friday cash", Players: []string{"Cleo", "Chris"}},
+			{ID: 3, StartedAt: summer, League: "Friday cash", Players: []string{"Chris", "Cleo"}},
+			{ID: 4, StartedAt: winter, League: "Monthly", Season: "finals", Players: []string{"Ruth"}},
+			{ID: 5, StartedAt: winter, Players: []string{"Chris"}},
+		},
 	}
-	return server
+	server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("it lists the leagues", func(t *testing.T) {
+		response := get("/leagues")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertResponseBody(t, response.Body.String(), `["Friday cash","Monthly","default"]`+"\n")
+	})
+
+	t.Run("it adds up a league across its seasons", func(t *testing.T) {
+		response := get("/leagues/Friday%20cash")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+
+		got := getLeagueFromResponse(t, response.Body)
+		want := poker.League{{Name: "Cleo", Wins: 2}, {Name: "Chris", Wins: 1}}
+		assertLeagueWins(t, got, want)
+	})
+
+	t.Run("it lists the seasons of a league", func(t *testing.T) {
+		response := get("/leagues/Friday%20cash/seasons")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertResponseBody(t, response.Body.String(), `["2019-Q1","2019-Q3"]`+"\n")
+	})
+
+	t.Run("it returns the standings for a season", func(t *testing.T) {
+		response := get("/leagues/Friday%20cash/seasons/2019-Q3")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+
+		got := getLeagueFromResponse(t, response.Body)
+		want := poker.League{{Name: "Chris", Wins: 1, Position: 1}, {Name: "Cleo", Wins: 1, Position: 1}}
+		assertStandings(t, got, want)
+	})
+
+	t.Run("it uses chosen season names", func(t *testing.T) {
+		response := get("/leagues/Monthly/seasons/finals")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		assertLeagueWins(t, getLeagueFromResponse(t, response.Body), poker.League{{Name: "Ruth", Wins: 1}})
+	})
+
+	t.Run("it leaves legacy wins out of the default league", func(t *testing.T) {
+		response := get("/leagues/default")
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		assertLeagueWins(t, getLeagueFromResponse(t, response.Body), poker.League{{Name: "Chris", Wins: 1}})
+	})
+
+	t.Run("it returns 404 for unknown leagues and seasons", func(t *testing.T) {
+		for _, path := range []string{
+			"/leagues/Sunday",
+			"/leagues/Monthly/seasons/2019-Q1",
+			"/leagues/Monthly/players",
+			"/leagues/Monthly/seasons/finals/extra",
+		} {
+			response := get(path)
+			if response.Code != http.StatusNotFound {
+				t.Errorf("got status %d for %s want %d", response.Code, path, http.StatusNotFound)
+			}
+		}
+	})
 }
 
-func mustDialWS(t *testing.T, url string) *websocket.Conn {
-	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+func TestAudit(t *testing.T) {
+	t.Run("it audits wins as the user that sent them", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
 
-	if err != nil {
-		t.Fatalf("could not open a ws connection on %s %v", url, err)
+		request := newPostWinRequest("Chirs")
+		request.Header.Set(poker.UserHeader, "ruth")
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		entry, err := audit.Entry(1)
+		poker.AssertNoError(t, err)
+		if entry.Who != "ruth" || entry.Via != poker.ViaHTTP {
+			t.Errorf("got the win audited as %s through %s", entry.Who, entry.Via)
+		}
+	})
+
+	t.Run("it serves the audit log", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Cleo"))
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/audit", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertContentType(t, response, poker.JsonContentType)
+
+		var got []poker.AuditEntry
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("Unable to parse audit log from %q, %v", response.Body, err)
+		}
+		if len(got) != 1 || got[0].Action != poker.RecordAction || got[0].Game.Winner() != "Cleo" {
+			t.Errorf("got audit log %v", got)
+		}
+	})
+
+	t.Run("it reverses an entry", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		poker.AssertNoError(t, store.RecordWin("Chirs"))
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodPost, "/audit/1/reverse", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertStoreLeague(t, store, poker.League{})
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusConflict)
+	})
+
+	t.Run("it returns 404 for unknown entries", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodPost, "/audit/42/reverse", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("it returns 404 when the store isn't audited", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/audit", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func TestImport(t *testing.T) {
+	newImportRequest := func(contentType, body string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "/import", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		return request
 	}
 
-	return ws
+	t.Run("it imports csv and reports what it did", func(t *testing.T) {
+		store, audit := newAuditedStore(t)
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		request := newImportRequest("text/csv; charset=utf-8", "startedAt,winner\n2019-03-01,Cleo\n")
+		request.Header.Set(poker.UserHeader, "ruth")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+
+		var report poker.ImportReport
+		if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+			t.Fatalf("Unable to parse import report from %q, %v", response.Body, err)
+		}
+		assertImported(t, report, 1, 0)
+
+		entry, err := audit.Entry(1)
+		poker.AssertNoError(t, err)
+		if entry.Who != "ruth" {
+			t.Errorf("got the import audited as %s want ruth", entry.Who)
+		}
+	})
+
+	t.Run("it returns 422 with the row errors", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newImportRequest(poker.JsonContentType, `[{"players":["Cleo"]}]`))
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusUnprocessableEntity)
+		poker.AssertResponseBody(t, response.Body.String(), `{"imported":[],"skipped":0,"errors":[{"row":1,"error":"startedAt is needed"}]}`+"\n")
+		if len(store.GameCalls) != 0 {
+			t.Errorf("expected nothing to be recorded but got %v", store.GameCalls)
+		}
+	})
+
+	t.Run("it returns 415 for formats it can't import", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newImportRequest("application/xml", "<games/>"))
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusUnsupportedMediaType)
+	})
+
+	t.Run("it only accepts posts", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/import", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusMethodNotAllowed)
+	})
+}
+
+func TestPlayerStats(t *testing.T) {
+	store := &poker.StubPlayerStore{Games: []poker.GameRecord{
+		{ID: 1, StartedAt: gameTime, Players: []string{"Cleo", "Chris"}},
+	}}
+	server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+	t.Run("it returns a player's stats", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/players/Chris/stats", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertContentType(t, response, poker.JsonContentType)
+
+		var got poker.PlayerStats
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("Unable to parse stats from %q, %v", response.Body, err)
+		}
+		if got.GamesPlayed != 1 || got.Wins != 0 || len(got.HeadToHead) != 1 || got.HeadToHead[0].Behind != 1 {
+			t.Errorf("got stats %+v", got)
+		}
+	})
+
+	t.Run("it returns 404 for players without games", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/players/Ruth/stats", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("it doesn't record a win when stats are posted to", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		request, _ := http.NewRequest(http.MethodPost, "/players/Chris/stats", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusMethodNotAllowed)
+		if len(store.WinCalls) != 0 {
+			t.Errorf("expected no wins to be recorded but got %v", store.WinCalls)
+		}
+	})
+}
+
+func TestRenamePlayer(t *testing.T) {
+	newRenameRequest := func(from, to string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "/players/"+from+"/rename?to="+to, nil)
+		return request
+	}
+
+	t.Run("it renames a player", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newRenameRequest("Chirs", "Chris"))
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusAccepted)
+		if store.Renamed["Chirs"] != "Chris" {
+			t.Errorf("expected Chirs to be renamed to Chris but got %v", store.Renamed)
+		}
+	})
+
+	t.Run("it returns 404 for unknown players and 409 when they can't be merged", func(t *testing.T) {
+		store, _ := newAuditedStore(t)
+		_, err := store.RecordGame(poker.GameRecord{StartedAt: gameTime, Players: []string{"Cleo", "Chris"}})
+		poker.AssertNoError(t, err)
+		server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newRenameRequest("Ruth", "Chris"))
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newRenameRequest("Cleo", "Chris"))
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusConflict)
+	})
+}
+
+func TestGames(t *testing.T) {
+	games := []poker.GameRecord{
+		{ID: 1, StartedAt: gameTime, NumberOfPlayers: 3, Players: []string{"Chris", "Cleo"}},
+		{ID: 2, StartedAt: gameTime, NumberOfPlayers: 2, Players: []string{"Cleo", "Chris"}},
+	}
+	store := &poker.StubPlayerStore{Games: games}
+	server := mustMakePlayerServer(t, store, &poker.GameSpy{})
+
+	t.Run("GET /games returns every recorded game as JSON", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/games", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		var got []poker.GameRecord
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("Unable to parse response from server %q into games, '%v'", response.Body, err)
+		}
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertContentType(t, response, poker.JsonContentType)
+		if !reflect.DeepEqual(got, games) {
+			t.Errorf("got %+v want %+v", got, games)
+		}
+	})
+
+	t.Run("GET /games/{id} returns one game", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/games/2", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		var got poker.GameRecord
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("Unable to parse response from server %q into a game, '%v'", response.Body, err)
+		}
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+		poker.AssertGameRecord(t, got, games[1])
+	})
+
+	for _, path := range []string{"/games/3", "/games/abc"} {
+		t.Run("GET "+path+" returns 404", func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodGet, path, nil)
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, request)
+
+			poker.AssertResponseStatusCode(t, response.Code, http.StatusNotFound)
+		})
+	}
+}
+
+func TestGame(t *testing.T) {
+	tenMs := 10 * time.Millisecond
+	t.Run("GET /game returns 200", func(t *testing.T) {
+		server := mustMakePlayerServer(t, &poker.StubPlayerStore{}, &poker.GameSpy{})
+
+		request := newGameRequest()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("when we get a message over a websocket it is a winner of a game", func(t *testing.T) {
+		store := &poker.StubPlayerStore{}
+		game := &poker.GameSpy{}
+		winner := "Ruth"
+		server := httptest.NewServer(mustMakePlayerServer(t, store, game))
+		defer server.Close()
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+		ws := mustDialWS(t, wsURL)
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: winner})
+
+		time.Sleep(tenMs)
+
+		poker.AssertFinishCalledWith(t, game, winner)
+	})
+
+	t.Run("start a game with 3 players and declare Ruth the winner", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		winner := "Ruth"
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: winner})
+
+		time.Sleep(tenMs)
+		poker.AssertGameStartedWith(t, game, 3)
+		poker.AssertFinishCalledWith(t, game, winner)
+	})
+
+	t.Run("start a game with 3 players, send some blind alerts down WS and declare Ruth the winner", func(t *testing.T) {
+		wantedBlindAlert := "Blind is 100"
+		winner := "Ruth"
+
+		game := &poker.GameSpy{BlindAlert: []byte(wantedBlindAlert)}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+
+		defer server.Close()
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: winner})
+
+		time.Sleep(tenMs)
+
+		poker.AssertGameStartedWith(t, game, 3)
+		poker.AssertFinishCalledWith(t, game, winner)
+		within(t, tenMs, func() { poker.AssertWebsocketGotMsg(t, ws, textFrame(wantedBlindAlert)) })
+	})
+
+	t.Run("blind alerts are sent down WS with their level and time until the next level", func(t *testing.T) {
+		immediateAlerter := poker.BlindAlerterFunc(func(ctx context.Context, duration time.Duration, blind poker.Blind, to io.Writer) {
+			if duration == 0 {
+				poker.WriteBlind(to, blind)
+			}
+		})
+		game := poker.NewTexasHoldem(immediateAlerter, dummyPlayerStore)
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+
+		defer server.Close()
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, ws, `{"v":1,"type":"blind","level":1,"amount":100,"nextLevelIn":480}`+"\n")
+		})
+	})
+
+	t.Run("the finishing order can be sent down WS", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth", RunnersUp: []string{"Chris", "Cleo"}})
+
+		poker.AssertFinishCalledWith(t, game, "Ruth")
+		poker.AssertFinishRunnersUp(t, game, "Chris", "Cleo")
+	})
+
+	t.Run("clock commands sent down WS control the game's clock", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.ClockType, Command: "pause"})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.ClockType, Command: "resume"})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth"})
+
+		poker.AssertClockCalls(t, game, "pause", "resume")
+		poker.AssertFinishCalledWith(t, game, "Ruth")
+	})
+
+	t.Run("the clock state is sent down WS as a structured message", func(t *testing.T) {
+		game := &poker.GameSpy{ClockState: poker.ClockState{
+			Blind:     poker.Blind{Level: 2, Amount: 200, NextLevelIn: 10 * time.Minute},
+			Paused:    true,
+			Remaining: 4 * time.Minute,
+		}}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.ClockType, Command: "time"})
+
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, ws, `{"v":1,"type":"clock","level":2,"paused":true,"remaining":240}`+"\n")
+		})
+	})
+
+	t.Run("a request that isn't valid gets an error frame and the game goes on", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "3")
+		within(t, 100*time.Millisecond, func() { assertErrorFrame(t, ws, poker.BadMessageCode) })
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.ClockType, Command: "pause"})
+		within(t, 100*time.Millisecond, func() { assertErrorFrame(t, ws, poker.NotStartedCode) })
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 4})
+		within(t, 100*time.Millisecond, func() { assertErrorFrame(t, ws, poker.AlreadyStartedCode) })
+
+		poker.AssertGameStartedWith(t, game, 3)
+	})
+
+	t.Run("a ping gets a pong", func(t *testing.T) {
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, &poker.GameSpy{}))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.PingType})
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, ws, `{"v":1,"type":"pong"}`+"\n") })
+	})
+
+	t.Run("a game that can't be recorded gets an error frame", func(t *testing.T) {
+		game := &poker.GameSpy{FinishError: errors.New("disk full")}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth"})
+
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, ws, `{"v":1,"type":"error","code":"game","message":"`+poker.RecordWinErrMsg+`, disk full"}`+"\n")
+		})
+	})
+
+	t.Run("the WS connection is closed properly once the game is finished", func(t *testing.T) {
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, &poker.GameSpy{}))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth"})
+
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, ws, `{"v":1,"type":"result","winner":"Ruth"}`+"\n")
+			_, _, err := ws.ReadMessage()
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("expected a normal close but got %v", err)
+			}
+		})
+	})
+
+	t.Run("a request that can't be upgraded to a WS gets 400", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		server := mustMakePlayerServer(t, dummyPlayerStore, game)
+
+		request, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		poker.AssertResponseStatusCode(t, response.Code, http.StatusBadRequest)
+		poker.AssertGameNotStarted(t, game)
+	})
+
+	t.Run("closing the WS connection cancels the game's context", func(t *testing.T) {
+		game := &poker.GameSpy{}
+		server := httptest.NewServer(mustMakePlayerServer(t, dummyPlayerStore, game))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		poker.AssertGameStartedWith(t, game, 3)
+
+		ws.Close()
+
+		poker.AssertStartContextCancelled(t, game)
+	})
+}
+
+func TestGameTables(t *testing.T) {
+	// newServer returns the server with a function listing the games its
+	// tables have made so far.
+	newServer := func(t *testing.T) (*httptest.Server, func() []*poker.GameSpy) {
+		var games []*poker.GameSpy
+		var mu sync.Mutex
+		registry := poker.NewGameRegistry(func() poker.Game {
+			mu.Lock()
+			defer mu.Unlock()
+			game := &poker.GameSpy{BlindAlert: []byte("Blind is 100")}
+			games = append(games, game)
+			return game
+		})
+		server, err := poker.NewPlayerServerWithGames(dummyPlayerStore, registry)
+		if err != nil {
+			t.Fatal("problem creating player server", err)
+		}
+		return httptest.NewServer(server), func() []*poker.GameSpy {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]*poker.GameSpy(nil), games...)
+		}
+	}
+
+	tableURL := func(server *httptest.Server, id string) string {
+		return "ws" + strings.TrimPrefix(server.URL, "http") + "/games/" + id + "/ws"
+	}
+
+	getActive := func(t *testing.T, server *httptest.Server) (active []poker.ActiveTable) {
+		t.Helper()
+		response, err := http.Get(server.URL + "/games/active")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		poker.AssertResponseStatusCode(t, response.StatusCode, http.StatusOK)
+		if err := json.NewDecoder(response.Body).Decode(&active); err != nil {
+			t.Fatalf("Unable to parse active tables, '%v'", err)
+		}
+		return active
+	}
+
+	t.Run("clients at /games/{id}/ws play the same game", func(t *testing.T) {
+		server, games := newServer(t)
+		defer server.Close()
+
+		dealer := mustDialWS(t, tableURL(server, "cleos"))
+		defer dealer.Close()
+		watcher := mustDialWS(t, tableURL(server, "cleos"))
+		defer watcher.Close()
+
+		within(t, 100*time.Millisecond, func() {
+			for len(getActive(t, server)) == 0 || getActive(t, server)[0].Clients != 2 {
+				time.Sleep(time.Millisecond)
+			}
+		})
+
+		writeWSRequest(t, dealer, poker.WSRequest{Type: poker.StartType, Players: 3})
+
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, dealer, textFrame("Blind is 100")) })
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, watcher, textFrame("Blind is 100")) })
+
+		writeWSRequest(t, watcher, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth"})
+		poker.AssertFinishCalledWith(t, games()[0], "Ruth")
+		if len(games()) != 1 {
+			t.Errorf("got %d games want 1", len(games()))
+		}
+	})
+
+	t.Run("each table has a game of its own", func(t *testing.T) {
+		server, games := newServer(t)
+		defer server.Close()
+
+		cleos := mustDialWS(t, tableURL(server, "cleos"))
+		defer cleos.Close()
+		chriss := mustDialWS(t, tableURL(server, "chriss"))
+		defer chriss.Close()
+
+		writeWSRequest(t, cleos, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, chriss, poker.WSRequest{Type: poker.StartType, Players: 5})
+
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, cleos, textFrame("Blind is 100")) })
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, chriss, textFrame("Blind is 100")) })
+
+		started := map[int]bool{}
+		for _, game := range games() {
+			started[game.StartedWith()] = true
+		}
+		if len(games()) != 2 || !started[3] || !started[5] {
+			t.Errorf("expected two games started with 3 and 5 players, got %v", started)
+		}
+	})
+
+	t.Run("GET /games/active lists the tables and forgets them when everyone leaves", func(t *testing.T) {
+		server, games := newServer(t)
+		defer server.Close()
+
+		ws := mustDialWS(t, tableURL(server, "cleos"))
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, ws, textFrame("Blind is 100")) })
+
+		active := getActive(t, server)
+		if len(active) != 1 || active[0].ID != "cleos" || !active[0].Started || active[0].NumberOfPlayers != 3 {
+			t.Fatalf("got %+v", active)
+		}
+
+		ws.Close()
+
+		poker.AssertStartContextCancelled(t, games()[0])
+		if active := getActive(t, server); len(active) != 0 {
+			t.Errorf("expected no active tables, got %+v", active)
+		}
+	})
+
+	t.Run("GET /games/{id} shows the table being played", func(t *testing.T) {
+		server, _ := newServer(t)
+		defer server.Close()
+
+		ws := mustDialWS(t, tableURL(server, "cleos"))
+		defer ws.Close()
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, ws, textFrame("Blind is 100")) })
+
+		response, err := http.Get(server.URL + "/games/cleos")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		var got poker.ActiveTable
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("Unable to parse the table, '%v'", err)
+		}
+		poker.AssertResponseStatusCode(t, response.StatusCode, http.StatusOK)
+		if got.ID != "cleos" || got.Clients != 1 {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("spectators at /games/{id}/watch follow the game without changing it", func(t *testing.T) {
+		server, games := newServer(t)
+		defer server.Close()
+
+		player := mustDialWS(t, tableURL(server, "cleos"))
+		defer player.Close()
+		writeWSRequest(t, player, poker.WSRequest{Type: poker.StartType, Players: 3})
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, player, textFrame("Blind is 100")) })
+
+		spectator := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/games/cleos/watch")
+		defer spectator.Close()
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, spectator, `{"v":1,"type":"clock","level":0,"paused":false,"remaining":0}`+"\n")
+		})
+
+		writeWSRequest(t, player, poker.WSRequest{Type: poker.OutType, Player: "Chris"})
+		out := `{"v":1,"type":"out","player":"Chris","place":3}` + "\n"
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, player, out) })
+		within(t, 100*time.Millisecond, func() { poker.AssertWebsocketGotMsg(t, spectator, out) })
+
+		writeWSRequest(t, spectator, poker.WSRequest{Type: poker.FinishType, Winner: "Cleo"})
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, spectator, `{"v":1,"type":"error","code":"spectator","message":"`+poker.SpectatorErrMsg+`"}`+"\n")
+		})
+
+		writeWSRequest(t, player, poker.WSRequest{Type: poker.FinishType, Winner: "Ruth"})
+		within(t, 100*time.Millisecond, func() {
+			poker.AssertWebsocketGotMsg(t, spectator, `{"v":1,"type":"result","winner":"Ruth","runnersUp":["Chris"]}`+"\n")
+		})
+		poker.AssertFinishRunnersUp(t, games()[0], "Chris")
+	})
+
+	t.Run("there is nothing to watch at a table nobody is at", func(t *testing.T) {
+		server, _ := newServer(t)
+		defer server.Close()
+
+		response, err := http.Get(server.URL + "/games/cleos/watch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		poker.AssertResponseStatusCode(t, response.StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("numeric table IDs are rejected", func(t *testing.T) {
+		server, _ := newServer(t)
+		defer server.Close()
+
+		response, err := http.Get(server.URL + "/games/42/ws")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		poker.AssertResponseStatusCode(t, response.StatusCode, http.StatusBadRequest)
+	})
+}
+
+func TestGameSessions(t *testing.T) {
+	newServer := func(t *testing.T) *httptest.Server {
+		registry := poker.NewGameRegistry(func() poker.Game {
+			return poker.NewTexasHoldem(&poker.SpyBlindAlerter{}, &poker.StubPlayerStore{})
+		})
+		registry.SetSessionStore(&poker.StubSessionStore{}, time.Minute)
+		server, err := poker.NewPlayerServerWithGames(dummyPlayerStore, registry)
+		if err != nil {
+			t.Fatal("problem creating player server", err)
+		}
+		return httptest.NewServer(server)
+	}
+
+	wsURL := func(server *httptest.Server, path string) string {
+		return "ws" + strings.TrimPrefix(server.URL, "http") + path
+	}
+
+	readFrame := func(t *testing.T, conn *websocket.Conn) (frame struct {
+		Type  string `json:"type"`
+		Table string `json:"table"`
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("could not read a frame, %v", err)
+		}
+		return frame
+	}
+
+	t.Run("a player that drops out rejoins the game with its session", func(t *testing.T) {
+		server := newServer(t)
+		defer server.Close()
+
+		ws := mustDialWS(t, wsURL(server, "/games/cleos/ws"))
+		session := readFrame(t, ws)
+		if session.Type != poker.SessionType || session.Table != "cleos" || session.Token == "" {
+			t.Fatalf("got %+v want a session frame", session)
+		}
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.StartType, Players: 3})
+		writeWSRequest(t, ws, poker.WSRequest{Type: poker.PingType})
+		if pong := readFrame(t, ws); pong.Type != poker.PongType {
+			t.Fatalf("got %+v want a pong", pong)
+		}
+		ws.Close()
+
+		again := mustDialWS(t, wsURL(server, "/ws?session="+session.Token))
+		defer again.Close()
+
+		if rejoined := readFrame(t, again); rejoined != session {
+			t.Errorf("got %+v want %+v", rejoined, session)
+		}
+		if clock := readFrame(t, again); clock.Type != poker.ClockType {
+			t.Errorf("got %+v want the clock of the game in play", clock)
+		}
+	})
+
+	t.Run("an unknown session gets an error frame", func(t *testing.T) {
+		server := newServer(t)
+		defer server.Close()
+
+		ws := mustDialWS(t, wsURL(server, "/ws?session=not-a-session"))
+		defer ws.Close()
+
+		assertErrorFrame(t, ws, poker.BadSessionCode)
+	})
+}
+
+func newGetScoreRequest(name string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/players/%s", name), nil)
+	return req
+}
+
+func newPostWinRequest(name string) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/players/%s", name), nil)
+	return req
+}
+
+func newGameRequest() *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/game", nil)
+	return req
+}
+
+func assertStandings(t *testing.T, got, want poker.League) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Wins != want[i].Wins || got[i].Position != want[i].Position {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+}
+
+func assertLeagueWins(t *testing.T, got, want poker.League) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Wins != want[i].Wins {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+}
+
+func getLeagueFromResponse(t *testing.T, body io.Reader) (league poker.League) {
+	t.Helper()
+	err := json.NewDecoder(body).Decode(&league)
+
+	if err != nil {
+		t.Fatalf("Unable to parse response from server %q into slice of Player, '%v'", body, err)
+	}
+
+	return
+}
+
+func newLeagueRequest() *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/league", nil)
+	return req
+}
+
+// mustMakePlayerServer makes a server where every table plays game, so tests
+// can spy on the game whichever table it is played at.
+func mustMakePlayerServer(t *testing.T, store poker.PlayerStore, game poker.Game) *poker.PlayerServer {
+	server, err := poker.NewPlayerServer(store, func() poker.Game { return game })
+	if err != nil {
+		t.Fatal("problem creating player server", err)
+	}
+	return server
+}
+
+func mustDialWS(t *testing.T, url string) *websocket.Conn {
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+
+	if err != nil {
+		t.Fatalf("could not open a ws connection on %s %v", url, err)
+	}
+
+	return ws
+}
+
+func writeWSRequest(t *testing.T, conn *websocket.Conn, request poker.WSRequest) {
+	t.Helper()
+	request.Version = poker.ProtocolVersion
+	message, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("could not encode %+v, %v", request, err)
+	}
+	writeWSMessage(t, conn, string(message))
+}
+
+func assertErrorFrame(t *testing.T, conn *websocket.Conn, code string) {
+	t.Helper()
+	var frame struct {
+		Type string `json:"type"`
+		Code string `json:"code"`
+	}
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Errorf("could not read a frame, %v", err)
+		return
+	}
+	if frame.Type != poker.ErrorType || frame.Code != code {
+		t.Errorf("got %+v want an error frame with code %q", frame, code)
+	}
+}
+
+func textFrame(text string) string {
+	return `{"v":1,"type":"message","text":"` + text + `"}` + "\n"
 }
 
 func writeWSMessage(t *testing.T, conn *websocket.Conn, message string) {
@@ -0,0 +1,180 @@
+package poker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TableSession is a table with a game in play, saved so that the game can
+// go on after the server restarts and its players can get back to it.
+type TableSession struct {
+	ID        string       `json:"id"`
+	Token     string       `json:"token"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Out       []string     `json:"out,omitempty"`
+	Game      GameSnapshot `json:"game"`
+}
+
+// SessionStore keeps the sessions of the tables being played at.
+type SessionStore interface {
+	SaveSession(session TableSession) error
+	DeleteSession(id string) error
+	Sessions() ([]TableSession, error)
+}
+
+// NewSessionToken makes a token for players to get back to their table
+// with. It's random so that one table's token can't be mistaken for
+// another's.
+func NewSessionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("problem making session token, %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SessionWriter is implemented by destinations that want the session of
+// their table as a structured value rather than as a line of text.
+type SessionWriter interface {
+	WriteSession(table, token string) error
+}
+
+func WriteSession(to io.Writer, table, token string) error {
+	if w, ok := to.(SessionWriter); ok {
+		return w.WriteSession(table, token)
+	}
+
+	_, err := fmt.Fprintf(to, "Rejoin table %s with session %s\n", table, token)
+	return err
+}
+
+// FileSessionStore keeps sessions in a JSON file, rewritten on a tape with
+// every change.
+type FileSessionStore struct {
+	mu       sync.Mutex
+	tape     *Tape
+	sessions map[string]TableSession
+}
+
+func NewFileSessionStore(file *os.File) (*FileSessionStore, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("problem reading sessions from %s, %v", file.Name(), err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("problem getting file info from file %s, %v", file.Name(), err)
+	}
+
+	var saved []TableSession
+	if info.Size() > 0 {
+		if err := json.NewDecoder(file).Decode(&saved); err != nil {
+			return nil, fmt.Errorf("problem loading sessions from file %s, %v", file.Name(), err)
+		}
+	}
+
+	sessions := make(map[string]TableSession, len(saved))
+	for _, session := range saved {
+		sessions[session.ID] = session
+	}
+
+	return &FileSessionStore{tape: NewTape(file), sessions: sessions}, nil
+}
+
+func SessionStoreFromFile(path string) (*FileSessionStore, func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem opening %s %v", path, err)
+	}
+
+	store, err := NewFileSessionStore(file)
+
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return store, store.Close, nil
+}
+
+func (f *FileSessionStore) SaveSession(session TableSession) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, had := f.sessions[session.ID]
+	f.sessions[session.ID] = session
+
+	if err := f.write(); err != nil {
+		if had {
+			f.sessions[session.ID] = previous
+		} else {
+			delete(f.sessions, session.ID)
+		}
+		return fmt.Errorf("problem saving session of table %s, %v", session.ID, err)
+	}
+	return nil
+}
+
+func (f *FileSessionStore) DeleteSession(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	session, ok := f.sessions[id]
+	if !ok {
+		return nil
+	}
+	delete(f.sessions, id)
+
+	if err := f.write(); err != nil {
+		f.sessions[id] = session
+		return fmt.Errorf("problem deleting session of table %s, %v", id, err)
+	}
+	return nil
+}
+
+// Sessions are the saved sessions, the oldest first.
+func (f *FileSessionStore) Sessions() ([]TableSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.sorted(), nil
+}
+
+func (f *FileSessionStore) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tape.Close()
+}
+
+func (f *FileSessionStore) write() error {
+	data, err := json.Marshal(f.sorted())
+	if err != nil {
+		return err
+	}
+	_, err = f.tape.Write(data)
+	return err
+}
+
+func (f *FileSessionStore) sorted() []TableSession {
+	sessions := make([]TableSession, 0, len(f.sessions))
+	for _, session := range f.sessions {
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions
+}
@@ -0,0 +1,107 @@
+package poker_test
+
+import (
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	cleos := poker.TableSession{
+		ID:        "cleos",
+		Token:     "abc",
+		CreatedAt: gameTime,
+		Out:       []string{"Chris"},
+		Game: poker.GameSnapshot{
+			StartedAt:       gameTime,
+			NumberOfPlayers: 3,
+			Clock:           poker.ClockSnapshot{Level: 1, LevelStarted: gameTime.Add(10 * time.Minute)},
+		},
+	}
+	ruths := poker.TableSession{ID: "ruths", Token: "def", CreatedAt: gameTime.Add(time.Minute)}
+
+	t.Run("it starts with no sessions", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "")
+		defer clean()
+
+		store, err := poker.NewFileSessionStore(file)
+		poker.AssertNoError(t, err)
+
+		got, err := store.Sessions()
+		poker.AssertNoError(t, err)
+		if len(got) != 0 {
+			t.Errorf("got %+v want no sessions", got)
+		}
+	})
+
+	t.Run("saved sessions are there after a restart", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "")
+		defer clean()
+
+		store, err := poker.NewFileSessionStore(file)
+		poker.AssertNoError(t, err)
+		poker.AssertNoError(t, store.SaveSession(ruths))
+		poker.AssertNoError(t, store.SaveSession(cleos))
+
+		reopened, clean := reopen(t, file.Name())
+		defer clean()
+
+		got, err := reopened.Sessions()
+		poker.AssertNoError(t, err)
+		assertSessions(t, got, []poker.TableSession{cleos, ruths})
+	})
+
+	t.Run("saving a table again replaces its session and deleting forgets it", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "")
+		defer clean()
+
+		store, err := poker.NewFileSessionStore(file)
+		poker.AssertNoError(t, err)
+		poker.AssertNoError(t, store.SaveSession(cleos))
+		poker.AssertNoError(t, store.SaveSession(ruths))
+
+		paused := cleos
+		paused.Game.Clock.Paused = true
+		poker.AssertNoError(t, store.SaveSession(paused))
+		poker.AssertNoError(t, store.DeleteSession("ruths"))
+		poker.AssertNoError(t, store.DeleteSession("nobodys"))
+
+		reopened, clean := reopen(t, file.Name())
+		defer clean()
+
+		got, err := reopened.Sessions()
+		poker.AssertNoError(t, err)
+		assertSessions(t, got, []poker.TableSession{paused})
+	})
+
+	t.Run("it won't load a file that isn't sessions", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "not json")
+		defer clean()
+
+		if _, err := poker.NewFileSessionStore(file); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func reopen(t *testing.T, path string) (*poker.FileSessionStore, func()) {
+	t.Helper()
+	store, close, err := poker.SessionStoreFromFile(path)
+	poker.AssertNoError(t, err)
+	return store, close
+}
+
+func assertSessions(t *testing.T, got, want []poker.TableSession) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Token != want[i].Token || !got[i].CreatedAt.Equal(want[i].CreatedAt) ||
+			!reflect.DeepEqual(got[i].Out, want[i].Out) || got[i].Game.Clock.Paused != want[i].Game.Clock.Paused ||
+			got[i].Game.NumberOfPlayers != want[i].Game.NumberOfPlayers || !got[i].Game.Clock.LevelStarted.Equal(want[i].Game.Clock.LevelStarted) {
+			t.Errorf("got %+v want %+v", got[i], want[i])
+		}
+	}
+}
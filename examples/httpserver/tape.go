@@ -1,19 +1,112 @@
 package poker
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
+const backupSuffix = ".bak"
+
+// Tape replaces the contents of its file on every write. The new contents are
+// written and synced to a temporary file that is then renamed over the
+// original, so the file always holds either the old or the new contents. The
+// old contents are kept next to it with a .bak suffix. The Tape owns the file
+// handed to NewTape: it is closed once it has been replaced, so read the file
+// by its name rather than through that handle.
 type Tape struct {
+	path string
 	file *os.File
 }
 
-func NewTape(reader *os.File) *Tape {
-	return &Tape{reader}
+func NewTape(file *os.File) *Tape {
+	return &Tape{path: file.Name(), file: file}
 }
 
 func (t *Tape) Write(p []byte) (n int, err error) {
-	t.file.Truncate(0)
-	t.file.Seek(0, 0)
-	return t.file.Write(p)
+	path := t.path
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return 0, fmt.Errorf("problem creating temporary file for %s, %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err = tmp.Write(p)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("problem writing temporary file for %s, %v", path, err)
+	}
+
+	if err := backup(path); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("problem backing up %s, %v", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("problem replacing %s, %v", path, err)
+	}
+	syncDir(filepath.Dir(path))
+
+	// The temporary file is now the file at path, so it's kept rather than
+	// reopened and there's nothing left to fail.
+	t.file.Close()
+	t.file = tmp
+
+	return n, nil
+}
+
+func (t *Tape) Close() error {
+	return t.file.Close()
+}
+
+// backup keeps the current contents of path in path.bak, linking to them
+// where the file system allows it and copying them where it doesn't.
+func backup(path string) error {
+	bak := path + backupSuffix
+
+	if err := os.Remove(bak); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Link(path, bak); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(bak)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, so failing to is not an error.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
 }
@@ -2,25 +2,53 @@ package poker_test
 
 import (
 	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
-	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestTape_Write(t *testing.T) {
-	file, clean := poker.CreateTempFile(t, "12345")
-	defer clean()
+	t.Run("replaces the file contents", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "12345")
+		defer clean()
 
-	tape := poker.NewTape(file)
+		tape := poker.NewTape(file)
 
-	tape.Write([]byte("abc"))
+		_, err := tape.Write([]byte("abc"))
+		poker.AssertNoError(t, err)
 
-	file.Seek(0, 0)
-	newFileContents, _ := ioutil.ReadAll(file)
+		assertFileContents(t, file.Name(), "abc")
+	})
 
-	got := string(newFileContents)
-	want := "abc"
+	t.Run("keeps the previous contents as a backup", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "12345")
+		defer clean()
 
-	if got != want {
-		t.Errorf("got %q want %q", got, want)
-	}
+		tape := poker.NewTape(file)
+
+		tape.Write([]byte("abc"))
+		tape.Write([]byte("def"))
+
+		assertFileContents(t, file.Name(), "def")
+		assertFileContents(t, file.Name()+".bak", "abc")
+	})
+
+	t.Run("returns an error and leaves the file alone when it can't write", func(t *testing.T) {
+		file, clean := poker.CreateTempFile(t, "12345")
+		defer clean()
+
+		// A directory in the way of the backup stops the write part way.
+		bak := file.Name() + ".bak"
+		poker.AssertNoError(t, os.MkdirAll(filepath.Join(bak, "in-the-way"), 0777))
+		defer os.RemoveAll(bak)
+
+		tape := poker.NewTape(file)
+		_, err := tape.Write([]byte("abc"))
+
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+
+		assertFileContents(t, file.Name(), "12345")
+	})
 }
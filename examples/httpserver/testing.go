@@ -2,6 +2,7 @@ package poker
 
 import (
 	"bytes"
+	"context"
 	"github.com/gorilla/websocket"
 	"io"
 	"io/ioutil"
@@ -9,54 +10,307 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 type StubPlayerStore struct {
-	Scores   map[string]int
-	WinCalls []string
-	League   League
+	changeNotifier
+	Scores    map[string]int
+	WinCalls  []string
+	League    League
+	Games     []GameRecord
+	GameCalls []GameRecord
+	Deleted   []int
+	Renamed   map[string]string
+
+	ScoreError  error
+	WinError    error
+	LeagueError error
 }
 
-func (s *StubPlayerStore) GetPlayerScore(name string) (score int) {
-	score = s.Scores[name]
-	return score
+func (s *StubPlayerStore) GetPlayerScore(name string) (int, error) {
+	if s.ScoreError != nil {
+		return 0, s.ScoreError
+	}
+
+	score, ok := s.Scores[name]
+	if !ok {
+		return 0, ErrPlayerNotFound
+	}
+	return score, nil
 }
 
-func (s *StubPlayerStore) RecordWin(name string) {
+func (s *StubPlayerStore) RecordWin(name string) error {
+	if s.WinError != nil {
+		return s.WinError
+	}
+
 	s.WinCalls = append(s.WinCalls, name)
+	s.changed()
+	return nil
+}
+
+func (s *StubPlayerStore) GetLeague() (League, error) {
+	return s.League, s.LeagueError
+}
+
+func (s *StubPlayerStore) RecordGame(game GameRecord) (GameRecord, error) {
+	if s.WinError != nil {
+		return GameRecord{}, s.WinError
+	}
+
+	s.GameCalls = append(s.GameCalls, game)
+	s.WinCalls = append(s.WinCalls, game.Winner())
+
+	game.ID = len(s.GameCalls)
+	s.changed()
+	return game, nil
+}
+
+func (s *StubPlayerStore) RecordGames(games []GameRecord) ([]GameRecord, error) {
+	if s.WinError != nil {
+		return nil, s.WinError
+	}
+
+	fresh := newGames(s.Games, games)
+	recorded := make([]GameRecord, len(fresh))
+	for i, game := range fresh {
+		recorded[i], _ = s.RecordGame(game)
+	}
+	return recorded, nil
+}
+
+func (s *StubPlayerStore) GetGames() ([]GameRecord, error) {
+	return s.Games, nil
+}
+
+func (s *StubPlayerStore) GetGame(id int) (GameRecord, error) {
+	for _, game := range s.Games {
+		if game.ID == id {
+			return game, nil
+		}
+	}
+	return GameRecord{}, ErrGameNotFound
+}
+
+func (s *StubPlayerStore) DeleteGame(id int) error {
+	for i, game := range s.Games {
+		if game.ID == id {
+			s.Games = append(s.Games[:i:i], s.Games[i+1:]...)
+			s.Deleted = append(s.Deleted, id)
+			s.changed()
+			return nil
+		}
+	}
+	return ErrGameNotFound
+}
+
+func (s *StubPlayerStore) RenamePlayer(from, to string) error {
+	if s.WinError != nil {
+		return s.WinError
+	}
+
+	if s.Renamed == nil {
+		s.Renamed = map[string]string{}
+	}
+	s.Renamed[from] = to
+	s.changed()
+	return nil
+}
+
+type StubSessionStore struct {
+	mu      sync.Mutex
+	Saved   map[string]TableSession
+	Saves   int
+	Deleted []string
+}
+
+func (s *StubSessionStore) SaveSession(session TableSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Saved == nil {
+		s.Saved = map[string]TableSession{}
+	}
+	s.Saved[session.ID] = session
+	s.Saves++
+	return nil
 }
-func (s *StubPlayerStore) GetLeague() League {
-	return s.League
+
+func (s *StubSessionStore) DeleteSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Saved, id)
+	s.Deleted = append(s.Deleted, id)
+	return nil
+}
+
+func (s *StubSessionStore) Sessions() ([]TableSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []TableSession
+	for _, session := range s.Saved {
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// Session is the session saved for the table with id.
+func (s *StubSessionStore) Session(id string) (TableSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.Saved[id]
+	return session, ok
 }
 
 type SpyBlindAlerter struct {
-	Alerts []ScheduledAlert
+	Alerts   []ScheduledAlert
+	Blinds   []Blind
+	Contexts []context.Context
 }
 
-func (s *SpyBlindAlerter) ScheduleAlertAt(duration time.Duration, amount int, to io.Writer) {
-	s.Alerts = append(s.Alerts, ScheduledAlert{duration, amount})
+func (s *SpyBlindAlerter) ScheduleAlertAt(ctx context.Context, duration time.Duration, blind Blind, to io.Writer) {
+	s.Alerts = append(s.Alerts, ScheduledAlert{duration, blind.Amount})
+	s.Blinds = append(s.Blinds, blind)
+	s.Contexts = append(s.Contexts, ctx)
+}
+
+func (s *SpyBlindAlerter) Cancelled() bool {
+	if len(s.Contexts) == 0 {
+		return false
+	}
+
+	for _, ctx := range s.Contexts {
+		if ctx.Err() == nil {
+			return false
+		}
+	}
+	return true
 }
 
+// GameSpy records what is asked of a game. Tables play it from their own
+// goroutines, so its fields are set before it is played and read through
+// the Assert helpers.
 type GameSpy struct {
+	mu sync.Mutex
+
 	StartCalled     bool
 	StartCalledWith int
+	StartContext    context.Context
 	BlindAlert      []byte
 
 	FinishedCalled   bool
 	FinishCalledWith string
+	FinishRunnersUp  []string
+	FinishError      error
+	FinishRecord     GameRecord
+
+	ClockCalls []string
+	ClockState ClockState
+	ClockError error
 }
 
-func (g *GameSpy) Start(numberOfPlayers int, out io.Writer) {
+func (g *GameSpy) Start(ctx context.Context, numberOfPlayers int, out io.Writer) {
+	g.mu.Lock()
 	g.StartCalled = true
 	g.StartCalledWith = numberOfPlayers
-	out.Write(g.BlindAlert)
+	g.StartContext = ctx
+	alert := g.BlindAlert
+	g.mu.Unlock()
 
+	if alert != nil {
+		out.Write(alert)
+	}
 }
 
-func (g *GameSpy) Finish(winner string) {
+// StartedWith is the number of players the game was started with.
+func (g *GameSpy) StartedWith() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.StartCalledWith
+}
+
+func (g *GameSpy) Finish(winner string, runnersUp ...string) (GameRecord, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.FinishRunnersUp = runnersUp
 	g.FinishCalledWith = winner
+	if g.FinishError != nil {
+		return GameRecord{}, g.FinishError
+	}
+
+	recorded := g.FinishRecord
+	recorded.Players = append([]string{winner}, runnersUp...)
+	return recorded, nil
+}
+
+func (g *GameSpy) Pause() error {
+	return g.clockCall("pause")
+}
+
+func (g *GameSpy) Resume() error {
+	return g.clockCall("resume")
+}
+
+func (g *GameSpy) NextLevel() error {
+	return g.clockCall("next")
+}
+
+func (g *GameSpy) PreviousLevel() error {
+	return g.clockCall("previous")
+}
+
+func (g *GameSpy) Status() (ClockState, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ClockCalls = append(g.ClockCalls, "time")
+	return g.ClockState, g.ClockError
+}
+
+func (g *GameSpy) clockCall(command string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ClockCalls = append(g.ClockCalls, command)
+	return g.ClockError
+}
+
+// locked reads the spy's fields with f while no game method is changing them.
+func (g *GameSpy) locked(f func() bool) func() bool {
+	return func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return f()
+	}
+}
+
+type StubClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewStubClock() *StubClock {
+	return &StubClock{now: time.Date(2019, time.March, 1, 20, 0, 0, 0, time.UTC)}
+}
+
+func (c *StubClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *StubClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
 }
 
 func AssertResponseBody(t *testing.T, got, want string) {
@@ -80,6 +334,28 @@ func AssertScoreEquals(t *testing.T, got, want int) {
 	}
 }
 
+func AssertStoreScore(t *testing.T, store PlayerStore, name string, want int) {
+	t.Helper()
+
+	got, err := store.GetPlayerScore(name)
+	if err != nil {
+		t.Fatalf("didn't expect an error getting the score for %s but got one, %v", name, err)
+	}
+
+	AssertScoreEquals(t, got, want)
+}
+
+func AssertStoreLeague(t *testing.T, store PlayerStore, want League) {
+	t.Helper()
+
+	got, err := store.GetLeague()
+	if err != nil {
+		t.Fatalf("didn't expect an error getting the league but got one, %v", err)
+	}
+
+	AssertLeague(t, got, want)
+}
+
 func AssertLeague(t *testing.T, got, want League) {
 	t.Helper()
 	if !reflect.DeepEqual(got, want) {
@@ -87,6 +363,13 @@ func AssertLeague(t *testing.T, got, want League) {
 	}
 }
 
+func AssertGameRecord(t *testing.T, got, want GameRecord) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
+
 func AssertContentType(t *testing.T, response *httptest.ResponseRecorder, want string) {
 	t.Helper()
 	if response.Result().Header.Get("content-type") != want {
@@ -101,6 +384,13 @@ func AssertNoError(t *testing.T, err error) {
 	}
 }
 
+func AssertError(t *testing.T, got, want error) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got error %v want %v", got, want)
+	}
+}
+
 func AssertPlayerWin(t *testing.T, store *StubPlayerStore, winner string) {
 	t.Helper()
 
@@ -121,6 +411,14 @@ func AssertScheduleAlert(t *testing.T, got ScheduledAlert, want ScheduledAlert)
 	}
 }
 
+func AssertBlind(t *testing.T, got Blind, want Blind) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
+
 func CreateTempFile(t *testing.T, initialData string) (*os.File, func()) {
 	t.Helper()
 
@@ -135,6 +433,7 @@ func CreateTempFile(t *testing.T, initialData string) (*os.File, func()) {
 	removeFile := func() {
 		tmpfile.Close()
 		os.Remove(tmpfile.Name())
+		os.Remove(tmpfile.Name() + backupSuffix)
 	}
 
 	return tmpfile, removeFile
@@ -160,30 +459,100 @@ func AssertMessagesSentToUser(t *testing.T, stdout *bytes.Buffer, messages ...st
 func AssertGameStartedWith(t *testing.T, game *GameSpy, want int) {
 	t.Helper()
 
-	passed := retryUntil(500*time.Millisecond, func() bool {
+	passed := retryUntil(500*time.Millisecond, game.locked(func() bool {
 		return game.StartCalledWith == want
-	})
+	}))
+
+	if !passed {
+		t.Errorf("expected start called with %d but got %d", want, game.StartedWith())
+	}
+}
+
+func AssertFinishRunnersUp(t *testing.T, game *GameSpy, want ...string) {
+	t.Helper()
+
+	var got []string
+	passed := retryUntil(500*time.Millisecond, game.locked(func() bool {
+		got = game.FinishRunnersUp
+		return reflect.DeepEqual(got, want)
+	}))
 
 	if !passed {
-		t.Errorf("expected start called with %d but got %d", want, game.StartCalledWith)
+		t.Errorf("expected finish called with runners up %q but got %q", want, got)
 	}
 }
 
 func AssertFinishCalledWith(t *testing.T, game *GameSpy, want string) {
 	t.Helper()
 
-	passed := retryUntil(500*time.Millisecond, func() bool {
-		return game.FinishCalledWith == want
-	})
+	var got string
+	passed := retryUntil(500*time.Millisecond, game.locked(func() bool {
+		got = game.FinishCalledWith
+		return got == want
+	}))
+
+	if !passed {
+		t.Errorf("expected finish called with %q but got %q", want, got)
+	}
+}
+
+func AssertAlertsCancelled(t *testing.T, alerter *SpyBlindAlerter) {
+	t.Helper()
+
+	passed := retryUntil(500*time.Millisecond, alerter.Cancelled)
 
 	if !passed {
-		t.Errorf("expected finish called with %q but got %q", want, game.FinishCalledWith)
+		t.Errorf("expected all %d scheduled alerts to be cancelled", len(alerter.Contexts))
+	}
+}
+
+func AssertAlertsNotCancelled(t *testing.T, alerter *SpyBlindAlerter) {
+	t.Helper()
+
+	for _, ctx := range alerter.Contexts {
+		if ctx.Err() != nil {
+			t.Fatalf("expected scheduled alerts to still be pending but got %v", ctx.Err())
+		}
+	}
+}
+
+func AssertStartContextCancelled(t *testing.T, game *GameSpy) {
+	t.Helper()
+
+	passed := retryUntil(500*time.Millisecond, game.locked(func() bool {
+		return game.StartContext != nil && game.StartContext.Err() != nil
+	}))
+
+	if !passed {
+		t.Errorf("expected the context passed to start to be cancelled")
+	}
+}
+
+func AssertClockCalls(t *testing.T, game *GameSpy, want ...string) {
+	t.Helper()
+
+	var got []string
+	passed := retryUntil(500*time.Millisecond, game.locked(func() bool {
+		got = append([]string(nil), game.ClockCalls...)
+		return reflect.DeepEqual(got, want)
+	}))
+
+	if !passed {
+		t.Errorf("got clock calls %v want %v", got, want)
+	}
+}
+
+func AssertClockState(t *testing.T, got, want ClockState) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got %+v want %+v", got, want)
 	}
 }
 
 func AssertGameNotStarted(t *testing.T, game *GameSpy) {
 	t.Helper()
-	if game.StartCalled {
+	if game.locked(func() bool { return game.StartCalled })() {
 		t.Errorf("game should not have started")
 	}
 }
@@ -0,0 +1,353 @@
+package poker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGameNotStarted  = errors.New("no game is in progress")
+	ErrClockPaused     = errors.New("the clock is already paused")
+	ErrClockRunning    = errors.New("the clock is already running")
+	ErrNoNextLevel     = errors.New("already on the last level")
+	ErrNoPreviousLevel = errors.New("already on the first level")
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type ClockFunc func() time.Time
+
+func (c ClockFunc) Now() time.Time {
+	return c()
+}
+
+var RealClock = ClockFunc(time.Now)
+
+type TournamentControls interface {
+	Pause() error
+	Resume() error
+	NextLevel() error
+	PreviousLevel() error
+	Status() (ClockState, error)
+}
+
+type ClockState struct {
+	Blind     Blind
+	Paused    bool
+	Remaining time.Duration
+}
+
+// ClockWriter is implemented by destinations that want clock changes as
+// structured values rather than as a line of text.
+type ClockWriter interface {
+	WriteClock(state ClockState) error
+}
+
+func WriteClock(to io.Writer, state ClockState) error {
+	if w, ok := to.(ClockWriter); ok {
+		return w.WriteClock(state)
+	}
+
+	status := fmt.Sprintf("Level %d", state.Blind.Level)
+	if state.Paused {
+		status = fmt.Sprintf("Clock paused on level %d", state.Blind.Level)
+	}
+
+	var err error
+	if state.Blind.NextLevelIn == 0 {
+		_, err = fmt.Fprintf(to, "%s, the final level\n", status)
+	} else {
+		_, err = fmt.Fprintf(to, "%s with %v until the next level\n", status, state.Remaining)
+	}
+	return err
+}
+
+// runClockCommand applies a clock command typed by a player, reporting the
+// outcome to out. It returns false when input is not a clock command.
+func runClockCommand(controls TournamentControls, input string, out io.Writer) bool {
+	ok, err := clockCommand(controls, input, out)
+	if err != nil {
+		fmt.Fprintln(out, err)
+	}
+	return ok
+}
+
+// clockCommand applies a clock command, writing the clock to out when asked
+// for the time. It returns false when input is not a clock command.
+func clockCommand(controls TournamentControls, input string, out io.Writer) (bool, error) {
+	var err error
+
+	switch input {
+	case "pause":
+		err = controls.Pause()
+	case "resume":
+		err = controls.Resume()
+	case "next":
+		err = controls.NextLevel()
+	case "previous":
+		err = controls.PreviousLevel()
+	case "time":
+		var state ClockState
+		state, err = controls.Status()
+		if err == nil {
+			err = WriteClock(out, state)
+		}
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// TournamentClock runs a game's blind levels. Every change to the clock
+// cancels the alerts it scheduled and schedules the remaining levels again
+// from the current position.
+type TournamentClock struct {
+	mu      sync.Mutex
+	alerter BlindAlerter
+	clock   Clock
+	blinds  []Blind
+	out     io.Writer
+
+	parent       context.Context
+	alerts       context.Context
+	cancelAlerts context.CancelFunc
+
+	level        int
+	levelStarted time.Time
+	paused       bool
+	elapsed      time.Duration
+}
+
+func NewTournamentClock(ctx context.Context, alerter BlindAlerter, clock Clock, blinds []Blind, out io.Writer) *TournamentClock {
+	c := &TournamentClock{
+		alerter:      alerter,
+		clock:        clock,
+		blinds:       blinds,
+		out:          out,
+		parent:       ctx,
+		levelStarted: clock.Now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(blinds) > 0 {
+		c.alerter.ScheduleAlertAt(c.newAlertsContext(), 0, blinds[0], out)
+		c.scheduleNextLevels(blinds[0].NextLevelIn)
+	}
+
+	return c
+}
+
+// ClockSnapshot is where a tournament clock has got to, enough to pick it up
+// again with ResumeTournamentClock.
+type ClockSnapshot struct {
+	Level        int           `json:"level"`
+	LevelStarted time.Time     `json:"levelStarted"`
+	Paused       bool          `json:"paused,omitempty"`
+	Elapsed      time.Duration `json:"elapsed,omitempty"`
+}
+
+// ResumeTournamentClock picks a clock up from snapshot. The levels that should
+// have gone by since are skipped and the alerts for the rest are scheduled for
+// the time remaining, as if the clock had never stopped.
+func ResumeTournamentClock(ctx context.Context, alerter BlindAlerter, clock Clock, blinds []Blind, out io.Writer, snapshot ClockSnapshot) *TournamentClock {
+	c := &TournamentClock{
+		alerter:      alerter,
+		clock:        clock,
+		blinds:       blinds,
+		out:          out,
+		parent:       ctx,
+		level:        snapshot.Level,
+		levelStarted: snapshot.LevelStarted,
+		paused:       snapshot.Paused,
+		elapsed:      snapshot.Elapsed,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(blinds) == 0 {
+		return c
+	}
+	if c.level < 0 || c.level >= len(blinds) {
+		c.level = len(blinds) - 1
+	}
+
+	c.catchUp()
+	if !c.paused {
+		c.alerter.ScheduleAlertAt(c.newAlertsContext(), 0, blinds[c.level], out)
+		c.scheduleNextLevels(c.state().Remaining)
+	}
+
+	return c
+}
+
+// Snapshot is where the clock has got to.
+func (c *TournamentClock) Snapshot() ClockSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catchUp()
+	return ClockSnapshot{
+		Level:        c.level,
+		LevelStarted: c.levelStarted.UTC(),
+		Paused:       c.paused,
+		Elapsed:      c.elapsed,
+	}
+}
+
+func (c *TournamentClock) Pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		return ErrClockPaused
+	}
+
+	c.catchUp()
+	c.elapsed = c.clock.Now().Sub(c.levelStarted)
+	c.paused = true
+	c.stopAlerts()
+
+	return WriteClock(c.out, c.state())
+}
+
+func (c *TournamentClock) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		return ErrClockRunning
+	}
+
+	c.levelStarted = c.clock.Now().Add(-c.elapsed)
+	c.paused = false
+	c.newAlertsContext()
+	c.scheduleNextLevels(c.blind().NextLevelIn - c.elapsed)
+
+	return WriteClock(c.out, c.state())
+}
+
+func (c *TournamentClock) NextLevel() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catchUp()
+	if c.level >= len(c.blinds)-1 {
+		return ErrNoNextLevel
+	}
+
+	return c.moveTo(c.level + 1)
+}
+
+func (c *TournamentClock) PreviousLevel() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catchUp()
+	if c.level == 0 {
+		return ErrNoPreviousLevel
+	}
+
+	return c.moveTo(c.level - 1)
+}
+
+func (c *TournamentClock) Status() (ClockState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catchUp()
+	return c.state(), nil
+}
+
+func (c *TournamentClock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopAlerts()
+}
+
+func (c *TournamentClock) moveTo(level int) error {
+	c.level = level
+	c.levelStarted = c.clock.Now()
+	c.elapsed = 0
+
+	if !c.paused {
+		c.newAlertsContext()
+		c.scheduleNextLevels(c.blinds[c.level].NextLevelIn)
+	}
+
+	if err := WriteBlind(c.out, c.blinds[c.level]); err != nil {
+		return err
+	}
+	return WriteClock(c.out, c.state())
+}
+
+// catchUp moves the clock on to whichever level should be running now, as
+// the alerts for the levels in between fire without telling the clock.
+func (c *TournamentClock) catchUp() {
+	if c.paused {
+		return
+	}
+
+	elapsed := c.clock.Now().Sub(c.levelStarted)
+	for c.level < len(c.blinds)-1 && elapsed >= c.blinds[c.level].NextLevelIn {
+		elapsed -= c.blinds[c.level].NextLevelIn
+		c.levelStarted = c.levelStarted.Add(c.blinds[c.level].NextLevelIn)
+		c.level++
+	}
+}
+
+func (c *TournamentClock) state() ClockState {
+	blind := c.blind()
+
+	elapsed := c.elapsed
+	if !c.paused {
+		elapsed = c.clock.Now().Sub(c.levelStarted)
+	}
+
+	remaining := blind.NextLevelIn - elapsed
+	if blind.NextLevelIn == 0 || remaining < 0 {
+		remaining = 0
+	}
+
+	return ClockState{Blind: blind, Paused: c.paused, Remaining: remaining}
+}
+
+// blind is the blind of the level running, none when there are no levels.
+func (c *TournamentClock) blind() Blind {
+	if c.level >= len(c.blinds) {
+		return Blind{}
+	}
+	return c.blinds[c.level]
+}
+
+func (c *TournamentClock) scheduleNextLevels(at time.Duration) {
+	if c.level+1 >= len(c.blinds) {
+		return
+	}
+	for _, blind := range c.blinds[c.level+1:] {
+		c.alerter.ScheduleAlertAt(c.alerts, at, blind, c.out)
+		at = at + blind.NextLevelIn
+	}
+}
+
+func (c *TournamentClock) newAlertsContext() context.Context {
+	c.stopAlerts()
+	c.alerts, c.cancelAlerts = context.WithCancel(c.parent)
+	return c.alerts
+}
+
+func (c *TournamentClock) stopAlerts() {
+	if c.cancelAlerts != nil {
+		c.cancelAlerts()
+	}
+}
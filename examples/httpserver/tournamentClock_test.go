@@ -0,0 +1,244 @@
+package poker_test
+
+import (
+	"bytes"
+	"context"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"testing"
+	"time"
+)
+
+var clockBlinds = []poker.Blind{
+	{Level: 1, Amount: 100, NextLevelIn: 10 * time.Minute},
+	{Level: 2, Amount: 200, NextLevelIn: 10 * time.Minute},
+	{Level: 3, Amount: 300, NextLevelIn: 0},
+}
+
+func TestTournamentClock(t *testing.T) {
+	t.Run("schedules every level when it starts", func(t *testing.T) {
+		alerter := &poker.SpyBlindAlerter{}
+
+		poker.NewTournamentClock(context.Background(), alerter, poker.NewStubClock(), clockBlinds, dummyStdOut)
+
+		poker.CheckSchedulingCases(t, alerter.Alerts, []poker.ScheduledAlert{
+			{At: 0, Amount: 100},
+			{At: 10 * time.Minute, Amount: 200},
+			{At: 20 * time.Minute, Amount: 300},
+		})
+	})
+
+	t.Run("reports the level and time remaining as time passes", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+
+		clock.Advance(12 * time.Minute)
+
+		got, err := tournament.Status()
+		poker.AssertNoError(t, err)
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[1], Remaining: 8 * time.Minute})
+	})
+
+	t.Run("pausing cancels the alerts and stops the time running down", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		alerter := &poker.SpyBlindAlerter{}
+		out := &bytes.Buffer{}
+		tournament := poker.NewTournamentClock(context.Background(), alerter, clock, clockBlinds, out)
+
+		clock.Advance(4 * time.Minute)
+		poker.AssertNoError(t, tournament.Pause())
+		clock.Advance(30 * time.Minute)
+
+		poker.AssertAlertsCancelled(t, alerter)
+		poker.AssertResponseBody(t, out.String(), "Clock paused on level 1 with 6m0s until the next level\n")
+
+		got, _ := tournament.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[0], Paused: true, Remaining: 6 * time.Minute})
+	})
+
+	t.Run("resuming schedules the rest of the levels from the time remaining", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		alerter := &poker.SpyBlindAlerter{}
+		tournament := poker.NewTournamentClock(context.Background(), alerter, clock, clockBlinds, dummyStdOut)
+
+		clock.Advance(4 * time.Minute)
+		tournament.Pause()
+		clock.Advance(30 * time.Minute)
+		scheduledBeforeResume := len(alerter.Alerts)
+		poker.AssertNoError(t, tournament.Resume())
+
+		poker.CheckSchedulingCases(t, alerter.Alerts[scheduledBeforeResume:], []poker.ScheduledAlert{
+			{At: 6 * time.Minute, Amount: 200},
+			{At: 16 * time.Minute, Amount: 300},
+		})
+		poker.AssertAlertsNotCancelled(t, &poker.SpyBlindAlerter{Contexts: alerter.Contexts[scheduledBeforeResume:]})
+	})
+
+	t.Run("can't pause twice or resume a running clock", func(t *testing.T) {
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, poker.NewStubClock(), clockBlinds, dummyStdOut)
+
+		poker.AssertError(t, tournament.Resume(), poker.ErrClockRunning)
+		tournament.Pause()
+		poker.AssertError(t, tournament.Pause(), poker.ErrClockPaused)
+	})
+
+	t.Run("moving to the next level announces it and reschedules the rest", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		alerter := &poker.SpyBlindAlerter{}
+		out := &bytes.Buffer{}
+		tournament := poker.NewTournamentClock(context.Background(), alerter, clock, clockBlinds, out)
+
+		clock.Advance(3 * time.Minute)
+		scheduledBeforeSkip := len(alerter.Alerts)
+		poker.AssertNoError(t, tournament.NextLevel())
+
+		poker.AssertResponseBody(t, out.String(), "Blind is now 200\nLevel 2 with 10m0s until the next level\n")
+		poker.AssertAlertsCancelled(t, &poker.SpyBlindAlerter{Contexts: alerter.Contexts[:scheduledBeforeSkip]})
+		poker.CheckSchedulingCases(t, alerter.Alerts[scheduledBeforeSkip:], []poker.ScheduledAlert{
+			{At: 10 * time.Minute, Amount: 300},
+		})
+	})
+
+	t.Run("moving back a level restarts that level", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+
+		clock.Advance(15 * time.Minute)
+		poker.AssertNoError(t, tournament.PreviousLevel())
+
+		got, _ := tournament.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[0], Remaining: 10 * time.Minute})
+	})
+
+	t.Run("moving levels while paused keeps the clock paused", func(t *testing.T) {
+		alerter := &poker.SpyBlindAlerter{}
+		tournament := poker.NewTournamentClock(context.Background(), alerter, poker.NewStubClock(), clockBlinds, dummyStdOut)
+
+		tournament.Pause()
+		scheduledBeforeSkip := len(alerter.Alerts)
+		tournament.NextLevel()
+
+		got, _ := tournament.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[1], Paused: true, Remaining: 10 * time.Minute})
+		if len(alerter.Alerts) != scheduledBeforeSkip {
+			t.Errorf("did not expect alerts to be scheduled while paused, got %v", alerter.Alerts[scheduledBeforeSkip:])
+		}
+	})
+
+	t.Run("can't move past the first or last level", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+
+		poker.AssertError(t, tournament.PreviousLevel(), poker.ErrNoPreviousLevel)
+
+		clock.Advance(25 * time.Minute)
+		poker.AssertError(t, tournament.NextLevel(), poker.ErrNoNextLevel)
+	})
+}
+
+func TestTournamentClock_Snapshot(t *testing.T) {
+	t.Run("copes without any levels", func(t *testing.T) {
+		alerter := &poker.SpyBlindAlerter{}
+		tournament := poker.NewTournamentClock(context.Background(), alerter, poker.NewStubClock(), nil, dummyStdOut)
+
+		got, err := tournament.Status()
+		poker.AssertNoError(t, err)
+		poker.AssertClockState(t, got, poker.ClockState{})
+
+		poker.AssertNoError(t, tournament.Pause())
+		poker.AssertNoError(t, tournament.Resume())
+		poker.AssertError(t, tournament.NextLevel(), poker.ErrNoNextLevel)
+		poker.AssertError(t, tournament.PreviousLevel(), poker.ErrNoPreviousLevel)
+
+		if len(alerter.Alerts) != 0 {
+			t.Errorf("expected no alerts but got %v", alerter.Alerts)
+		}
+	})
+
+	t.Run("a resumed clock carries on with the time remaining", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+
+		clock.Advance(4 * time.Minute)
+		snapshot := tournament.Snapshot()
+		tournament.Stop()
+
+		clock.Advance(2 * time.Minute)
+		alerter := &poker.SpyBlindAlerter{}
+		resumed := poker.ResumeTournamentClock(context.Background(), alerter, clock, clockBlinds, dummyStdOut, snapshot)
+
+		poker.CheckSchedulingCases(t, alerter.Alerts, []poker.ScheduledAlert{
+			{At: 0, Amount: 100},
+			{At: 4 * time.Minute, Amount: 200},
+			{At: 14 * time.Minute, Amount: 300},
+		})
+
+		got, _ := resumed.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[0], Remaining: 4 * time.Minute})
+	})
+
+	t.Run("levels that went by while it was stopped are skipped", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+		snapshot := tournament.Snapshot()
+		tournament.Stop()
+
+		clock.Advance(13 * time.Minute)
+		alerter := &poker.SpyBlindAlerter{}
+		resumed := poker.ResumeTournamentClock(context.Background(), alerter, clock, clockBlinds, dummyStdOut, snapshot)
+
+		poker.CheckSchedulingCases(t, alerter.Alerts, []poker.ScheduledAlert{
+			{At: 0, Amount: 200},
+			{At: 7 * time.Minute, Amount: 300},
+		})
+
+		got, _ := resumed.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[1], Remaining: 7 * time.Minute})
+	})
+
+	t.Run("a paused clock stays paused", func(t *testing.T) {
+		clock := poker.NewStubClock()
+		tournament := poker.NewTournamentClock(context.Background(), &poker.SpyBlindAlerter{}, clock, clockBlinds, dummyStdOut)
+
+		clock.Advance(4 * time.Minute)
+		tournament.Pause()
+		snapshot := tournament.Snapshot()
+		tournament.Stop()
+
+		clock.Advance(time.Hour)
+		alerter := &poker.SpyBlindAlerter{}
+		resumed := poker.ResumeTournamentClock(context.Background(), alerter, clock, clockBlinds, dummyStdOut, snapshot)
+
+		if len(alerter.Alerts) != 0 {
+			t.Errorf("didn't expect alerts while paused, got %v", alerter.Alerts)
+		}
+
+		got, _ := resumed.Status()
+		poker.AssertClockState(t, got, poker.ClockState{Blind: clockBlinds[0], Paused: true, Remaining: 6 * time.Minute})
+
+		poker.AssertNoError(t, resumed.Resume())
+		poker.CheckSchedulingCases(t, alerter.Alerts, []poker.ScheduledAlert{
+			{At: 6 * time.Minute, Amount: 200},
+			{At: 16 * time.Minute, Amount: 300},
+		})
+	})
+}
+
+func TestWriteClock(t *testing.T) {
+	cases := []struct {
+		State poker.ClockState
+		Want  string
+	}{
+		{poker.ClockState{Blind: clockBlinds[0], Remaining: time.Minute}, "Level 1 with 1m0s until the next level\n"},
+		{poker.ClockState{Blind: clockBlinds[1], Paused: true, Remaining: time.Minute}, "Clock paused on level 2 with 1m0s until the next level\n"},
+		{poker.ClockState{Blind: clockBlinds[2]}, "Level 3, the final level\n"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.Want, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			poker.AssertNoError(t, poker.WriteClock(out, test.State))
+			poker.AssertResponseBody(t, out.String(), test.Want)
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package poker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the version of the websocket protocol, sent as "v" in
+// every frame. Clients must send it too so that a change to the protocol
+// can't be mistaken for a bad message.
+const ProtocolVersion = 1
+
+// The types of frames clients send.
+const (
+	StartType  = "start"
+	FinishType = "finish"
+	ClockType  = "clock"
+	OutType    = "out"
+	PingType   = "ping"
+)
+
+// The types of frames sent to clients, besides the clock and eliminations
+// which share their type with the requests for them.
+const (
+	BlindType   = "blind"
+	ResultType  = "result"
+	MessageType = "message"
+	ErrorType   = "error"
+	PongType    = "pong"
+	SessionType = "session"
+)
+
+// The codes of the errors sent in error frames.
+const (
+	BadMessageCode         = "bad-message"
+	UnsupportedVersionCode = "unsupported-version"
+	UnknownTypeCode        = "unknown-type"
+	InvalidCode            = "invalid"
+	NotStartedCode         = "not-started"
+	AlreadyStartedCode     = "already-started"
+	SpectatorCode          = "spectator"
+	BadSessionCode         = "bad-session"
+	GameErrorCode          = "game"
+)
+
+// wsWriteWait is how long a write to a websocket can take before the client
+// is given up on, so a stalled client only ever holds up its own sends.
+const wsWriteWait = 10 * time.Second
+
+const wsMaxMessageSize = 4096
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// WSRequest is a frame sent by a client. Which of its fields are needed
+// depends on its type.
+type WSRequest struct {
+	Version   int      `json:"v"`
+	Type      string   `json:"type"`
+	Players   int      `json:"players,omitempty"`
+	Winner    string   `json:"winner,omitempty"`
+	RunnersUp []string `json:"runnersUp,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Player    string   `json:"player,omitempty"`
+}
+
+// WSError is an error sent back to a client in an error frame.
+type WSError struct {
+	Code    string
+	Message string
+}
+
+func (e WSError) Error() string {
+	return e.Message
+}
+
+func wsErrorf(code, format string, a ...interface{}) WSError {
+	return WSError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// ParseWSRequest decodes and validates a frame sent by a client.
+func ParseWSRequest(data []byte) (WSRequest, error) {
+	var request WSRequest
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return request, wsErrorf(BadMessageCode, "messages must be JSON objects, %v", err)
+	}
+
+	return request, request.Validate()
+}
+
+// Validate checks a request has what its type needs.
+func (r WSRequest) Validate() error {
+	if r.Version != ProtocolVersion {
+		return wsErrorf(UnsupportedVersionCode, "unsupported protocol version %d, want %d", r.Version, ProtocolVersion)
+	}
+
+	switch r.Type {
+	case StartType:
+		if r.Players < 2 {
+			return wsErrorf(InvalidCode, "a game needs at least 2 players, got %d", r.Players)
+		}
+	case FinishType:
+		return validateFinishingOrder(r.Winner, r.RunnersUp)
+	case ClockType:
+		switch r.Command {
+		case "pause", "resume", "next", "previous", "time":
+		default:
+			return wsErrorf(InvalidCode, "unknown clock command %q, want pause, resume, next, previous or time", r.Command)
+		}
+	case OutType:
+		if strings.TrimSpace(r.Player) == "" {
+			return wsErrorf(InvalidCode, "say which player is out")
+		}
+	case PingType:
+	default:
+		return wsErrorf(UnknownTypeCode, "unknown message type %q", r.Type)
+	}
+	return nil
+}
+
+func validateFinishingOrder(winner string, runnersUp []string) error {
+	if strings.TrimSpace(winner) == "" {
+		return wsErrorf(InvalidCode, "a finished game needs a winner")
+	}
+
+	seen := map[string]bool{winner: true}
+	for _, name := range runnersUp {
+		if strings.TrimSpace(name) == "" {
+			return wsErrorf(InvalidCode, "runners up need names")
+		}
+		if seen[name] {
+			return wsErrorf(InvalidCode, "%s finished more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+type blindFrame struct {
+	Version     int    `json:"v"`
+	Type        string `json:"type"`
+	Level       int    `json:"level"`
+	Amount      int    `json:"amount"`
+	Ante        int    `json:"ante,omitempty"`
+	Break       bool   `json:"break,omitempty"`
+	NextLevelIn int    `json:"nextLevelIn"`
+}
+
+type clockFrame struct {
+	Version   int    `json:"v"`
+	Type      string `json:"type"`
+	Level     int    `json:"level"`
+	Paused    bool   `json:"paused"`
+	Remaining int    `json:"remaining"`
+}
+
+type outFrame struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Player  string `json:"player"`
+	Place   int    `json:"place,omitempty"`
+}
+
+type resultFrame struct {
+	Version   int      `json:"v"`
+	Type      string   `json:"type"`
+	Winner    string   `json:"winner"`
+	RunnersUp []string `json:"runnersUp,omitempty"`
+}
+
+type messageFrame struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+}
+
+type errorFrame struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type sessionFrame struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Table   string `json:"table"`
+	Token   string `json:"token"`
+}
+
+type pongFrame struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+}
+
+// playerServerWS speaks the protocol over a websocket. Anything written to
+// it as text is sent as a message frame.
+type playerServerWS struct {
+	*websocket.Conn
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+// newPlayerServerWS upgrades the request to a websocket. When it can't, the
+// client has already been sent an HTTP error.
+func newPlayerServerWS(w http.ResponseWriter, r *http.Request) (*playerServerWS, error) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		log.Printf("problem upgrading connection to WebSockets %v\n", err)
+		return nil, err
+	}
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	return &playerServerWS{Conn: conn}, nil
+}
+
+// ReadRequest waits for the next request from the client. Requests that
+// aren't valid come back as a WSError, which the connection survives; any
+// other error means the connection is gone.
+func (w *playerServerWS) ReadRequest() (WSRequest, error) {
+	kind, msg, err := w.ReadMessage()
+	if err != nil {
+		if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			log.Printf("error reading from websocket %v\n", err)
+		}
+		return WSRequest{}, err
+	}
+
+	if kind != websocket.TextMessage {
+		return WSRequest{}, wsErrorf(BadMessageCode, "messages must be text frames")
+	}
+
+	return ParseWSRequest(msg)
+}
+
+func (w *playerServerWS) Write(p []byte) (n int, err error) {
+	if err := w.writeJSON(messageFrame{ProtocolVersion, MessageType, string(p)}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *playerServerWS) WriteBlind(blind Blind) error {
+	return w.writeJSON(blindFrame{
+		Version:     ProtocolVersion,
+		Type:        BlindType,
+		Level:       blind.Level,
+		Amount:      blind.Amount,
+		Ante:        blind.Ante,
+		Break:       blind.Break,
+		NextLevelIn: int(blind.NextLevelIn / time.Second),
+	})
+}
+
+func (w *playerServerWS) WriteClock(state ClockState) error {
+	return w.writeJSON(clockFrame{
+		Version:   ProtocolVersion,
+		Type:      ClockType,
+		Level:     state.Blind.Level,
+		Paused:    state.Paused,
+		Remaining: int(state.Remaining / time.Second),
+	})
+}
+
+func (w *playerServerWS) WriteElimination(out Elimination) error {
+	return w.writeJSON(outFrame{ProtocolVersion, OutType, out.Player, out.Place})
+}
+
+func (w *playerServerWS) WriteResult(result Result) error {
+	return w.writeJSON(resultFrame{ProtocolVersion, ResultType, result.Winner, result.RunnersUp})
+}
+
+// WriteError sends err in an error frame, with the code of a WSError and the
+// game's code for anything else.
+func (w *playerServerWS) WriteError(err error) error {
+	var wsErr WSError
+	if !errors.As(err, &wsErr) {
+		wsErr = WSError{Code: GameErrorCode, Message: err.Error()}
+	}
+	return w.writeJSON(errorFrame{ProtocolVersion, ErrorType, wsErr.Code, wsErr.Message})
+}
+
+func (w *playerServerWS) WriteSession(table, token string) error {
+	return w.writeJSON(sessionFrame{ProtocolVersion, SessionType, table, token})
+}
+
+func (w *playerServerWS) WritePong() error {
+	return w.writeJSON(pongFrame{ProtocolVersion, PongType})
+}
+
+// Close says goodbye to the client with a close frame and closes the
+// connection. It can be called more than once.
+func (w *playerServerWS) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(wsWriteWait),
+		)
+		err = w.Conn.Close()
+	})
+	return err
+}
+
+func (w *playerServerWS) writeJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return w.WriteJSON(v)
+}
@@ -0,0 +1,64 @@
+package poker_test
+
+import (
+	"errors"
+	poker "github.com/pedrorochaorg/learn-go-with-tests/examples/httpserver"
+	"reflect"
+	"testing"
+)
+
+func TestParseWSRequest(t *testing.T) {
+	valid := []struct {
+		message string
+		want    poker.WSRequest
+	}{
+		{`{"v":1,"type":"start","players":3}`, poker.WSRequest{Version: 1, Type: poker.StartType, Players: 3}},
+		{`{"v":1,"type":"finish","winner":"Ruth","runnersUp":["Chris","Cleo"]}`, poker.WSRequest{Version: 1, Type: poker.FinishType, Winner: "Ruth", RunnersUp: []string{"Chris", "Cleo"}}},
+		{`{"v":1,"type":"clock","command":"pause"}`, poker.WSRequest{Version: 1, Type: poker.ClockType, Command: "pause"}},
+		{`{"v":1,"type":"out","player":"Chris"}`, poker.WSRequest{Version: 1, Type: poker.OutType, Player: "Chris"}},
+		{`{"v":1,"type":"ping"}`, poker.WSRequest{Version: 1, Type: poker.PingType}},
+	}
+
+	for _, c := range valid {
+		t.Run(c.message, func(t *testing.T) {
+			got, err := poker.ParseWSRequest([]byte(c.message))
+
+			poker.AssertNoError(t, err)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v want %+v", got, c.want)
+			}
+		})
+	}
+
+	invalid := []struct {
+		message string
+		code    string
+	}{
+		{`3`, poker.BadMessageCode},
+		{`Ruth wins`, poker.BadMessageCode},
+		{`{"v":1,"type":"start","players":3,"table":"cleos"}`, poker.BadMessageCode},
+		{`{"type":"start","players":3}`, poker.UnsupportedVersionCode},
+		{`{"v":2,"type":"start","players":3}`, poker.UnsupportedVersionCode},
+		{`{"v":1,"type":"deal"}`, poker.UnknownTypeCode},
+		{`{"v":1,"type":"start","players":1}`, poker.InvalidCode},
+		{`{"v":1,"type":"finish"}`, poker.InvalidCode},
+		{`{"v":1,"type":"finish","winner":"Ruth","runnersUp":["Chris","Ruth"]}`, poker.InvalidCode},
+		{`{"v":1,"type":"finish","winner":"Ruth","runnersUp":[""]}`, poker.InvalidCode},
+		{`{"v":1,"type":"clock","command":"rewind"}`, poker.InvalidCode},
+		{`{"v":1,"type":"out","player":" "}`, poker.InvalidCode},
+	}
+
+	for _, c := range invalid {
+		t.Run(c.message, func(t *testing.T) {
+			_, err := poker.ParseWSRequest([]byte(c.message))
+
+			var wsErr poker.WSError
+			if !errors.As(err, &wsErr) {
+				t.Fatalf("expected a WSError but got %v", err)
+			}
+			if wsErr.Code != c.code {
+				t.Errorf("got code %q want %q, %v", wsErr.Code, c.code, err)
+			}
+		})
+	}
+}